@@ -1,40 +1,97 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/cloudflare/circl/oprf"
 
 	"github.com/ensimag-oprf/go/client/core"
+	"github.com/ensimag-oprf/go/protocol"
 )
 
-const serverURL = "http://localhost:1323/api"
+const (
+	serverURL = "http://localhost:1323/api"
+	// envTrustedCallerToken is the bearer token of the full evaluation endpoint, read from the environment rather
+	// than from the command line
+	envTrustedCallerToken = "OPRF_TRUSTED_CALLER_TOKEN"
+	// envVerifierToken is the bearer token of the verification endpoint
+	envVerifierToken = "OPRF_VERIFIER_TOKEN"
+	// verifyCommand checks a pseudonym : verify <input> <base64 output>
+	verifyCommand = "verify"
+)
 
 var (
-	modeFlag *uint
-	suiteID  string
-	help     bool
+	modeFlag   *uint
+	suiteID    string
+	keyID      string
+	servers    string
+	encoding   string
+	rootKey    string
+	checkpoint string
+	infoHex    string
+	infosHex   string
+	legacy     bool
+	partial    bool
+	full       bool
+	help       bool
+
+	// fields of the structured public information
+	application string
+	purpose     string
+	epoch       uint64
+	recipient   string
 )
 
 func commandLine() {
 	modeFlag = flag.Uint("mode", uint(oprf.BaseMode), "mode")
 
-	flag.StringVar(&suiteID, "suite", "P256-SHA256", "Cipher suite : P256-SHA256, P384-SHA384 or P521-SHA512")
+	flag.StringVar(&suiteID, "suite", "P256-SHA256", "Cipher suite : P256-SHA256, P384-SHA384, P521-SHA512 or "+
+		"ristretto255-SHA512, negotiated with the server if empty")
+	flag.StringVar(&keyID, "kid", "", "Key ID of the server key, the active key is used if empty")
+	flag.StringVar(&servers, "servers", "", "Comma-separated URLs of threshold evaluators holding key shares, "+
+		"e.g. http://localhost:1324/api,http://localhost:1325/api")
+	flag.StringVar(&encoding, "encoding", "", "Encoding of the evaluation : json, cbor or binary, "+
+		"negotiated with the server if empty")
+	flag.StringVar(&rootKey, "root-key", "", "Base64 Ed25519 root key of the server, the keys are only used if "+
+		"their signed key set is signed by this root")
+	flag.StringVar(&checkpoint, "checkpoint", "", "File of the checkpoints of the transparency log, the signed keys "+
+		"are also checked against the log of the server if it is set")
+	flag.StringVar(&infoHex, "info", "", "Hex encoded public information of the partially oblivious mode, "+
+		"random if empty")
+	flag.StringVar(&infosHex, "infos", "", "Comma-separated hex encoded public information of each input, "+
+		"evaluated in a single request of the partially oblivious mode")
+	flag.BoolVar(&legacy, "legacy-info", false, "Use the text of the hex public information rather than its bytes, "+
+		"as the previous clients did, to reproduce their outputs")
+	flag.BoolVar(&partial, "partial", false, "Evaluate the valid inputs of the batch even if other inputs are "+
+		"rejected, and report the rejected inputs")
+	flag.BoolVar(&full, "full", false, "Evaluate the inputs without blinding on the endpoint of the trusted callers, "+
+		"with the token of "+envTrustedCallerToken)
+	flag.StringVar(&application, "application", "", "Application field of the structured public information")
+	flag.StringVar(&purpose, "purpose", "", "Purpose field of the structured public information")
+	flag.Uint64Var(&epoch, "epoch", 0, "Epoch field of the structured public information, none if 0")
+	flag.StringVar(&recipient, "recipient", "", "Recipient field of the structured public information")
 	flag.BoolVar(&help, "help", false, "Show the usage")
 
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s [flags] [inputs...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s [flags] %s <input> <base64 output>\n\tverify a pseudonym with the "+
+			"token of %s, the -kid flag selects a retired key\n", os.Args[0], verifyCommand, envVerifierToken)
 		flag.PrintDefaults()
 	}
 }
 
 func main() {
+	commandLine()
 	flag.Parse()
 
 	mode := oprf.Mode(*modeFlag)
@@ -46,15 +103,15 @@ func main() {
 		os.Exit(0)
 	}
 
-	suite, err := oprf.GetSuite(suiteID)
-	if err != nil {
-		log.Fatal(err)
-	}
+	// an empty suite is negotiated with the server
+	var suite oprf.Suite
 
-	log.Println(mode, suite)
-
-	// Set up the client
-	client := core.NewClient(serverURL, suite, mode)
+	if suiteID != "" {
+		var err error
+		if suite, err = oprf.GetSuite(suiteID); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// Convert the string input to bytes
 	dataBytes := make([][]byte, len(data))
@@ -66,6 +123,74 @@ func main() {
 		dataBytes = [][]byte{{0x00}, {0xFF}}
 	}
 
+	if servers != "" {
+		if suite == nil {
+			log.Fatal("the threshold evaluators need a suite")
+		}
+
+		thresholdPseudonymize(strings.Split(servers, ","), suite, mode, dataBytes)
+
+		return
+	}
+
+	// Set up the client, with the keys signed by the root key if any
+	var rootPublicKey ed25519.PublicKey
+
+	if rootKey != "" {
+		var err error
+		if rootPublicKey, err = base64.StdEncoding.DecodeString(rootKey); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var (
+		client *core.Client
+		err    error
+	)
+
+	if checkpoint != "" {
+		if rootPublicKey == nil {
+			log.Fatal("the transparency log is checked with the root key")
+		}
+
+		client, err = core.NewClientWithTransparencyLog(serverURL, suite, mode, rootPublicKey, checkpoint)
+	} else {
+		client, err = core.NewClientWithRootKey(serverURL, suite, mode, rootPublicKey)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	suite = client.Suite()
+	log.Println(mode, suite)
+
+	if encoding != "" {
+		if err := client.SetContentType(protocol.Encodings[encoding]); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client.SetPartialSuccess(partial)
+
+	if len(data) > 0 && data[0] == verifyCommand {
+		verifyPseudonym(client, data[1:])
+
+		return
+	}
+
+	if full {
+		fullPseudonymize(client, dataBytes)
+
+		return
+	}
+
+	if infosHex != "" {
+		batchPseudonymize(client, strings.Split(infosHex, ","), dataBytes)
+
+		return
+	}
+
 	// Request of pseudonymization
 	finalizeData, oprfEvaluationRequest, err := client.Blind(dataBytes)
 	if err != nil {
@@ -80,14 +205,36 @@ func main() {
 	// Any system which has multiple POPRF applications should distinguish client inputs to
 	// ensure the POPRF results are separate.
 	// info := "7465737420696e666f"
-	// Generate a random information for each request for non-deterministic results
-	token := make([]byte, 256)
-	if _, err := rand.Read(token); err != nil {
-		log.Fatal(err)
-	}
+	// Generate a random information for each request for non-deterministic results, the public information is only
+	// used in the partially oblivious mode
+	var info []byte
+
+	if mode == oprf.PartialObliviousMode {
+		if infoHex == "" && (application != "" || purpose != "" || epoch != 0 || recipient != "") {
+			data, err := structuredInfo()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			infoHex = hex.EncodeToString(data)
+		}
 
-	info := hex.EncodeToString(token)
-	log.Println("Public information : ", info)
+		if infoHex == "" {
+			token := make([]byte, 256)
+			if _, err := rand.Read(token); err != nil {
+				log.Fatal(err)
+			}
+
+			infoHex = hex.EncodeToString(token)
+		}
+
+		log.Println("Public information : ", infoHex)
+
+		info, err = core.ParseInfo(infoHex, legacy)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	blindedElements, err := core.SerializeElements(oprfEvaluationRequest.Elements)
 	if err != nil {
@@ -97,6 +244,8 @@ func main() {
 	evaluationRequest := core.NewEvaluationRequest(
 		suite, mode, info, blindedElements,
 	)
+	evaluationRequest.KeyID = keyID
+	evaluationRequest.PartialSuccess = partial
 
 	evaluationResponse, err := client.EvaluateRequest(evaluationRequest)
 	if err != nil {
@@ -113,8 +262,20 @@ func main() {
 	// 	return
 	// }
 
+	log.Println("Key ID : ", evaluationResponse.KeyID)
+
+	// the fingerprint can be compared out of band with the published one
+	if publicKey, err := client.PublicKey(evaluationResponse.KeyID); err == nil {
+		fingerprint, err := core.Fingerprint(suite, publicKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("Fingerprint : ", fingerprint)
+	}
+
 	for _, element := range evaluationResponse.Evaluation.Elements {
-		data, err := element.MarshalBinary()
+		data, err := element.MarshalBinaryCompress()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -122,8 +283,58 @@ func main() {
 		log.Println("Evaluation : ", base64.StdEncoding.EncodeToString(data))
 	}
 
-	// Finalize the OPRF protocol
-	outputs, err := client.Finalize(finalizeData, evaluationResponse.Evaluation, info)
+	// Finalize the OPRF protocol, the outputs of the rejected inputs of a partial-success batch are nil
+	outputs, err := client.FinalizePartial(dataBytes, finalizeData, evaluationResponse, info)
+	logFailures(err)
+
+	for _, output := range outputs {
+		if output != nil {
+			log.Println("Output : ", base64.StdEncoding.EncodeToString(output))
+		}
+	}
+}
+
+// logFailures logs the rejected inputs of a partial-success batch, and exits on the other errors.
+func logFailures(err error) {
+	var batchError *core.BatchError
+	if errors.As(err, &batchError) {
+		for _, failure := range batchError.Failures {
+			log.Println("Rejected input : ", failure.Index, failure.Err)
+		}
+	} else if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// thresholdPseudonymize evaluates the inputs on the threshold evaluators and combines the partial evaluations.
+func thresholdPseudonymize(serverURLs []string, suite oprf.Suite, mode oprf.Mode, dataBytes [][]byte) {
+	client, err := core.NewThresholdClient(serverURLs, suite, mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	finalizeData, oprfEvaluationRequest, err := client.Blind(dataBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	blindedElements, err := core.SerializeElements(oprfEvaluationRequest.Elements)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	partialEvaluations, err := client.EvaluateRequest(
+		core.NewEvaluationRequest(suite, mode, nil, blindedElements),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, partialEvaluation := range partialEvaluations {
+		log.Println("Partial evaluation of the share", partialEvaluation.ShareIndex, "of the key", partialEvaluation.KeyID)
+	}
+
+	outputs, err := client.Finalize(finalizeData, partialEvaluations)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -132,3 +343,115 @@ func main() {
 		log.Println("Output : ", base64.StdEncoding.EncodeToString(output))
 	}
 }
+
+// fullPseudonymize evaluates the inputs without blinding, the caller must be trusted by the server.
+func fullPseudonymize(client *core.Client, dataBytes [][]byte) {
+	client.SetTrustedCallerToken(os.Getenv(envTrustedCallerToken))
+
+	info, err := flagInfo()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	outputs, err := client.FullEvaluate(dataBytes, info, keyID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, output := range outputs {
+		log.Println("Output : ", base64.StdEncoding.EncodeToString(output))
+	}
+}
+
+// verifyPseudonym checks that the base64 output is the pseudonym of the input, it exits with the status 1 if it isn't.
+func verifyPseudonym(client *core.Client, args []string) {
+	if len(args) != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client.SetVerifierToken(os.Getenv(envVerifierToken))
+
+	output, err := base64.StdEncoding.DecodeString(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	info, err := flagInfo()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	match, err := client.Verify([]byte(args[0]), info, output, keyID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !match {
+		log.Println("No match")
+		os.Exit(1)
+	}
+
+	log.Println("Match")
+}
+
+// flagInfo returns the public information of the -info flag or of the structured fields flags, nil if none is set.
+func flagInfo() ([]byte, error) {
+	if infoHex != "" {
+		return core.ParseInfo(infoHex, legacy) //nolint:wrapcheck
+	}
+
+	if application != "" || purpose != "" || epoch != 0 || recipient != "" {
+		return structuredInfo()
+	}
+
+	return nil, nil
+}
+
+// structuredInfo builds the structured public information of the field flags.
+func structuredInfo() ([]byte, error) {
+	infoBuilder := core.NewInfoBuilder()
+
+	if application != "" {
+		infoBuilder.Application(application)
+	}
+
+	if purpose != "" {
+		infoBuilder.Purpose(purpose)
+	}
+
+	if epoch != 0 {
+		infoBuilder.Epoch(epoch)
+	}
+
+	if recipient != "" {
+		infoBuilder.Recipient(recipient)
+	}
+
+	return infoBuilder.Build() //nolint:wrapcheck
+}
+
+// batchPseudonymize evaluates the inputs with their own public information in a single request.
+func batchPseudonymize(client *core.Client, infosHex []string, dataBytes [][]byte) {
+	infos := make([][]byte, len(infosHex))
+
+	for index, elementInfo := range infosHex {
+		info, err := core.ParseInfo(strings.TrimSpace(elementInfo), legacy)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		infos[index] = info
+	}
+
+	outputs, err := client.EvaluateBatch(dataBytes, infos, keyID)
+	logFailures(err)
+
+	for index, output := range outputs {
+		if output == nil {
+			continue
+		}
+
+		log.Println("Output : ", infosHex[index], base64.StdEncoding.EncodeToString(output))
+	}
+}
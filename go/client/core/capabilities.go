@@ -0,0 +1,86 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+var ErrNegotiation = errors.New("couldn't negotiate with the server")
+
+// negotiate selects the suite and the encoding with the capabilities of the server. A nil suite selects the first
+// suite offered by the server in the mode of the client, otherwise the suite must be offered in the mode.
+// The encoding is the first encoding of the server supported by the client. The servers without the capabilities
+// endpoint are used with the requested suite and JSON.
+func (c *Client) negotiate(suite oprf.Suite) error {
+	capabilities, err := c.httpClient.GetCapabilities()
+	if errors.Is(err, protocol.ErrNotFound) {
+		if suite == nil {
+			return fmt.Errorf("%w : the server doesn't describe its suites, a suite is required", ErrNegotiation)
+		}
+
+		c.suite = suite
+
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	mode := protocol.NewMode(c.mode)
+
+	if suite == nil {
+		if suite = preferredSuite(capabilities, mode); suite == nil {
+			return fmt.Errorf("%w : no suite known by the client in the %s mode", protocol.ErrNotOffered, mode)
+		}
+	} else if err := capabilities.CheckSuite(suite.Identifier(), mode); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	contentType := ""
+
+	for _, encoding := range capabilities.Encodings {
+		if protocol.ValidateContentType(encoding) == nil {
+			contentType = encoding
+
+			break
+		}
+	}
+
+	if contentType == "" {
+		return fmt.Errorf("%w : no known encoding in %v", ErrNegotiation, capabilities.Encodings)
+	}
+
+	if err := c.httpClient.SetContentType(contentType); err != nil {
+		return err
+	}
+
+	c.suite = suite
+	c.capabilities = capabilities
+
+	return nil
+}
+
+// preferredSuite returns the first suite of the server known by the client and offered in the mode, nil if there is
+// none.
+func preferredSuite(capabilities *protocol.Capabilities, mode protocol.Mode) oprf.Suite {
+	for index := range capabilities.Suites {
+		suite, err := oprf.GetSuite(capabilities.Suites[index].Suite)
+		if err == nil && capabilities.Suites[index].HasMode(mode) {
+			return suite
+		}
+	}
+
+	return nil
+}
+
+// Capabilities returns the capabilities of the server, nil if the server doesn't describe them.
+func (c *Client) Capabilities() *protocol.Capabilities {
+	return c.capabilities
+}
+
+// Suite returns the suite of the client, negotiated with the server if NewClient had no suite.
+func (c *Client) Suite() oprf.Suite {
+	return c.suite
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+func TestNewClientNegotiation(t *testing.T) {
+	privateKey, err := oprf.GenerateKey(oprf.SuiteP384, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := privateKey.Public().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an unknown suite and encoding are skipped
+	capabilities := &protocol.Capabilities{
+		APIVersions: []string{protocol.APIVersion2},
+		Suites: []protocol.SuiteCapabilities{
+			{Suite: "decaf448-SHAKE256", Modes: []protocol.Mode{protocol.ModeBase}, KeyIDs: nil, ActiveKeyID: ""},
+			{Suite: oprf.SuiteP521.Identifier(), Modes: []protocol.Mode{protocol.ModeBase}, KeyIDs: nil, ActiveKeyID: ""},
+			{
+				Suite:       oprf.SuiteP384.Identifier(),
+				Modes:       []protocol.Mode{protocol.ModeBase, protocol.ModeVerifiable},
+				KeyIDs:      []string{testKeyID},
+				ActiveKeyID: testKeyID,
+			},
+		},
+		Encodings: []string{"application/x-unknown", protocol.ContentTypeCBOR, protocol.ContentTypeJSON},
+		Limits:    protocol.LimitsOffer{MaxBodySize: 1 << 20, MaxBatchSize: 100, MaxInfoLength: 1024},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(protocol.V2Path+protocol.CapabilitiesEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(capabilities)
+	})
+	mux.HandleFunc(protocol.V2Path+protocol.PublicKeysEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string][]protocol.PublicKeyInfo{
+			oprf.SuiteP384.Identifier(): {{ID: testKeyID, State: protocol.KeyStateActive, PublicKey: publicKey}},
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, nil, oprf.VerifiableMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.Suite() != oprf.SuiteP384 || client.httpClient.contentType != protocol.ContentTypeCBOR {
+		t.Errorf("negotiated %s with %s", client.Suite().Identifier(), client.httpClient.contentType)
+	}
+
+	if err := client.SetContentType(protocol.ContentTypeBinary); !errors.Is(err, protocol.ErrNotOffered) {
+		t.Errorf("the binary encoding isn't offered : %v", err)
+	}
+
+	if _, err := NewClient(server.URL, nil, oprf.PartialObliviousMode); !errors.Is(err, protocol.ErrNotOffered) {
+		t.Errorf("no suite is offered in the partially oblivious mode : %v", err)
+	}
+
+	if _, err := NewClient(server.URL, oprf.SuiteP521, oprf.VerifiableMode); !errors.Is(err, protocol.ErrNotOffered) {
+		t.Errorf("the suite %s isn't offered in the verifiable mode : %v", oprf.SuiteP521.Identifier(), err)
+	}
+
+	// a server without the capabilities endpoint needs a suite
+	legacy := httptest.NewServer(http.NotFoundHandler())
+	defer legacy.Close()
+
+	if _, err := NewClient(legacy.URL, nil, oprf.BaseMode); !errors.Is(err, ErrNegotiation) {
+		t.Errorf("negotiated without capabilities : %v", err)
+	}
+}
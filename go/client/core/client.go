@@ -1,36 +1,109 @@
 package core
 
 import (
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+var (
+	ErrUnknownKeyID     = errors.New("unknown key ID")
+	ErrNoCheckpointPath = errors.New("the transparency log is checked with a checkpoint file")
 )
 
 // Client regroup an HTTP client and an OPRF client
 type Client struct {
 	httpClient *HTTPClient
 	oprfClient OprfClientInterface
-	// suite:public key
-	publicKeys map[string]*oprf.PublicKey
+	suite      oprf.Suite
+	mode       oprf.Mode
+	// suite:kid:public key
+	publicKeys map[string]map[string]*oprf.PublicKey
+	// suite:kid of the active key
+	activeKeyIDs map[string]string
+	// partialSuccess sends the batches of EvaluateBatch as partial-success batches
+	partialSuccess bool
+	// capabilities of the server, nil if the server doesn't describe them
+	capabilities *protocol.Capabilities
+	// rootKey checks the signature of the key set, the keys aren't signed if it is nil
+	rootKey ed25519.PublicKey
+	// checkpointPath is the file of the checkpoints of the transparency log, the log isn't checked if it is empty
+	checkpointPath string
+}
+
+// NewClient returns a new HTTP + OPRF client with the provided server URL, suite and mode, set up with the server's
+// active key. The suite and the encoding are negotiated with the capabilities of the server : a nil suite selects the
+// preferred suite of the server in the mode, and a suite that the server doesn't offer in the mode returns an
+// error wrapping protocol.ErrNotOffered.
+func NewClient(serverURL string, suite oprf.Suite, mode oprf.Mode) (*Client, error) {
+	return newClient(serverURL, suite, mode, nil, "")
+}
+
+// NewClientWithRootKey returns a new client which only uses the keys of the signed key set of the server, whose
+// signature is checked against the Ed25519 root key. It returns a protocol.ErrInvalidSignature or a
+// protocol.ErrExpiredKeySet error if the key set can't be trusted.
+func NewClientWithRootKey(serverURL string, suite oprf.Suite, mode oprf.Mode, rootKey ed25519.PublicKey,
+) (*Client, error) {
+	return newClient(serverURL, suite, mode, rootKey, "")
+}
+
+// NewClientWithTransparencyLog returns a new client which uses the keys of the signed key set as
+// NewClientWithRootKey, and which also checks them against the transparency log of the server. The keys of the client
+// suite must be included in the signed tree head of the log, and the log must have only grown since the checkpoint
+// saved in the checkpointPath file, otherwise it returns a protocol.ErrInvalidProof or a protocol.ErrLogInconsistent
+// error. The server must persist its log, see OPRF_TRANSPARENCY_LOG.
+func NewClientWithTransparencyLog(serverURL string, suite oprf.Suite, mode oprf.Mode, rootKey ed25519.PublicKey,
+	checkpointPath string,
+) (*Client, error) {
+	if checkpointPath == "" {
+		return nil, ErrNoCheckpointPath
+	}
+
+	return newClient(serverURL, suite, mode, rootKey, checkpointPath)
 }
 
-// NewClient returns a new HTTP + OPRF client with the provided server URL, suite, mode and static key.
-// No static key is needed for oprf.BaseMode.
-func NewClient(serverURL string, suite oprf.Suite, mode oprf.Mode) *Client {
-	client := &Client{
-		httpClient: NewHttpClient(serverURL),
+func newClient(serverURL string, suite oprf.Suite, mode oprf.Mode, rootKey ed25519.PublicKey, checkpointPath string,
+) (*Client, error) {
+	client := &Client{ //nolint:exhaustivestruct
+		httpClient:     NewHttpClient(serverURL),
+		mode:           mode,
+		rootKey:        rootKey,
+		checkpointPath: checkpointPath,
 	}
-	if err := client.setupOPRFClient(suite, mode); err != nil {
-		log.Fatal(err)
+
+	if err := client.negotiate(suite); err != nil {
+		return nil, err
+	}
+
+	if err := client.setupOPRFClient(); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// SetupOPRFClient retrieve the server's public keys and create the OPRF client with the active key.
+func (c *Client) setupOPRFClient() error {
+	if err := c.refreshPublicKeys(); err != nil {
+		return err
 	}
 
-	return client
+	suiteID := c.suite.Identifier()
+	publicKey := c.publicKeys[suiteID][c.activeKeyIDs[suiteID]]
+	c.oprfClient = NewOPRFClient(c.suite, c.mode, publicKey)
+
+	return nil
 }
 
-// SetupOPRFClient retrieve the server's public keys and create the OPRF client.
-func (c *Client) setupOPRFClient(suite oprf.Suite, mode oprf.Mode) error {
-	serializedPublicKeys, err := c.httpClient.GetPublicKeys()
+// refreshPublicKeys retrieve the server's published public keys, from the signed key set if the client has a root
+// key.
+func (c *Client) refreshPublicKeys() error {
+	serializedPublicKeys, err := c.getPublicKeys()
 	if err != nil {
 		log.Println("error when getting the static keys")
 
@@ -45,13 +118,68 @@ func (c *Client) setupOPRFClient(suite oprf.Suite, mode oprf.Mode) error {
 	}
 
 	c.publicKeys = publicKeys
-
-	publicKey := publicKeys[suite.Identifier()]
-	c.oprfClient = NewOPRFClient(suite, mode, publicKey)
+	c.activeKeyIDs = ActiveKeyIDs(serializedPublicKeys)
 
 	return nil
 }
 
+// PublicKey returns the server's public key of the client suite with the provided key ID.
+// An empty key ID selects the active key. The public keys are fetched again once if the key ID is unknown,
+// for instance after a key rotation.
+func (c *Client) PublicKey(kid string) (*oprf.PublicKey, error) {
+	if publicKey, ok := c.lookupPublicKey(kid); ok {
+		return publicKey, nil
+	}
+
+	if err := c.refreshPublicKeys(); err != nil {
+		return nil, err
+	}
+
+	publicKey, ok := c.lookupPublicKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w : %q for the suite %s", ErrUnknownKeyID, kid, c.suite.Identifier())
+	}
+
+	return publicKey, nil
+}
+
+// getPublicKeys returns the published public keys, checked with the root key if the client has one and with the
+// transparency log if the client has a checkpoint file.
+func (c *Client) getPublicKeys() (map[string][]protocol.PublicKeyInfo, error) {
+	if c.rootKey == nil {
+		return c.httpClient.GetPublicKeys()
+	}
+
+	signedKeySet, err := c.httpClient.GetSignedKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := signedKeySet.Verify(c.rootKey, time.Now())
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if c.checkpointPath != "" {
+		if err := c.checkTransparencyLog(document); err != nil {
+			return nil, err
+		}
+	}
+
+	return document.PublicKeys(), nil
+}
+
+func (c *Client) lookupPublicKey(kid string) (*oprf.PublicKey, bool) {
+	suiteID := c.suite.Identifier()
+	if kid == "" {
+		kid = c.activeKeyIDs[suiteID]
+	}
+
+	publicKey, ok := c.publicKeys[suiteID][kid]
+
+	return publicKey, ok
+}
+
 // Blind generates a request for the server by passing an array of inputs to be evaluated by server.
 func (c *Client) Blind(inputs [][]byte) (*oprf.FinalizeData, *oprf.EvaluationRequest, error) {
 	return c.oprfClient.Blind(inputs)
@@ -63,16 +191,108 @@ func (c *Client) DeterministicBlind(inputs [][]byte, blinds []oprf.Blind) (*oprf
 	return c.oprfClient.DeterministicBlind(inputs, blinds)
 }
 
-func (c *Client) EvaluateRequest(evaluationRequest *EvaluationRequest) (*EvaluationResponse, error) {
+// SetTrustedCallerToken sets the bearer token of the full evaluation endpoint, used by FullEvaluate.
+func (c *Client) SetTrustedCallerToken(token string) {
+	c.httpClient.SetTrustedCallerToken(token)
+}
+
+// FullEvaluate computes the PRF outputs of the inputs with the suite and the mode of the client, without blinding.
+// It is reserved to the trusted callers, the server must have a trusted caller token. The outputs are in the order of
+// the inputs, the optional key ID selects a published key. The public information is only used in the partially
+// oblivious mode.
+func (c *Client) FullEvaluate(inputs [][]byte, info []byte, keyID string) ([][]byte, error) {
+	fullEvaluationResponse, err := c.httpClient.FullEvaluate(&protocol.FullEvaluationRequest{
+		Suite:  c.suite.Identifier(),
+		Mode:   protocol.NewMode(c.mode),
+		Info:   info,
+		Inputs: inputs,
+		KeyID:  keyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fullEvaluationResponse.Outputs) != len(inputs) {
+		return nil, fmt.Errorf("%w : %d outputs for %d inputs", protocol.ErrInvalidEncoding,
+			len(fullEvaluationResponse.Outputs), len(inputs))
+	}
+
+	return fullEvaluationResponse.Outputs, nil
+}
+
+// SetVerifierToken sets the bearer token of the verification endpoint, used by Verify.
+func (c *Client) SetVerifierToken(token string) {
+	c.httpClient.SetVerifierToken(token)
+}
+
+// Verify checks on the server that the output is the PRF output of the input and of the public information, with the
+// suite and the mode of the client. It is reserved to the data stewards, the server must have a verifier token.
+// The optional key ID selects any key of the suite, including the retired keys. The info is the evaluated one, see
+// EvaluatedInfo.
+func (c *Client) Verify(input, info, output []byte, keyID string) (bool, error) {
+	verificationResponse, err := c.httpClient.Verify(&protocol.VerificationRequest{
+		Suite:  c.suite.Identifier(),
+		Mode:   protocol.NewMode(c.mode),
+		Info:   info,
+		Input:  input,
+		Output: output,
+		KeyID:  keyID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return verificationResponse.Match, nil
+}
+
+// SetContentType sets the encoding of the evaluation requests and responses, it must be offered by the server.
+func (c *Client) SetContentType(contentType string) error {
+	if c.capabilities != nil && !c.capabilities.HasEncoding(contentType) {
+		return fmt.Errorf("the encoding %s is %w", contentType, protocol.ErrNotOffered)
+	}
+
+	return c.httpClient.SetContentType(contentType)
+}
+
+func (c *Client) EvaluateRequest(evaluationRequest *protocol.EvaluationRequest) (*EvaluationResponse, error) {
 	return c.httpClient.EvaluateRequest(evaluationRequest)
 }
 
 // Finalize computes the signed token from the server Evaluation and returns the output of the
-// OPRF protocol. The function uses server's static key to verify the proof in verifiable mode.
+// OPRF protocol. The function uses the server's public key with the evaluation key ID to verify
+// the proof in verifiable mode, and the evaluated public information of the response if the server injected fields.
 func (c *Client) Finalize(finalizeData *oprf.FinalizeData,
-	evaluation *oprf.Evaluation, info string,
+	evaluationResponse *EvaluationResponse, info []byte,
 ) ([][]byte, error) {
-	clientOutputs, err := c.oprfClient.Finalize(finalizeData, evaluation, []byte(info))
+	oprfClient := c.oprfClient
+
+	if evaluationResponse.Evaluation == nil {
+		return nil, fmt.Errorf("finalize error : the response has evaluation groups, use EvaluateBatch")
+	} else if newBatchError(evaluationResponse.Statuses) != nil {
+		return nil, fmt.Errorf("finalize error : %w, use FinalizePartial", ErrPartialFailure)
+	}
+
+	// No static key is needed for oprf.BaseMode
+	if c.mode != oprf.BaseMode {
+		publicKey, err := c.PublicKey(evaluationResponse.KeyID)
+		if err != nil {
+			log.Println("Finalize error :", err)
+
+			return nil, fmt.Errorf("finalize error : %w", err)
+		}
+
+		oprfClient = NewOPRFClient(c.suite, c.mode, publicKey)
+	}
+
+	// the server may have injected fields in the public information
+	info, err := EvaluatedInfo(info, evaluationResponse.Info)
+	if err != nil {
+		log.Println("Finalize error :", err)
+
+		return nil, fmt.Errorf("finalize error : %w", err)
+	}
+
+	clientOutputs, err := oprfClient.Finalize(finalizeData, evaluationResponse.Evaluation, info)
 	if err != nil || clientOutputs == nil {
 		log.Println("Finalize error :", err, clientOutputs)
 
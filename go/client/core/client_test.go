@@ -2,7 +2,6 @@ package core
 
 import (
 	"crypto/rand"
-	"encoding/hex"
 	"log"
 	"testing"
 
@@ -10,22 +9,28 @@ import (
 )
 
 func setup(mode oprf.Mode, suite oprf.Suite) *Client {
-	return NewClient("http://localhost:1323/api", suite, mode)
+	client, err := NewClient("http://localhost:1323/api", suite, mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return client
 }
 
 func exchange(client *Client, mode oprf.Mode, suite oprf.Suite) [][]byte {
 	finalizeData, oprfEvaluationRequest, _ := client.Blind([][]byte{[]byte("dead3eef")})
 
-	token := make([]byte, 256)
-	if _, err := rand.Read(token); err != nil {
-		log.Println(err)
+	// the public information is only sent in the partially oblivious mode
+	var info []byte
+	if mode == oprf.PartialObliviousMode {
+		info = make([]byte, 256)
+		if _, err := rand.Read(info); err != nil {
+			log.Println(err)
 
-		return nil
+			return nil
+		}
 	}
 
-	info := hex.EncodeToString(token)
-	// log.Println("Public information : ", info)
-
 	blindedElements, err := SerializeElements(oprfEvaluationRequest.Elements)
 	if err != nil {
 		log.Fatal(err)
@@ -40,7 +45,7 @@ func exchange(client *Client, mode oprf.Mode, suite oprf.Suite) [][]byte {
 		log.Fatal(err)
 	}
 
-	outputs, err := client.Finalize(finalizeData, evaluation.Evaluation, info)
+	outputs, err := client.Finalize(finalizeData, evaluation, info)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -95,3 +100,15 @@ func BenchmarkClientVerifiableModeOPRFP521(b *testing.B) {
 func BenchmarkClientPartiallyObliviousModeOPRFP521(b *testing.B) {
 	benchmarkClient(b, oprf.PartialObliviousMode, oprf.SuiteP521)
 }
+
+func BenchmarkClientBaseModeOPRFRistretto255(b *testing.B) {
+	benchmarkClient(b, oprf.BaseMode, oprf.SuiteRistretto255)
+}
+
+func BenchmarkClientVerifiableModeOPRFRistretto255(b *testing.B) {
+	benchmarkClient(b, oprf.VerifiableMode, oprf.SuiteRistretto255)
+}
+
+func BenchmarkClientPartiallyObliviousModeOPRFRistretto255(b *testing.B) {
+	benchmarkClient(b, oprf.PartialObliviousMode, oprf.SuiteRistretto255)
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+var ErrGroupMismatch = errors.New("the evaluation groups don't match the request")
+
+// inputGroup holds the inputs of a batch sharing a public information
+type inputGroup struct {
+	indices      []uint16
+	inputs       [][]byte
+	info         []byte
+	finalizeData *oprf.FinalizeData
+}
+
+// groupInputs groups the inputs by public information, in the order of the first input of each group, as the server
+// groups the elements.
+func groupInputs(inputs, infos [][]byte) []*inputGroup {
+	groupIndices := make(map[string]int)
+
+	var groups []*inputGroup
+
+	for index, input := range inputs {
+		groupIndex, ok := groupIndices[string(infos[index])]
+		if !ok {
+			groupIndex = len(groups)
+			groupIndices[string(infos[index])] = groupIndex
+			groups = append(groups, &inputGroup{indices: nil, inputs: nil, info: infos[index], finalizeData: nil})
+		}
+
+		groups[groupIndex].indices = append(groups[groupIndex].indices, uint16(index))
+		groups[groupIndex].inputs = append(groups[groupIndex].inputs, input)
+	}
+
+	return groups
+}
+
+// EvaluateBatch pseudonymizes the inputs in the partially oblivious mode with a public information per input, in a
+// single request. The inputs sharing a public information are blinded and finalized as a group, with the proof of
+// their group. The outputs are in the order of the inputs. The optional key ID selects a published key.
+// If the client sends partial-success batches, the outputs of the inputs which weren't evaluated are nil and
+// reported by a *BatchError, returned with the outputs.
+func (c *Client) EvaluateBatch(inputs, infos [][]byte, keyID string) ([][]byte, error) {
+	if c.mode != oprf.PartialObliviousMode {
+		return nil, fmt.Errorf("%w : the %s mode has no public information", ErrGroupMismatch, protocol.NewMode(c.mode))
+	}
+
+	if len(inputs) != len(infos) || len(inputs) == 0 || len(inputs) > math.MaxUint16 {
+		return nil, fmt.Errorf("%w : %d infos for %d inputs", ErrGroupMismatch, len(infos), len(inputs))
+	}
+
+	groups := groupInputs(inputs, infos)
+	blindedElements := make([][]byte, len(inputs))
+
+	for _, inputGroup := range groups {
+		finalizeData, evaluationRequest, err := c.Blind(inputGroup.inputs)
+		if err != nil {
+			return nil, err
+		}
+
+		serializedElements, err := SerializeElements(evaluationRequest.Elements)
+		if err != nil {
+			return nil, err
+		}
+
+		for groupIndex, index := range inputGroup.indices {
+			blindedElements[index] = serializedElements[groupIndex]
+		}
+
+		inputGroup.finalizeData = finalizeData
+	}
+
+	evaluationRequest := NewEvaluationRequest(c.suite, c.mode, nil, blindedElements)
+	evaluationRequest.Infos = infos
+	evaluationRequest.KeyID = keyID
+	evaluationRequest.PartialSuccess = c.partialSuccess
+
+	evaluationResponse, err := c.EvaluateRequest(evaluationRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// the groups rejected by the server are missing from a partial-success batch
+	statuses := evaluationResponse.Statuses
+	if len(statuses) > 0 && len(statuses) != len(inputs) {
+		return nil, fmt.Errorf("%w : %d statuses for %d inputs", ErrStatusMismatch, len(statuses), len(inputs))
+	} else if len(statuses) == 0 && len(evaluationResponse.Groups) != len(groups) {
+		return nil, fmt.Errorf("%w : %d groups, expected %d", ErrGroupMismatch, len(evaluationResponse.Groups),
+			len(groups))
+	}
+
+	outputs := make([][]byte, len(inputs))
+
+	for _, evaluationGroup := range evaluationResponse.Groups {
+		inputGroup, err := matchGroup(groups, evaluationGroup.Indices, statuses)
+		if err != nil {
+			return nil, err
+		}
+
+		groupResponse := *evaluationResponse
+		groupResponse.Evaluation, groupResponse.Info, groupResponse.Groups, groupResponse.Statuses =
+			evaluationGroup.Evaluation, evaluationGroup.Info, nil, groupStatuses(statuses, inputGroup.indices)
+
+		// the failures are reported with the statuses of the whole batch
+		var batchError *BatchError
+
+		groupOutputs, err := c.FinalizePartial(inputGroup.inputs, inputGroup.finalizeData, &groupResponse,
+			inputGroup.info)
+		if err != nil && !errors.As(err, &batchError) {
+			return nil, err
+		}
+
+		for groupIndex, index := range inputGroup.indices {
+			outputs[index] = groupOutputs[groupIndex]
+		}
+	}
+
+	// each evaluated input has an output
+	for index, status := range statuses {
+		if status.Evaluated != (outputs[index] != nil) {
+			return nil, fmt.Errorf("%w : input %d", ErrStatusMismatch, index)
+		}
+	}
+
+	if batchError := newBatchError(statuses); batchError != nil {
+		return outputs, batchError
+	}
+
+	return outputs, nil
+}
+
+// groupStatuses returns the statuses of the elements at the indices, nil if the batch has no statuses.
+func groupStatuses(statuses []protocol.ElementStatus, indices []uint16) []protocol.ElementStatus {
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	selected := make([]protocol.ElementStatus, len(indices))
+	for groupIndex, index := range indices {
+		selected[groupIndex] = statuses[index]
+	}
+
+	return selected
+}
+
+// matchGroup returns the group of the inputs at the indices. The server drops the invalid elements of a group from a
+// partial-success batch : the indices must then be the evaluated elements of the group, see FinalizePartial.
+func matchGroup(groups []*inputGroup, indices []uint16, statuses []protocol.ElementStatus) (*inputGroup, error) {
+	for _, inputGroup := range groups {
+		evaluatedIndices := inputGroup.indices
+		if len(statuses) > 0 {
+			evaluatedIndices = nil
+
+			for _, index := range inputGroup.indices {
+				if statuses[index].Evaluated {
+					evaluatedIndices = append(evaluatedIndices, index)
+				}
+			}
+		}
+
+		if len(indices) > 0 && reflect.DeepEqual(evaluatedIndices, indices) {
+			return inputGroup, nil
+		}
+	}
+
+	log.Println("Unexpected evaluation group :", indices)
+
+	return nil, fmt.Errorf("%w : unexpected group %v", ErrGroupMismatch, indices)
+}
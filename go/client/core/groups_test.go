@@ -0,0 +1,166 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/circl/group"
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+// newTestGroupEvaluator returns an in-process partially oblivious evaluator of the grouped batches, which sends the
+// groups in the reverse order. The "rejected" info is rejected in the partial-success batches, and the first element
+// of the "truncated" info is reported as invalid and dropped from its group.
+func newTestGroupEvaluator(t *testing.T, suite oprf.Suite, privateKey *oprf.PrivateKey) *httptest.Server {
+	t.Helper()
+
+	publicKey, err := privateKey.Public().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := oprf.NewPartialObliviousServer(suite, privateKey)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(protocol.V2Path+protocol.PublicKeysEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string][]protocol.PublicKeyInfo{
+			suite.Identifier(): {{ID: testKeyID, State: protocol.KeyStateActive, PublicKey: publicKey}},
+		})
+	})
+	mux.HandleFunc(protocol.V2Path+protocol.EvaluateEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		var evaluationRequest protocol.EvaluationRequest
+		if err := json.NewDecoder(r.Body).Decode(&evaluationRequest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		// the elements are grouped by public information
+		var (
+			infos   []string
+			indices = make(map[string][]uint16)
+		)
+
+		for index, info := range evaluationRequest.Infos {
+			if _, ok := indices[string(info)]; !ok {
+				infos = append(infos, string(info))
+			}
+
+			indices[string(info)] = append(indices[string(info)], uint16(index))
+		}
+
+		var (
+			groups   []protocol.EvaluationGroup
+			statuses []protocol.ElementStatus
+		)
+
+		if evaluationRequest.PartialSuccess {
+			statuses = make([]protocol.ElementStatus, len(evaluationRequest.BlindedElements))
+		}
+
+		for _, info := range infos {
+			if statuses != nil && info == "truncated" {
+				statuses[indices[info][0]].Error = protocol.NewError(protocol.CodeInvalidElement, "invalid element")
+				indices[info] = indices[info][1:]
+			}
+
+			for _, index := range indices[info] {
+				if statuses != nil && info == "rejected" {
+					statuses[index].Error = protocol.NewError(protocol.CodeInfoRejected, "rejected info")
+				} else if statuses != nil {
+					statuses[index].Evaluated = true
+				}
+			}
+
+			if statuses != nil && info == "rejected" {
+				continue
+			}
+
+			elements := make([]group.Element, 0, len(indices[info]))
+
+			for _, index := range indices[info] {
+				element := suite.Group().NewElement()
+				if err := element.UnmarshalBinary(evaluationRequest.BlindedElements[index]); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+
+					return
+				}
+
+				elements = append(elements, element)
+			}
+
+			evaluation, err := server.Evaluate(&oprf.EvaluationRequest{Elements: elements}, []byte(info))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			evaluatedElements, _ := SerializeElements(evaluation.Elements)
+			proof, _ := evaluation.Proof.MarshalBinary()
+
+			// the groups are sent in the reverse order
+			groups = append([]protocol.EvaluationGroup{{
+				Indices:    indices[info],
+				Evaluation: &protocol.Evaluation{Proof: proof, Elements: evaluatedElements},
+			}}, groups...)
+		}
+
+		_ = json.NewEncoder(w).Encode(protocol.EvaluationResponse{
+			Suite:    suite.Identifier(),
+			KeyID:    testKeyID,
+			Groups:   groups,
+			Statuses: statuses,
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestEvaluateBatch(t *testing.T) {
+	suite := oprf.SuiteP256
+
+	privateKey, err := oprf.GenerateKey(suite, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluator := newTestGroupEvaluator(t, suite, privateKey)
+	defer evaluator.Close()
+
+	client, err := NewClient(evaluator.URL, suite, oprf.PartialObliviousMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := [][]byte{[]byte("alice"), []byte("bob"), []byte("carol")}
+	infos := [][]byte{[]byte("survey"), []byte("census"), []byte("census")}
+
+	outputs, err := client.EvaluateBatch(inputs, infos, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the outputs are the evaluations of each input with its public information
+	server := oprf.NewPartialObliviousServer(suite, privateKey)
+
+	for index, input := range inputs {
+		output, err := server.FullEvaluate(input, infos[index])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(outputs[index], output) {
+			t.Errorf("output %d : %x, expected %x", index, outputs[index], output)
+		}
+	}
+
+	if _, err := client.EvaluateBatch(inputs, infos[:2], ""); err == nil {
+		t.Errorf("batch evaluated without an info per input")
+	}
+}
@@ -2,39 +2,98 @@ package core
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
-)
 
-const (
-	PublicKeysEndpoint = "/request_public_keys"
-	EvaluateEndpoint   = "/evaluate"
+	"github.com/ensimag-oprf/go/protocol"
 )
 
+// maxErrorSize bounds the error bodies read by the client
+const maxErrorSize = 1 << 16
+
 type HTTPClient struct {
 	client    *http.Client
 	serverURL string
+	// contentType is the encoding of the evaluation requests and responses
+	contentType string
+	// trustedCallerToken is the bearer token of the full evaluation endpoint
+	trustedCallerToken string
+	// verifierToken is the bearer token of the verification endpoint
+	verifierToken string
+	// signedKeySet is the last signed key set with its ETag, fetched again only if it changed
+	signedKeySet     *protocol.SignedKeySet
+	signedKeySetETag string
 }
 
 func NewHttpClient(serverURL string) *HTTPClient {
 	return &HTTPClient{
-		serverURL: serverURL,
-		client:    &http.Client{Timeout: 15 * time.Second},
+		serverURL:   serverURL,
+		client:      &http.Client{Timeout: 15 * time.Second},
+		contentType: protocol.ContentTypeJSON,
+	}
+}
+
+// SetContentType sets the encoding of the evaluation requests and responses : protocol.ContentTypeJSON,
+// protocol.ContentTypeCBOR or protocol.ContentTypeBinary.
+func (c *HTTPClient) SetContentType(contentType string) error {
+	if err := protocol.ValidateContentType(contentType); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	c.contentType = contentType
+
+	return nil
+}
+
+// GetPublicKeys returns the published public keys of each suite from the server
+func (c *HTTPClient) GetPublicKeys() (map[string][]protocol.PublicKeyInfo, error) {
+	var publicKeys map[string][]protocol.PublicKeyInfo
+	if err := c.getJSON(protocol.V2Path+protocol.PublicKeysEndpoint, &publicKeys); err != nil {
+		return nil, err
+	}
+
+	return publicKeys, nil
+}
+
+// GetCapabilities returns the suites, the modes, the encodings and the limits offered by the server. The servers
+// without the capabilities endpoint return a protocol.ErrNotFound error.
+func (c *HTTPClient) GetCapabilities() (*protocol.Capabilities, error) {
+	capabilities := new(protocol.Capabilities)
+	if err := c.getJSON(protocol.V2Path+protocol.CapabilitiesEndpoint, capabilities); err != nil {
+		return nil, err
 	}
+
+	return capabilities, nil
 }
 
-// GetPublicKeys returns the public keys from the server
-func (c *HTTPClient) GetPublicKeys() (map[string][]byte, error) {
-	req, err := http.NewRequest(http.MethodGet, c.serverURL+PublicKeysEndpoint, http.NoBody)
+// GetSignedKeySet returns the signed key set served at the root of the server. The previous key set is returned if
+// the server reports that it didn't change since, its signature must be checked again.
+func (c *HTTPClient) GetSignedKeySet() (*protocol.SignedKeySet, error) {
+	serverURL, err := url.Parse(c.serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL : %w", err)
+	}
+
+	wellKnownURL := serverURL.ResolveReference(&url.URL{Path: protocol.WellKnownKeysPath}) //nolint:exhaustivestruct
+
+	req, err := http.NewRequest(http.MethodGet, wellKnownURL.String(), http.NoBody)
 	if err != nil {
 		log.Println("HTTP NewRequest error :", err)
 
 		return nil, fmt.Errorf("HTTP NewRequest error : %w", err)
 	}
 
+	if c.signedKeySet != nil {
+		req.Header.Set("If-None-Match", c.signedKeySetETag)
+	}
+
 	resp, err := c.client.Do(req)
 	if err != nil {
 		log.Println("HTTP Do :", err)
@@ -43,35 +102,80 @@ func (c *HTTPClient) GetPublicKeys() (map[string][]byte, error) {
 	}
 	defer resp.Body.Close()
 
-	var publicKeys map[string][]byte
-	if err := json.NewDecoder(resp.Body).Decode(&publicKeys); err != nil {
+	if resp.StatusCode == http.StatusNotModified && c.signedKeySet != nil {
+		return c.signedKeySet, nil
+	}
+
+	if err := checkStatus(resp); err != nil {
+		log.Println("API error :", err)
+
+		return nil, err
+	}
+
+	signedKeySet := new(protocol.SignedKeySet)
+	if err := json.NewDecoder(resp.Body).Decode(signedKeySet); err != nil {
 		log.Println("JSON decoder error :", err)
 
 		return nil, fmt.Errorf("JSON decoder error : %w", err)
 	}
 
-	// log.Println(publicKeys, base64.StdEncoding.EncodeToString(publicKeys[common.OPRFP256]))
+	c.signedKeySet, c.signedKeySetETag = signedKeySet, resp.Header.Get("ETag")
 
-	return publicKeys, nil
+	return signedKeySet, nil
 }
 
-// EvaluateRequest evaluate an EvaluationRequest into an EvaluationResponse
-func (c *HTTPClient) EvaluateRequest(evaluationRequest *EvaluationRequest) (*EvaluationResponse, error) {
-	data, err := json.Marshal(&evaluationRequest)
+// GetSignedTreeHead returns the signed tree head of the transparency log of the published keys.
+func (c *HTTPClient) GetSignedTreeHead() (*protocol.SignedTreeHead, error) {
+	signedTreeHead := new(protocol.SignedTreeHead)
+	if err := c.getJSON(protocol.V2Path+protocol.TreeHeadEndpoint, signedTreeHead); err != nil {
+		return nil, err
+	}
+
+	return signedTreeHead, nil
+}
+
+// GetInclusionProof returns the inclusion proof of the leaf hash in the tree of treeSize leaves of the log.
+func (c *HTTPClient) GetInclusionProof(leafHash []byte, treeSize uint64) (*protocol.InclusionProof, error) {
+	query := url.Values{"leaf_hash": {hex.EncodeToString(leafHash)}, "tree_size": {strconv.FormatUint(treeSize, 10)}}
+
+	proof := new(protocol.InclusionProof)
+	if err := c.getJSON(protocol.V2Path+protocol.InclusionProofEndpoint+"?"+query.Encode(), proof); err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// GetConsistencyProof returns the consistency proof between the trees of firstSize and secondSize leaves of the log.
+func (c *HTTPClient) GetConsistencyProof(firstSize, secondSize uint64) (*protocol.ConsistencyProof, error) {
+	query := url.Values{"first": {strconv.FormatUint(firstSize, 10)}, "second": {strconv.FormatUint(secondSize, 10)}}
+
+	proof := new(protocol.ConsistencyProof)
+	if err := c.getJSON(protocol.V2Path+protocol.ConsistencyProofEndpoint+"?"+query.Encode(), proof); err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// EvaluateRequest evaluate an EvaluationRequest into an EvaluationResponse with the v2 API
+func (c *HTTPClient) EvaluateRequest(evaluationRequest *protocol.EvaluationRequest) (*EvaluationResponse, error) {
+	data, err := protocol.EncodeEvaluationRequest(c.contentType, evaluationRequest)
 	if err != nil {
 		log.Println("evaluation request marshalling error :", err)
 
 		return nil, fmt.Errorf("evaluation request marshalling error : %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, c.serverURL+EvaluateEndpoint, bytes.NewBuffer(data))
+	req, err := http.NewRequest(http.MethodPost, c.serverURL+protocol.V2Path+protocol.EvaluateEndpoint, bytes.NewBuffer(data))
 	if err != nil {
 		log.Println("HTTP NewRequest error :", err)
 
 		return nil, fmt.Errorf("HTTP NewRequest error : %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", c.contentType)
+	req.Header.Set("Accept", c.contentType)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -81,12 +185,150 @@ func (c *HTTPClient) EvaluateRequest(evaluationRequest *EvaluationRequest) (*Eva
 	}
 	defer resp.Body.Close()
 
+	if err := checkStatus(resp); err != nil {
+		log.Println("API error :", err)
+
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Println("HTTP read error :", err)
+
+		return nil, fmt.Errorf("HTTP read error : %w", err)
+	}
+
 	var evaluationResponse EvaluationResponse
-	if err = json.NewDecoder(resp.Body).Decode(&evaluationResponse); err != nil {
-		log.Println("JSON decoder error :", err)
+	if err = DecodeEvaluationResponse(resp.Header.Get("Content-Type"), body, &evaluationResponse); err != nil {
+		log.Println("evaluation response decoder error :", err)
 
-		return nil, fmt.Errorf("JSON decoder error : %w", err)
+		return nil, fmt.Errorf("evaluation response decoder error : %w", err)
 	}
 
 	return &evaluationResponse, nil
 }
+
+// SetTrustedCallerToken sets the bearer token of the full evaluation endpoint.
+func (c *HTTPClient) SetTrustedCallerToken(token string) {
+	c.trustedCallerToken = token
+}
+
+// FullEvaluate computes the PRF outputs of the inputs without blinding, on the endpoint of the trusted callers.
+// The request and the response are JSON encoded.
+func (c *HTTPClient) FullEvaluate(fullEvaluationRequest *protocol.FullEvaluationRequest,
+) (*protocol.FullEvaluationResponse, error) {
+	var fullEvaluationResponse protocol.FullEvaluationResponse
+	if err := c.postJSON(protocol.FullEvaluateEndpoint, c.trustedCallerToken, fullEvaluationRequest,
+		&fullEvaluationResponse); err != nil {
+		return nil, err
+	}
+
+	return &fullEvaluationResponse, nil
+}
+
+// SetVerifierToken sets the bearer token of the verification endpoint.
+func (c *HTTPClient) SetVerifierToken(token string) {
+	c.verifierToken = token
+}
+
+// Verify checks an output on the verification endpoint of the data stewards.
+// The request and the response are JSON encoded.
+func (c *HTTPClient) Verify(verificationRequest *protocol.VerificationRequest) (*protocol.VerificationResponse, error) {
+	var verificationResponse protocol.VerificationResponse
+	if err := c.postJSON(protocol.VerifyEndpoint, c.verifierToken, verificationRequest,
+		&verificationResponse); err != nil {
+		return nil, err
+	}
+
+	return &verificationResponse, nil
+}
+
+// postJSON posts the JSON request to the endpoint of the API root, authenticated with the bearer token, and decodes
+// the JSON response.
+func (c *HTTPClient) postJSON(endpoint, token string, request, response interface{}) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		log.Println("request marshalling error :", err)
+
+		return fmt.Errorf("request marshalling error : %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.serverURL+endpoint, bytes.NewBuffer(data))
+	if err != nil {
+		log.Println("HTTP NewRequest error :", err)
+
+		return fmt.Errorf("HTTP NewRequest error : %w", err)
+	}
+
+	req.Header.Set("Content-Type", protocol.ContentTypeJSON)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Println("HTTP Do :", err)
+
+		return fmt.Errorf("HTTP Do error : %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		log.Println("API error :", err)
+
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		log.Println("JSON decoder error :", err)
+
+		return fmt.Errorf("JSON decoder error : %w", err)
+	}
+
+	return nil
+}
+
+// getJSON decodes the JSON response of the endpoint of the API root.
+func (c *HTTPClient) getJSON(endpoint string, response interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.serverURL+endpoint, http.NoBody)
+	if err != nil {
+		log.Println("HTTP NewRequest error :", err)
+
+		return fmt.Errorf("HTTP NewRequest error : %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Println("HTTP Do :", err)
+
+		return fmt.Errorf("HTTP Do error : %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		log.Println("API error :", err)
+
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		log.Println("JSON decoder error :", err)
+
+		return fmt.Errorf("JSON decoder error : %w", err)
+	}
+
+	return nil
+}
+
+// checkStatus returns the protocol.Error of an error response, the errors are JSON encoded whatever the requested
+// encoding. The callers can check the error code with errors.Is, for instance errors.Is(err, protocol.ErrUnknownSuite).
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorSize))
+	if err != nil {
+		return fmt.Errorf("HTTP read error : %w", err)
+	}
+
+	return protocol.DecodeError(resp.StatusCode, body)
+}
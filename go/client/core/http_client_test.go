@@ -0,0 +1,163 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+func TestHTTPClientErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(protocol.V2Path+protocol.EvaluateEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", protocol.ContentTypeJSON)
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"code":"unknown_suite","message":"the suite \"P256-SHA256\" isn't served"}`))
+	})
+	mux.HandleFunc(protocol.V2Path+protocol.PublicKeysEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	httpClient := NewHttpClient(server.URL)
+
+	_, err := httpClient.EvaluateRequest(NewEvaluationRequest(oprf.SuiteP256, oprf.BaseMode, nil, nil))
+	if !errors.Is(err, protocol.ErrUnknownSuite) {
+		t.Errorf("%v, expected an unknown suite error", err)
+	}
+
+	var apiError *protocol.Error
+	if !errors.As(err, &apiError) || apiError.HTTPStatus() != http.StatusBadRequest {
+		t.Errorf("%v, expected a protocol.Error with the status 400", err)
+	}
+
+	// an error body without code has the code of its status
+	if _, err := httpClient.GetPublicKeys(); !errors.Is(err, protocol.ErrInternal) {
+		t.Errorf("%v, expected an internal error", err)
+	}
+}
+
+func TestHTTPClientFullEvaluate(t *testing.T) {
+	const token = "trusted"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(protocol.FullEvaluateEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, `{"code":"unauthorized","message":"invalid key"}`, http.StatusUnauthorized)
+
+			return
+		}
+
+		var fullEvaluationRequest protocol.FullEvaluationRequest
+		if err := json.NewDecoder(r.Body).Decode(&fullEvaluationRequest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		// the output of each input is its reverse
+		outputs := make([][]byte, len(fullEvaluationRequest.Inputs))
+		for index, input := range fullEvaluationRequest.Inputs {
+			for position := len(input) - 1; position >= 0; position-- {
+				outputs[index] = append(outputs[index], input[position])
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(&protocol.FullEvaluationResponse{
+			Suite:   fullEvaluationRequest.Suite,
+			KeyID:   testKeyID,
+			Outputs: outputs,
+			Info:    nil,
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	httpClient := NewHttpClient(server.URL)
+	fullEvaluationRequest := &protocol.FullEvaluationRequest{
+		Suite:  oprf.SuiteP256.Identifier(),
+		Mode:   protocol.ModeBase,
+		Info:   nil,
+		Inputs: [][]byte{{0x00, 0xFF}},
+		KeyID:  "",
+	}
+
+	if _, err := httpClient.FullEvaluate(fullEvaluationRequest); !errors.Is(err, protocol.ErrUnauthorized) {
+		t.Errorf("%v, expected an unauthorized error", err)
+	}
+
+	httpClient.SetTrustedCallerToken(token)
+
+	response, err := httpClient.FullEvaluate(fullEvaluationRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Outputs) != 1 || !bytes.Equal(response.Outputs[0], []byte{0xFF, 0x00}) {
+		t.Errorf("unexpected outputs %x", response.Outputs)
+	}
+}
+
+func TestHTTPClientVerify(t *testing.T) {
+	const token = "steward"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(protocol.VerifyEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, `{"code":"unauthorized","message":"invalid key"}`, http.StatusUnauthorized)
+
+			return
+		}
+
+		var verificationRequest protocol.VerificationRequest
+		if err := json.NewDecoder(r.Body).Decode(&verificationRequest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		// the output of the input is the input itself
+		_ = json.NewEncoder(w).Encode(&protocol.VerificationResponse{
+			Suite: verificationRequest.Suite,
+			KeyID: verificationRequest.KeyID,
+			Match: bytes.Equal(verificationRequest.Input, verificationRequest.Output),
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	httpClient := NewHttpClient(server.URL)
+	verificationRequest := &protocol.VerificationRequest{
+		Suite:  oprf.SuiteP256.Identifier(),
+		Mode:   protocol.ModeBase,
+		Info:   nil,
+		Input:  []byte("alice"),
+		Output: []byte("alice"),
+		KeyID:  "2021-12",
+	}
+
+	if _, err := httpClient.Verify(verificationRequest); !errors.Is(err, protocol.ErrUnauthorized) {
+		t.Errorf("%v, expected an unauthorized error", err)
+	}
+
+	httpClient.SetVerifierToken(token)
+
+	if response, err := httpClient.Verify(verificationRequest); err != nil || !response.Match ||
+		response.KeyID != "2021-12" {
+		t.Errorf("unexpected response %v (%v)", response, err)
+	}
+
+	verificationRequest.Output = []byte("bob")
+	if response, err := httpClient.Verify(verificationRequest); err != nil || response.Match {
+		t.Errorf("unexpected response %v (%v)", response, err)
+	}
+}
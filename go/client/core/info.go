@@ -0,0 +1,79 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+var ErrInfoMismatch = errors.New("the evaluated info doesn't match the sent info")
+
+// InfoBuilder builds the structured public information of the partially oblivious mode, required by the servers
+// with a structured info policy. The server-side fields, such as the tenant, are injected by the server.
+// For instance :
+// info, err := core.NewInfoBuilder().Application("survey").Purpose("statistics").Epoch(2022).Build()
+type InfoBuilder struct {
+	info protocol.StructuredInfo
+}
+
+func NewInfoBuilder() *InfoBuilder {
+	return &InfoBuilder{info: make(protocol.StructuredInfo)}
+}
+
+func (b *InfoBuilder) Application(application string) *InfoBuilder {
+	b.info[protocol.InfoFieldApplication] = []byte(application)
+
+	return b
+}
+
+func (b *InfoBuilder) Purpose(purpose string) *InfoBuilder {
+	b.info[protocol.InfoFieldPurpose] = []byte(purpose)
+
+	return b
+}
+
+// Epoch separates the pseudonyms of each period, for instance the pseudonyms of a year.
+func (b *InfoBuilder) Epoch(epoch uint64) *InfoBuilder {
+	b.info[protocol.InfoFieldEpoch] = protocol.EpochValue(epoch)
+
+	return b
+}
+
+func (b *InfoBuilder) Recipient(recipient string) *InfoBuilder {
+	b.info[protocol.InfoFieldRecipient] = []byte(recipient)
+
+	return b
+}
+
+// Build encodes the public information.
+func (b *InfoBuilder) Build() ([]byte, error) {
+	return b.info.MarshalBinary() //nolint:wrapcheck
+}
+
+// EvaluatedInfo returns the public information evaluated by the server : the sent information, or the information of
+// the response if the server injected fields. The server can only add fields to the sent structured information.
+func EvaluatedInfo(info, responseInfo []byte) ([]byte, error) {
+	if len(responseInfo) == 0 {
+		return info, nil
+	}
+
+	sentInfo, err := protocol.ParseStructuredInfo(info)
+	if err != nil {
+		return nil, fmt.Errorf("%w : the sent info isn't structured", ErrInfoMismatch)
+	}
+
+	evaluatedInfo, err := protocol.ParseStructuredInfo(responseInfo)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInfoMismatch, err.Error())
+	}
+
+	for field, value := range sentInfo {
+		if !bytes.Equal(evaluatedInfo[field], value) {
+			return nil, fmt.Errorf("%w : the %s field changed", ErrInfoMismatch, field)
+		}
+	}
+
+	return responseInfo, nil
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+func TestInfoBuilder(t *testing.T) {
+	info, err := NewInfoBuilder().Application("survey").Purpose("statistics").Epoch(2022).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	structuredInfo, err := protocol.ParseStructuredInfo(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if epoch, _ := structuredInfo.Epoch(); string(structuredInfo[protocol.InfoFieldApplication]) != "survey" ||
+		string(structuredInfo[protocol.InfoFieldPurpose]) != "statistics" || epoch != 2022 {
+		t.Errorf("built info %v", structuredInfo)
+	}
+
+	if _, err := NewInfoBuilder().Build(); !errors.Is(err, protocol.ErrInvalidStructuredInfo) {
+		t.Errorf("empty info built : %v", err)
+	}
+
+	// the server can add fields
+	structuredInfo[protocol.InfoFieldTenant] = []byte("ensimag")
+	injectedInfo, _ := structuredInfo.MarshalBinary()
+
+	evaluatedInfo, err := EvaluatedInfo(info, injectedInfo)
+	if err != nil || string(evaluatedInfo) != string(injectedInfo) {
+		t.Errorf("evaluated info %x (%v), expected the injected info", evaluatedInfo, err)
+	}
+
+	if evaluatedInfo, err := EvaluatedInfo(info, nil); err != nil || string(evaluatedInfo) != string(info) {
+		t.Errorf("evaluated info %x (%v), expected the sent info", evaluatedInfo, err)
+	}
+
+	// but it can't change them
+	structuredInfo[protocol.InfoFieldPurpose] = []byte("marketing")
+	changedInfo, _ := structuredInfo.MarshalBinary()
+
+	if _, err := EvaluatedInfo(info, changedInfo); !errors.Is(err, ErrInfoMismatch) {
+		t.Errorf("changed info accepted : %v", err)
+	}
+}
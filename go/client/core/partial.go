@@ -0,0 +1,136 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+var (
+	ErrPartialFailure = errors.New("some inputs weren't evaluated")
+	ErrStatusMismatch = errors.New("the statuses don't match the request")
+)
+
+// ElementFailure is an input of a partial-success batch which wasn't evaluated
+type ElementFailure struct {
+	Index int
+	Err   *protocol.Error
+}
+
+// BatchError reports the inputs of a partial-success batch which weren't evaluated, in the order of the inputs.
+// It wraps ErrPartialFailure.
+type BatchError struct {
+	Failures []ElementFailure
+	// Count is the number of inputs of the batch
+	Count int
+}
+
+// newBatchError returns the failures of the statuses, nil if all the elements were evaluated.
+func newBatchError(statuses []protocol.ElementStatus) *BatchError {
+	var failures []ElementFailure
+
+	for index, status := range statuses {
+		if status.Evaluated {
+			continue
+		}
+
+		err := status.Error
+		if err == nil {
+			err = protocol.NewError(protocol.CodeInvalidElement, "element %d wasn't evaluated", index)
+		}
+
+		failures = append(failures, ElementFailure{Index: index, Err: err})
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return &BatchError{Failures: failures, Count: len(statuses)}
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%s : %d of %d inputs, input %d : %s", ErrPartialFailure, len(e.Failures), e.Count,
+		e.Failures[0].Index, e.Failures[0].Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return ErrPartialFailure
+}
+
+// SetPartialSuccess sends the batches of EvaluateBatch as partial-success batches, whose valid elements are evaluated
+// even if other elements are rejected.
+func (c *Client) SetPartialSuccess(partialSuccess bool) {
+	c.partialSuccess = partialSuccess
+}
+
+// FinalizePartial finalizes the response of a partial-success batch of the inputs. The outputs are in the order of
+// the inputs, the outputs of the inputs which weren't evaluated are nil and reported by a *BatchError, returned with
+// the outputs. The evaluated inputs are blinded again with their blinds to verify the proof, which only covers them.
+func (c *Client) FinalizePartial(inputs [][]byte, finalizeData *oprf.FinalizeData,
+	evaluationResponse *EvaluationResponse, info []byte,
+) ([][]byte, error) {
+	statuses := evaluationResponse.Statuses
+	if len(statuses) == 0 {
+		return c.Finalize(finalizeData, evaluationResponse, info)
+	}
+
+	if len(statuses) != len(inputs) {
+		return nil, fmt.Errorf("%w : %d statuses for %d inputs", ErrStatusMismatch, len(statuses), len(inputs))
+	}
+
+	batchError := newBatchError(statuses)
+	outputs := make([][]byte, len(inputs))
+
+	if batchError != nil && len(batchError.Failures) == len(inputs) {
+		return outputs, batchError
+	}
+
+	evaluatedResponse := *evaluationResponse
+	evaluatedResponse.Statuses = nil
+
+	if batchError == nil {
+		evaluatedOutputs, err := c.Finalize(finalizeData, &evaluatedResponse, info)
+		if err != nil {
+			return nil, err
+		}
+
+		return evaluatedOutputs, nil
+	}
+
+	var (
+		blinds          = finalizeData.CopyBlinds()
+		evaluatedInputs [][]byte
+		evaluatedBlinds []oprf.Blind
+		indices         []int
+	)
+
+	for index, status := range statuses {
+		if status.Evaluated {
+			evaluatedInputs = append(evaluatedInputs, inputs[index])
+			evaluatedBlinds = append(evaluatedBlinds, blinds[index])
+			indices = append(indices, index)
+		}
+	}
+
+	evaluatedData, _, err := c.DeterministicBlind(evaluatedInputs, evaluatedBlinds)
+	if err != nil {
+		log.Println("Finalize error :", err)
+
+		return nil, fmt.Errorf("finalize error : %w", err)
+	}
+
+	evaluatedOutputs, err := c.Finalize(evaluatedData, &evaluatedResponse, info)
+	if err != nil {
+		return nil, err
+	}
+
+	for evaluatedIndex, index := range indices {
+		outputs[index] = evaluatedOutputs[evaluatedIndex]
+	}
+
+	return outputs, batchError
+}
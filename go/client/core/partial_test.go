@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+func TestFinalizePartial(t *testing.T) {
+	suite := oprf.SuiteP256
+
+	privateKey, err := oprf.GenerateKey(suite, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluator := newTestGroupEvaluator(t, suite, privateKey)
+	defer evaluator.Close()
+
+	client, err := NewClient(evaluator.URL, suite, oprf.PartialObliviousMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := oprf.NewPartialObliviousServer(suite, privateKey)
+
+	inputs := [][]byte{[]byte("alice"), []byte("bob"), []byte("carol")}
+	info := []byte("survey")
+
+	// the server only evaluates the first and the last elements, with a proof covering them
+	finalizeData, evaluationRequest, err := client.Blind(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluation, err := server.Evaluate(&oprf.EvaluationRequest{
+		Elements: []oprf.Blinded{evaluationRequest.Elements[0], evaluationRequest.Elements[2]},
+	}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluationResponse := &EvaluationResponse{ //nolint:exhaustivestruct
+		Evaluation: evaluation,
+		Suite:      suite.Identifier(),
+		KeyID:      testKeyID,
+		Statuses: []protocol.ElementStatus{
+			{Evaluated: true, Error: nil},
+			{Evaluated: false, Error: protocol.NewError(protocol.CodeInvalidElement, "invalid element")},
+			{Evaluated: true, Error: nil},
+		},
+	}
+
+	if _, err := client.Finalize(finalizeData, evaluationResponse, info); !errors.Is(err, ErrPartialFailure) {
+		t.Errorf("partial response finalized : %v", err)
+	}
+
+	outputs, err := client.FinalizePartial(inputs, finalizeData, evaluationResponse, info)
+
+	var batchError *BatchError
+	if !errors.As(err, &batchError) || len(batchError.Failures) != 1 || batchError.Failures[0].Index != 1 ||
+		!errors.Is(batchError.Failures[0].Err, protocol.ErrInvalidElement) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for index, input := range inputs {
+		var expected []byte
+		if index != 1 {
+			expected, _ = server.FullEvaluate(input, info)
+		}
+
+		if !bytes.Equal(outputs[index], expected) {
+			t.Errorf("output %d : %x, expected %x", index, outputs[index], expected)
+		}
+	}
+
+	// the inputs of the rejected groups of a batch have no output
+	client.SetPartialSuccess(true)
+
+	infos := [][]byte{[]byte("survey"), []byte("rejected"), []byte("census")}
+
+	outputs, err = client.EvaluateBatch(inputs, infos, "")
+	if !errors.As(err, &batchError) || len(batchError.Failures) != 1 || batchError.Failures[0].Index != 1 ||
+		!errors.Is(batchError.Failures[0].Err, protocol.ErrInfoRejected) {
+		t.Fatalf("unexpected batch error %v", err)
+	}
+
+	for index, input := range inputs {
+		var expected []byte
+		if index != 1 {
+			expected, _ = server.FullEvaluate(input, infos[index])
+		}
+
+		if !bytes.Equal(outputs[index], expected) {
+			t.Errorf("batch output %d : %x, expected %x", index, outputs[index], expected)
+		}
+	}
+
+	// the group without its invalid elements is finalized with the evaluated elements
+	infos = [][]byte{[]byte("truncated"), []byte("survey"), []byte("truncated")}
+
+	outputs, err = client.EvaluateBatch(inputs, infos, "")
+	if !errors.As(err, &batchError) || len(batchError.Failures) != 1 || batchError.Failures[0].Index != 0 ||
+		!errors.Is(batchError.Failures[0].Err, protocol.ErrInvalidElement) {
+		t.Fatalf("unexpected batch error %v", err)
+	}
+
+	for index, input := range inputs {
+		var expected []byte
+		if index != 0 {
+			expected, _ = server.FullEvaluate(input, infos[index])
+		}
+
+		if !bytes.Equal(outputs[index], expected) {
+			t.Errorf("truncated batch output %d : %x, expected %x", index, outputs[index], expected)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+func TestNewClientWithRootKey(t *testing.T) {
+	suite := oprf.SuiteP256
+
+	rootPublicKey, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingPublicKey, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey, err := oprf.GenerateKey(suite, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := privateKey.Public().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedKeySetKey := protocol.KeySetKey{
+		Suite:         suite.Identifier(),
+		PublicKeyInfo: protocol.PublicKeyInfo{ID: testKeyID, State: protocol.KeyStateActive, PublicKey: publicKey},
+	}
+
+	now := time.Now()
+	delegation := protocol.SignDelegation(rootKey, signingPublicKey, now.Add(-time.Hour), now.Add(time.Hour))
+
+	signedKeySet, err := protocol.SignKeySet(signingKey, delegation, &protocol.KeySetDocument{
+		IssuedAt:  now,
+		ExpiresAt: now.Add(time.Hour),
+		Keys:      []protocol.KeySetKey{signedKeySetKey},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notModified := 0
+
+	// the unsigned keys endpoint and the transparency log aren't used
+	mux := http.NewServeMux()
+	mux.HandleFunc(protocol.WellKnownKeysPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		_ = json.NewEncoder(w).Encode(signedKeySet)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClientWithRootKey(server.URL+"/api", suite, oprf.BaseMode, rootPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key set cached with its ETag is checked again
+	if err := client.refreshPublicKeys(); err != nil || notModified != 1 {
+		t.Errorf("the key set isn't revalidated with its ETag : %v", err)
+	}
+
+	activeKey, err := client.PublicKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if serializedKey, _ := activeKey.MarshalBinary(); !bytes.Equal(serializedKey, publicKey) {
+		t.Error("the active key isn't the signed key")
+	}
+
+	otherRootKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewClientWithRootKey(server.URL+"/api", suite, oprf.BaseMode, otherRootKey)
+	if !errors.Is(err, protocol.ErrInvalidSignature) {
+		t.Errorf("the key set is trusted with another root key : %v", err)
+	}
+}
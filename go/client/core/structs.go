@@ -1,92 +1,128 @@
 package core
 
 import (
-	"encoding/json"
+	"fmt"
 
 	"github.com/cloudflare/circl/group"
 	"github.com/cloudflare/circl/oprf"
 	"github.com/cloudflare/circl/zk/dleq"
+	"github.com/ensimag-oprf/go/protocol"
 )
 
-// EvaluationRequest represents an evaluation requests
-type EvaluationRequest struct {
-	Suite           string    `json:"suite"`
-	Info            string    `json:"info"`
-	BlindedElements [][]byte  `json:"blinded_elements"`
-	Mode            oprf.Mode `json:"mode"`
-}
-
-func NewEvaluationRequest(suite oprf.Suite, mode oprf.Mode, info string,
+// NewEvaluationRequest returns the v2 request of the evaluation of the blinded elements, with the named mode.
+func NewEvaluationRequest(suite oprf.Suite, mode oprf.Mode, info []byte,
 	blindedElements [][]byte,
-) *EvaluationRequest {
-	return &EvaluationRequest{
+) *protocol.EvaluationRequest {
+	return &protocol.EvaluationRequest{
 		Suite:           suite.Identifier(),
-		Mode:            mode,
+		Mode:            protocol.NewMode(mode),
 		Info:            info,
 		BlindedElements: blindedElements,
 	}
 }
 
-// WrappedEvaluationResponse allows to partially parse the JSON input
-type WrappedEvaluationResponse struct {
-	Evaluation          *WrappedEvaluation `json:"evaluation"`
-	Suite               string             `json:"suite"`
-	SerializedPublicKey []byte             `json:"serialized_public_key"`
-}
-
-type WrappedEvaluation struct {
-	Proof    []byte   `json:"proof"`
-	Elements [][]byte `json:"elements"`
-}
-
-// EvaluationResponse contains the oprf.Evaluation and the serialized public key that will be used for the
-// finalization.
+// EvaluationResponse contains the oprf.Evaluation, the key ID and the serialized public key that will be used
+// for the finalization.
 type EvaluationResponse struct {
 	Evaluation          *oprf.Evaluation `json:"evaluation"`
 	Suite               string           `json:"suite"`
+	KeyID               string           `json:"kid"`
 	SerializedPublicKey []byte           `json:"serialized_public_key"`
+	// ShareIndex is the index of the key share if the evaluation is partial
+	ShareIndex uint16 `json:"share_index,omitempty"`
+	// Info is the evaluated public information if the server injected fields
+	Info []byte `json:"info,omitempty"`
+	// Groups are the evaluations of a batch with a public information per element
+	Groups []EvaluationGroup `json:"groups,omitempty"`
+	// Statuses are the statuses of each element of a partial-success batch
+	Statuses []protocol.ElementStatus `json:"statuses,omitempty"`
+}
+
+// EvaluationGroup is the evaluation of the elements of a batch sharing a public information
+type EvaluationGroup struct {
+	// Indices are the positions of the elements in the request
+	Indices    []uint16         `json:"indices"`
+	Evaluation *oprf.Evaluation `json:"evaluation"`
+	Info       []byte           `json:"info,omitempty"`
 }
 
-// UnmarshalJSON first parse the JSON input into a WrappedEvaluationResponse
-// and then convert the string into a byte array into EvaluationResponse.
+// UnmarshalJSON first parse the JSON input into a protocol.EvaluationResponse
+// and then deserializes the proof and the elements.
 func (r *EvaluationResponse) UnmarshalJSON(data []byte) error {
-	var wr WrappedEvaluationResponse
-	if err := json.Unmarshal(data, &wr); err != nil {
-		return err
+	return DecodeEvaluationResponse(protocol.ContentTypeJSON, data, r)
+}
+
+// DecodeEvaluationResponse decodes the response with the encoding of its content type, JSON by default.
+func DecodeEvaluationResponse(contentType string, data []byte, evaluationResponse *EvaluationResponse) error {
+	var wr protocol.EvaluationResponse
+	if err := protocol.DecodeEvaluationResponse(contentType, data, &wr); err != nil {
+		return err //nolint:wrapcheck
 	}
 
+	return evaluationResponse.unwrap(&wr)
+}
+
+// unwrap deserializes the proof and the elements of a protocol.EvaluationResponse.
+func (r *EvaluationResponse) unwrap(wr *protocol.EvaluationResponse) error {
+	r.Suite = wr.Suite
+	r.KeyID = wr.KeyID
 	r.SerializedPublicKey = wr.SerializedPublicKey
-	r.Evaluation = &oprf.Evaluation{}
+	r.ShareIndex = wr.ShareIndex
+	r.Info = wr.Info
+	r.Statuses = wr.Statuses
+	r.Evaluation, r.Groups = nil, nil
 
 	suite, err := oprf.GetSuite(wr.Suite)
 	if err != nil {
 		return err
 	}
 
-	suiteGroup := suite.Group()
+	if wr.Evaluation != nil {
+		if r.Evaluation, err = unwrapEvaluation(suite.Group(), wr.Evaluation); err != nil {
+			return err
+		}
+	}
 
-	var proof *dleq.Proof
-	if len(wr.Evaluation.Proof) > 0 {
-		proof = &dleq.Proof{}
-		if err := proof.UnmarshalBinary(suiteGroup, wr.Evaluation.Proof); err != nil {
+	for _, wrappedGroup := range wr.Groups {
+		evaluation, err := unwrapEvaluation(suite.Group(), wrappedGroup.Evaluation)
+		if err != nil {
 			return err
 		}
+
+		r.Groups = append(r.Groups, EvaluationGroup{
+			Indices:    wrappedGroup.Indices,
+			Evaluation: evaluation,
+			Info:       wrappedGroup.Info,
+		})
 	}
 
-	r.Evaluation.Proof = proof
+	return nil
+}
 
-	elements := make([]group.Element, len(wr.Evaluation.Elements))
+// unwrapEvaluation deserializes the proof and the elements of a protocol.Evaluation.
+func unwrapEvaluation(suiteGroup group.Group, wrappedEvaluation *protocol.Evaluation) (*oprf.Evaluation, error) {
+	if wrappedEvaluation == nil {
+		return nil, fmt.Errorf("%w : the group has no evaluation", protocol.ErrInvalidEncoding)
+	}
 
-	for index, data := range wr.Evaluation.Elements {
+	var proof *dleq.Proof
+	if len(wrappedEvaluation.Proof) > 0 {
+		proof = &dleq.Proof{}
+		if err := proof.UnmarshalBinary(suiteGroup, wrappedEvaluation.Proof); err != nil {
+			return nil, err
+		}
+	}
+
+	elements := make([]group.Element, len(wrappedEvaluation.Elements))
+
+	for index, data := range wrappedEvaluation.Elements {
 		element := suiteGroup.NewElement()
 		if err := element.UnmarshalBinary(data); err != nil {
-			return err
+			return nil, err
 		}
 
 		elements[index] = element
 	}
 
-	r.Evaluation.Elements = elements
-
-	return nil
+	return &oprf.Evaluation{Elements: elements, Proof: proof}, nil
 }
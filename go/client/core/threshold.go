@@ -0,0 +1,336 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/cloudflare/circl/group"
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+var (
+	ErrThresholdMode         = errors.New("the partially oblivious mode is not supported with key shares")
+	ErrInconsistentShares    = errors.New("the evaluators' key shares are inconsistent")
+	ErrNotEnoughEvaluations  = errors.New("not enough partial evaluations")
+	ErrInvalidShareResponses = errors.New("invalid partial evaluations")
+)
+
+// ThresholdClient evaluates the blinded elements on several evaluators holding Shamir shares of the server key
+// and combines a threshold of partial evaluations. The output is the output of the unsplit key.
+type ThresholdClient struct {
+	httpClients []*HTTPClient
+	oprfClient  OprfClientInterface
+	suite       oprf.Suite
+	mode        oprf.Mode
+	keyID       string
+	threshold   uint16
+	// share index:public key of the share
+	sharePublicKeys map[uint16]*oprf.PublicKey
+	groupPublicKey  *oprf.PublicKey
+}
+
+// NewThresholdClient returns a client of the threshold evaluators with the provided server URLs, suite and mode.
+// The active key shares of the evaluators must be shares of the same key.
+func NewThresholdClient(serverURLs []string, suite oprf.Suite, mode oprf.Mode) (*ThresholdClient, error) {
+	if mode == oprf.PartialObliviousMode {
+		return nil, ErrThresholdMode
+	}
+
+	client := &ThresholdClient{
+		suite:           suite,
+		mode:            mode,
+		sharePublicKeys: make(map[uint16]*oprf.PublicKey),
+	}
+
+	for _, serverURL := range serverURLs {
+		client.httpClients = append(client.httpClients, NewHttpClient(serverURL))
+	}
+
+	if err := client.setupShares(); err != nil {
+		return nil, err
+	}
+
+	client.oprfClient = NewOPRFClient(suite, mode, client.groupPublicKey)
+
+	return client, nil
+}
+
+// setupShares retrieve the active key share of each evaluator and check that they share the same key.
+func (c *ThresholdClient) setupShares() error {
+	suiteID := c.suite.Identifier()
+
+	for _, httpClient := range c.httpClients {
+		serializedPublicKeys, err := httpClient.GetPublicKeys()
+		if err != nil {
+			return err
+		}
+
+		var share *protocol.PublicKeyInfo
+
+		for index, publicKeyInfo := range serializedPublicKeys[suiteID] {
+			if publicKeyInfo.State == protocol.KeyStateActive && publicKeyInfo.Share != nil {
+				share = &serializedPublicKeys[suiteID][index]
+			}
+		}
+
+		if share == nil {
+			return fmt.Errorf("%w : %s has no active key share for the suite %s",
+				ErrInconsistentShares, httpClient.serverURL, suiteID)
+		}
+
+		if err := c.addShare(share); err != nil {
+			return err
+		}
+	}
+
+	if len(c.sharePublicKeys) < int(c.threshold) {
+		return fmt.Errorf("%w : %d evaluators for a threshold of %d", ErrNotEnoughEvaluations,
+			len(c.sharePublicKeys), c.threshold)
+	}
+
+	return c.verifyShares()
+}
+
+func (c *ThresholdClient) addShare(share *protocol.PublicKeyInfo) error {
+	if c.groupPublicKey == nil {
+		groupPublicKey, err := DeserializePublicKey(c.suite, share.Share.GroupPublicKey)
+		if err != nil {
+			return err
+		}
+
+		c.groupPublicKey = groupPublicKey
+		c.keyID = share.ID
+		c.threshold = share.Share.Threshold
+	}
+
+	serializedGroupPublicKey, err := c.groupPublicKey.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if share.ID != c.keyID || share.Share.Threshold != c.threshold ||
+		string(share.Share.GroupPublicKey) != string(serializedGroupPublicKey) {
+		return fmt.Errorf("%w : the share %d is not a share of the key %s", ErrInconsistentShares,
+			share.Share.Index, c.keyID)
+	}
+
+	if share.Share.Index == 0 {
+		return fmt.Errorf("%w : the share 0 would be the key", ErrInconsistentShares)
+	}
+
+	if _, ok := c.sharePublicKeys[share.Share.Index]; ok {
+		return fmt.Errorf("%w : duplicate share %d", ErrInconsistentShares, share.Share.Index)
+	}
+
+	publicKey, err := DeserializePublicKey(c.suite, share.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	c.sharePublicKeys[share.Share.Index] = publicKey
+
+	return nil
+}
+
+// verifyShares checks that every share public key is on the same polynomial as the group public key : the
+// threshold first shares combine into the group public key, and they interpolate the public key of every other share.
+func (c *ThresholdClient) verifyShares() error {
+	suiteGroup := c.suite.Group()
+
+	indices := make([]uint16, 0, len(c.sharePublicKeys))
+	for index := range c.sharePublicKeys {
+		indices = append(indices, index)
+	}
+
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	shareElements := make([]group.Element, len(indices))
+
+	for position, index := range indices {
+		element, err := publicKeyElement(c.suite, c.sharePublicKeys[index])
+		if err != nil {
+			return err
+		}
+
+		shareElements[position] = element
+	}
+
+	groupElement, err := publicKeyElement(c.suite, c.groupPublicKey)
+	if err != nil {
+		return err
+	}
+
+	baseIndices, baseElements := indices[:c.threshold], shareElements[:c.threshold]
+	if !interpolateShares(suiteGroup, 0, baseIndices, baseElements).IsEqual(groupElement) {
+		return fmt.Errorf("%w : the share public keys don't combine into the key %s", ErrInconsistentShares, c.keyID)
+	}
+
+	for position, index := range indices[c.threshold:] {
+		if !interpolateShares(suiteGroup, index, baseIndices, baseElements).IsEqual(
+			shareElements[int(c.threshold)+position]) {
+			return fmt.Errorf("%w : the share %d isn't a share of the key %s", ErrInconsistentShares, index, c.keyID)
+		}
+	}
+
+	return nil
+}
+
+// Blind generates a request for the evaluators by passing an array of inputs to be evaluated.
+func (c *ThresholdClient) Blind(inputs [][]byte) (*oprf.FinalizeData, *oprf.EvaluationRequest, error) {
+	return c.oprfClient.Blind(inputs)
+}
+
+// EvaluateRequest sends the EvaluationRequest to every evaluator and returns the threshold first partial evaluations
+// in the evaluators order. A copy of the request is evaluated with the shared key.
+func (c *ThresholdClient) EvaluateRequest(evaluationRequest *protocol.EvaluationRequest) ([]*EvaluationResponse, error) {
+	sharedKeyRequest := *evaluationRequest
+	sharedKeyRequest.KeyID = c.keyID
+
+	responses := make([]*EvaluationResponse, len(c.httpClients))
+
+	var wg sync.WaitGroup
+
+	for index, httpClient := range c.httpClients {
+		wg.Add(1)
+
+		go func(index int, httpClient *HTTPClient) {
+			defer wg.Done()
+
+			response, err := httpClient.EvaluateRequest(&sharedKeyRequest)
+			if err != nil {
+				log.Println("partial evaluation error :", httpClient.serverURL, err)
+
+				return
+			}
+
+			responses[index] = response
+		}(index, httpClient)
+	}
+
+	wg.Wait()
+
+	partialEvaluations := make([]*EvaluationResponse, 0, c.threshold)
+
+	for _, response := range responses {
+		if response != nil && len(partialEvaluations) < int(c.threshold) {
+			partialEvaluations = append(partialEvaluations, response)
+		}
+	}
+
+	if len(partialEvaluations) < int(c.threshold) {
+		return nil, fmt.Errorf("%w : %d of %d", ErrNotEnoughEvaluations, len(partialEvaluations), c.threshold)
+	}
+
+	return partialEvaluations, nil
+}
+
+// Finalize verifies the proof of each partial evaluation in verifiable mode, combines the partial evaluations
+// with the Lagrange coefficients of their share and returns the output of the OPRF protocol.
+func (c *ThresholdClient) Finalize(finalizeData *oprf.FinalizeData,
+	partialEvaluations []*EvaluationResponse,
+) ([][]byte, error) {
+	if len(partialEvaluations) < int(c.threshold) {
+		return nil, fmt.Errorf("%w : %d of %d", ErrNotEnoughEvaluations, len(partialEvaluations), c.threshold)
+	}
+
+	partialEvaluations = partialEvaluations[:c.threshold]
+	indices := make([]uint16, len(partialEvaluations))
+
+	for position, partialEvaluation := range partialEvaluations {
+		publicKey, ok := c.sharePublicKeys[partialEvaluation.ShareIndex]
+		if !ok || partialEvaluation.KeyID != c.keyID {
+			return nil, fmt.Errorf("%w : unknown share %d of the key %q", ErrInvalidShareResponses,
+				partialEvaluation.ShareIndex, partialEvaluation.KeyID)
+		}
+
+		for _, index := range indices[:position] {
+			if index == partialEvaluation.ShareIndex {
+				return nil, fmt.Errorf("%w : duplicate share %d", ErrInvalidShareResponses, index)
+			}
+		}
+
+		indices[position] = partialEvaluation.ShareIndex
+
+		// the proof of a partial evaluation is verified against the public key of the share
+		if c.mode == oprf.VerifiableMode {
+			verifiableClient := OprfVerifiableClient{oprf.NewVerifiableClient(c.suite, publicKey)}
+			if _, err := verifiableClient.Finalize(finalizeData, partialEvaluation.Evaluation, nil); err != nil {
+				return nil, fmt.Errorf("partial evaluation %d : %w", partialEvaluation.ShareIndex, err)
+			}
+		}
+	}
+
+	evaluation, err := c.combineEvaluations(indices, partialEvaluations)
+	if err != nil {
+		return nil, err
+	}
+
+	// Once the proofs are verified, the base and verifiable finalizations are identical
+	clientOutputs, err := OprfBaseClient{oprf.NewClient(c.suite)}.Finalize(finalizeData, evaluation, nil)
+	if err != nil || clientOutputs == nil {
+		log.Println("Finalize error :", err, clientOutputs)
+
+		return nil, fmt.Errorf("finalize error : %w", err)
+	}
+
+	return clientOutputs, nil
+}
+
+func (c *ThresholdClient) combineEvaluations(indices []uint16,
+	partialEvaluations []*EvaluationResponse,
+) (*oprf.Evaluation, error) {
+	size := len(partialEvaluations[0].Evaluation.Elements)
+	elements := make([]group.Element, size)
+
+	for position := range elements {
+		partialElements := make([]group.Element, len(partialEvaluations))
+
+		for index, partialEvaluation := range partialEvaluations {
+			if len(partialEvaluation.Evaluation.Elements) != size {
+				return nil, fmt.Errorf("%w : the partial evaluations sizes differ", ErrInvalidShareResponses)
+			}
+
+			partialElements[index] = partialEvaluation.Evaluation.Elements[position]
+		}
+
+		elements[position] = combineShares(c.suite.Group(), indices, partialElements)
+	}
+
+	return &oprf.Evaluation{Elements: elements}, nil
+}
+
+// combineShares interpolates at x = 0 the elements of the shares with the provided indices.
+func combineShares(suiteGroup group.Group, indices []uint16, elements []group.Element) group.Element {
+	return interpolateShares(suiteGroup, 0, indices, elements)
+}
+
+// interpolateShares interpolates at x the elements of the shares with the provided indices.
+func interpolateShares(suiteGroup group.Group, x uint16, indices []uint16, elements []group.Element) group.Element {
+	interpolated := suiteGroup.Identity()
+
+	for position, index := range indices {
+		coefficient := protocol.LagrangeCoefficientAt(suiteGroup, x, index, indices)
+		interpolated.Add(interpolated, suiteGroup.NewElement().Mul(elements[position], coefficient))
+	}
+
+	return interpolated
+}
+
+// publicKeyElement returns the group element of a public key.
+func publicKeyElement(suite oprf.Suite, publicKey *oprf.PublicKey) (group.Element, error) {
+	serializedPublicKey, err := publicKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	element := suite.Group().NewElement()
+	if err := element.UnmarshalBinary(serializedPublicKey); err != nil {
+		return nil, err
+	}
+
+	return element, nil
+}
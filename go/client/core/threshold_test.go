@@ -0,0 +1,177 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/circl/group"
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+const testKeyID = "threshold"
+
+// newTestEvaluator returns an in-process evaluator holding the share of index of the group key.
+func newTestEvaluator(t *testing.T, suite oprf.Suite, share *oprf.PrivateKey, shareInfo *protocol.KeyShareInfo) *httptest.Server {
+	t.Helper()
+
+	publicKey, err := share.Public().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(protocol.V2Path+protocol.PublicKeysEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string][]protocol.PublicKeyInfo{
+			suite.Identifier(): {{ID: testKeyID, State: protocol.KeyStateActive, PublicKey: publicKey, Share: shareInfo}},
+		})
+	})
+	mux.HandleFunc(protocol.V2Path+protocol.EvaluateEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		var evaluationRequest protocol.EvaluationRequest
+		if err := json.NewDecoder(r.Body).Decode(&evaluationRequest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		elements := make([]group.Element, len(evaluationRequest.BlindedElements))
+		for index, blindedElement := range evaluationRequest.BlindedElements {
+			elements[index] = suite.Group().NewElement()
+			if err := elements[index].UnmarshalBinary(blindedElement); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+		}
+
+		evaluation, err := oprf.NewVerifiableServer(suite, share).Evaluate(&oprf.EvaluationRequest{Elements: elements})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		evaluatedElements, _ := SerializeElements(evaluation.Elements)
+		proof, _ := evaluation.Proof.MarshalBinary()
+
+		_ = json.NewEncoder(w).Encode(protocol.EvaluationResponse{
+			Evaluation: &protocol.Evaluation{Proof: proof, Elements: evaluatedElements},
+			Suite:      suite.Identifier(),
+			KeyID:      testKeyID,
+			ShareIndex: shareInfo.Index,
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestThresholdClient(t *testing.T) {
+	suite := oprf.SuiteP256
+	suiteGroup := suite.Group()
+
+	privateKey, err := oprf.GenerateKey(suite, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groupPublicKey, _ := privateKey.Public().MarshalBinary()
+	serializedKey, _ := privateKey.MarshalBinary()
+
+	// 2-of-3 Shamir shares f(i) = k + a * i
+	secret := suiteGroup.NewScalar()
+	if err := secret.UnmarshalBinary(serializedKey); err != nil {
+		t.Fatal(err)
+	}
+
+	coefficient := suiteGroup.RandomScalar(rand.Reader)
+	evaluators := make([]*httptest.Server, 0, 3)
+	serverURLs := make([]string, 0, 3)
+
+	for index := uint16(1); index <= 3; index++ {
+		y := suiteGroup.NewScalar().Mul(coefficient, suiteGroup.NewScalar().SetUint64(uint64(index)))
+		y.Add(y, secret)
+
+		serializedShare, _ := y.MarshalBinary()
+
+		share := new(oprf.PrivateKey)
+		if err := share.UnmarshalBinary(suite, serializedShare); err != nil {
+			t.Fatal(err)
+		}
+
+		evaluator := newTestEvaluator(t, suite, share, &protocol.KeyShareInfo{
+			Index: index, Threshold: 2, Total: 3, GroupPublicKey: groupPublicKey,
+		})
+		t.Cleanup(evaluator.Close)
+
+		evaluators = append(evaluators, evaluator)
+		serverURLs = append(serverURLs, evaluator.URL)
+	}
+
+	// every share is checked against the polynomial of the group key, an index 0 would be the key itself
+	for _, index := range []uint16{4, 0} {
+		rogueKey, err := oprf.GenerateKey(suite, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rogue := newTestEvaluator(t, suite, rogueKey, &protocol.KeyShareInfo{
+			Index: index, Threshold: 2, Total: 3, GroupPublicKey: groupPublicKey,
+		})
+		t.Cleanup(rogue.Close)
+
+		_, err = NewThresholdClient(append(serverURLs[:3:3], rogue.URL), suite, oprf.VerifiableMode)
+		if !errors.Is(err, ErrInconsistentShares) {
+			t.Errorf("the share %d of another key is accepted : %v", index, err)
+		}
+	}
+
+	client, err := NewThresholdClient(serverURLs, suite, oprf.VerifiableMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the threshold is reached without the first evaluator
+	evaluators[0].Close()
+
+	input := []byte("dead3eef")
+
+	finalizeData, oprfEvaluationRequest, err := client.Blind([][]byte{input})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blindedElements, err := SerializeElements(oprfEvaluationRequest.Elements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluationRequest := NewEvaluationRequest(suite, oprf.VerifiableMode, nil, blindedElements)
+
+	partialEvaluations, err := client.EvaluateRequest(evaluationRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evaluationRequest.KeyID != "" {
+		t.Errorf("the request of the caller is changed to the key %q", evaluationRequest.KeyID)
+	}
+
+	outputs, err := client.Finalize(finalizeData, partialEvaluations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := oprf.NewVerifiableServer(suite, privateKey).FullEvaluate(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(outputs[0], expected) {
+		t.Errorf("the threshold output does not match the output of the unsplit key")
+	}
+}
@@ -0,0 +1,183 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+// Checkpoint is the last verified tree head of the transparency log of a server
+type Checkpoint struct {
+	TreeSize uint64 `json:"tree_size"`
+	RootHash []byte `json:"root_hash"`
+}
+
+// checkTransparencyLog checks that the keys of the client suite are included in the signed tree head of the
+// transparency log, and that the log only grew since the checkpoint. The checkpoint is then moved to the tree head.
+func (c *Client) checkTransparencyLog(document *protocol.KeySetDocument) error {
+	signedTreeHead, err := c.httpClient.GetSignedTreeHead()
+	if err != nil {
+		return err
+	}
+
+	if err := signedTreeHead.Verify(c.rootKey, time.Now()); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	treeHead := signedTreeHead.TreeHead
+
+	for _, key := range document.Keys {
+		if key.Suite != c.suite.Identifier() {
+			continue
+		}
+
+		entry := protocol.KeyLogEntry{Suite: key.Suite, KeyID: key.ID, PublicKey: key.PublicKey, State: key.State}
+
+		leafData, err := entry.LeafData()
+		if err != nil {
+			return fmt.Errorf("the key %q can't be in the transparency log : %w", key.ID, err)
+		}
+
+		leafHash := protocol.LeafHash(leafData)
+
+		proof, err := c.httpClient.GetInclusionProof(leafHash, treeHead.TreeSize)
+		if err != nil {
+			return fmt.Errorf("the key %q isn't in the transparency log : %w", key.ID, err)
+		}
+
+		if proof.TreeSize != treeHead.TreeSize {
+			return fmt.Errorf("%w : inclusion proof in a tree of %d leaves instead of %d", protocol.ErrInvalidProof,
+				proof.TreeSize, treeHead.TreeSize)
+		}
+
+		if err := protocol.VerifyInclusion(leafHash, proof, treeHead.RootHash); err != nil {
+			return fmt.Errorf("the key %q isn't in the transparency log : %w", key.ID, err)
+		}
+	}
+
+	checkpoint, err := c.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	if err := c.checkConsistency(checkpoint, &treeHead); err != nil {
+		return err
+	}
+
+	return c.saveCheckpoint(&Checkpoint{TreeSize: treeHead.TreeSize, RootHash: treeHead.RootHash})
+}
+
+// checkConsistency checks that the tree head extends the checkpoint, nil if the server wasn't checked before.
+func (c *Client) checkConsistency(checkpoint *Checkpoint, treeHead *protocol.TreeHead) error {
+	switch {
+	case checkpoint == nil:
+		return nil
+	case checkpoint.TreeSize > treeHead.TreeSize:
+		return fmt.Errorf("%w : the log shrank from %d to %d leaves", protocol.ErrLogInconsistent,
+			checkpoint.TreeSize, treeHead.TreeSize)
+	case checkpoint.TreeSize == treeHead.TreeSize:
+		if !bytes.Equal(checkpoint.RootHash, treeHead.RootHash) {
+			return fmt.Errorf("%w : another tree of %d leaves", protocol.ErrLogInconsistent, treeHead.TreeSize)
+		}
+
+		return nil
+	}
+
+	proof, err := c.httpClient.GetConsistencyProof(checkpoint.TreeSize, treeHead.TreeSize)
+	if err != nil {
+		return err
+	}
+
+	if proof.FirstSize != checkpoint.TreeSize || proof.SecondSize != treeHead.TreeSize {
+		return fmt.Errorf("%w : consistency proof between %d and %d leaves", protocol.ErrLogInconsistent,
+			proof.FirstSize, proof.SecondSize)
+	}
+
+	if err := protocol.VerifyConsistency(proof, checkpoint.RootHash, treeHead.RootHash); err != nil {
+		return fmt.Errorf("%w : %s", protocol.ErrLogInconsistent, err.Error())
+	}
+
+	return nil
+}
+
+// loadCheckpoint returns the checkpoint of the server from the checkpoint file, nil if there is none.
+func (c *Client) loadCheckpoint() (*Checkpoint, error) {
+	checkpoints, err := readCheckpoints(c.checkpointPath)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint, ok := checkpoints[c.httpClient.serverURL]
+	if !ok {
+		return nil, nil
+	}
+
+	return &checkpoint, nil
+}
+
+// saveCheckpoint replaces the checkpoint of the server, the checkpoint file is replaced atomically.
+func (c *Client) saveCheckpoint(checkpoint *Checkpoint) error {
+	checkpoints, err := readCheckpoints(c.checkpointPath)
+	if err != nil {
+		return err
+	}
+
+	checkpoints[c.httpClient.serverURL] = *checkpoint
+
+	data, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't marshal the checkpoints : %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.checkpointPath), 0o700); err != nil {
+		return fmt.Errorf("couldn't create the checkpoint directory : %w", err)
+	}
+
+	temporaryFile, err := os.CreateTemp(filepath.Dir(c.checkpointPath), ".checkpoint-*")
+	if err != nil {
+		return fmt.Errorf("couldn't save the checkpoint : %w", err)
+	}
+	defer os.Remove(temporaryFile.Name())
+
+	if _, err := temporaryFile.Write(data); err != nil {
+		temporaryFile.Close()
+
+		return fmt.Errorf("couldn't save the checkpoint : %w", err)
+	}
+
+	if err := temporaryFile.Close(); err != nil {
+		return fmt.Errorf("couldn't save the checkpoint : %w", err)
+	}
+
+	if err := os.Rename(temporaryFile.Name(), c.checkpointPath); err != nil {
+		return fmt.Errorf("couldn't save the checkpoint : %w", err)
+	}
+
+	return nil
+}
+
+// readCheckpoints reads the checkpoints of each server URL, the file is empty if it doesn't exist.
+func readCheckpoints(path string) (map[string]Checkpoint, error) {
+	checkpoints := make(map[string]Checkpoint)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoints, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the checkpoints : %w", err)
+	}
+
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, fmt.Errorf("couldn't parse the checkpoints : %w", err)
+	}
+
+	return checkpoints, nil
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+// testLog serves the signed key set of its keys and the transparency log of its entries
+type testLog struct {
+	signingKey ed25519.PrivateKey
+	delegation protocol.Delegation
+	keys       []protocol.KeySetKey
+	entries    []protocol.KeyLogEntry
+}
+
+func (l *testLog) leafHashes() [][]byte {
+	leafHashes := make([][]byte, 0, len(l.entries))
+	for _, entry := range l.entries {
+		leafData, _ := entry.LeafData()
+		leafHashes = append(leafHashes, protocol.LeafHash(leafData))
+	}
+
+	return leafHashes
+}
+
+func (l *testLog) publish(key protocol.KeySetKey) {
+	l.keys = []protocol.KeySetKey{key}
+	l.entries = append(l.entries, protocol.KeyLogEntry{
+		Suite: key.Suite, KeyID: key.ID, PublicKey: key.PublicKey, State: key.State,
+	})
+}
+
+// serveKeySet serves the signed key set of the keys.
+func (l *testLog) serveKeySet(t *testing.T, mux *http.ServeMux) {
+	t.Helper()
+
+	mux.HandleFunc(protocol.WellKnownKeysPath, func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+
+		signedKeySet, err := protocol.SignKeySet(l.signingKey, l.delegation, &protocol.KeySetDocument{
+			IssuedAt: now, ExpiresAt: now.Add(time.Hour), Keys: l.keys,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+
+		_ = json.NewEncoder(w).Encode(signedKeySet)
+	})
+}
+
+// serveLog serves the signed tree head and the proofs of the entries under /api.
+func (l *testLog) serveLog(mux *http.ServeMux) {
+	mux.HandleFunc("/api"+protocol.V2Path+protocol.TreeHeadEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		treeHead := protocol.TreeHead{
+			TreeSize: uint64(len(l.entries)), RootHash: protocol.RootHash(l.leafHashes()), Timestamp: time.Now(),
+		}
+		signedTreeHead, _ := protocol.SignTreeHead(l.signingKey, l.delegation, treeHead)
+		_ = json.NewEncoder(w).Encode(signedTreeHead)
+	})
+	mux.HandleFunc("/api"+protocol.V2Path+protocol.InclusionProofEndpoint, func(w http.ResponseWriter,
+		r *http.Request) {
+		leafHash, _ := hex.DecodeString(r.URL.Query().Get("leaf_hash"))
+		treeSize, _ := strconv.ParseUint(r.URL.Query().Get("tree_size"), 10, 64)
+
+		for index, hash := range l.leafHashes()[:treeSize] {
+			if bytes.Equal(hash, leafHash) {
+				_ = json.NewEncoder(w).Encode(protocol.NewInclusionProof(l.leafHashes()[:treeSize], uint64(index)))
+
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/api"+protocol.V2Path+protocol.ConsistencyProofEndpoint, func(w http.ResponseWriter,
+		r *http.Request) {
+		firstSize, _ := strconv.ParseUint(r.URL.Query().Get("first"), 10, 64)
+		secondSize, _ := strconv.ParseUint(r.URL.Query().Get("second"), 10, 64)
+		_ = json.NewEncoder(w).Encode(protocol.NewConsistencyProof(l.leafHashes()[:secondSize], firstSize))
+	})
+}
+
+func newTestLog(t *testing.T) (*testLog, ed25519.PublicKey) {
+	t.Helper()
+
+	rootPublicKey, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingPublicKey, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	return &testLog{
+		signingKey: signingKey,
+		delegation: protocol.SignDelegation(rootKey, signingPublicKey, now.Add(-time.Hour), now.Add(time.Hour)),
+	}, rootPublicKey
+}
+
+func newTestKey(t *testing.T, suite oprf.Suite, kid string) protocol.KeySetKey {
+	t.Helper()
+
+	privateKey, err := oprf.GenerateKey(suite, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := privateKey.Public().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return protocol.KeySetKey{
+		Suite:         suite.Identifier(),
+		PublicKeyInfo: protocol.PublicKeyInfo{ID: kid, State: protocol.KeyStateActive, PublicKey: publicKey},
+	}
+}
+
+func TestTransparencyLog(t *testing.T) {
+	suite := oprf.SuiteP256
+
+	keyLog, rootPublicKey := newTestLog(t)
+	januaryKey := newTestKey(t, suite, "january")
+	keyLog.publish(januaryKey)
+
+	mux := http.NewServeMux()
+	keyLog.serveKeySet(t, mux)
+	keyLog.serveLog(mux)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	checkpointPath := filepath.Join(t.TempDir(), "oprf", "checkpoint.json")
+
+	if _, err := NewClientWithTransparencyLog(server.URL+"/api", suite, oprf.BaseMode, rootPublicKey,
+		""); !errors.Is(err, ErrNoCheckpointPath) {
+		t.Errorf("the transparency log is checked without checkpoint file : %v", err)
+	}
+
+	if _, err := NewClientWithTransparencyLog(server.URL+"/api", suite, oprf.BaseMode, rootPublicKey,
+		checkpointPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rotated key is appended, the log grew since the checkpoint
+	keyLog.publish(newTestKey(t, suite, "february"))
+
+	client, err := NewClientWithTransparencyLog(server.URL+"/api", suite, oprf.BaseMode, rootPublicKey, checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if checkpoint, err := client.loadCheckpoint(); err != nil || checkpoint.TreeSize != 2 {
+		t.Errorf("the checkpoint isn't saved : %v %v", checkpoint, err)
+	}
+
+	// a key missing from the log isn't used
+	keyLog.keys = []protocol.KeySetKey{newTestKey(t, suite, "hidden")}
+
+	_, err = NewClientWithTransparencyLog(server.URL+"/api", suite, oprf.BaseMode, rootPublicKey, checkpointPath)
+	if err == nil {
+		t.Error("a key missing from the transparency log is used")
+	}
+
+	// a truncated log or a log rewritten since the checkpoint is detected, even with more leaves
+	keyLog.entries = keyLog.entries[:1]
+	keyLog.keys = []protocol.KeySetKey{januaryKey}
+
+	_, err = NewClientWithTransparencyLog(server.URL+"/api", suite, oprf.BaseMode, rootPublicKey, checkpointPath)
+	if !errors.Is(err, protocol.ErrLogInconsistent) {
+		t.Errorf("a truncated transparency log is trusted : %v", err)
+	}
+
+	keyLog.publish(newTestKey(t, suite, "march"))
+	keyLog.publish(newTestKey(t, suite, "april"))
+
+	_, err = NewClientWithTransparencyLog(server.URL+"/api", suite, oprf.BaseMode, rootPublicKey, checkpointPath)
+	if !errors.Is(err, protocol.ErrLogInconsistent) {
+		t.Errorf("a rewritten transparency log is trusted : %v", err)
+	}
+}
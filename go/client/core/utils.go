@@ -1,12 +1,44 @@
 package core
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 
 	"github.com/cloudflare/circl/group"
 	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
 )
 
+var ErrFingerprintMismatch = errors.New("the fingerprint doesn't match the public key")
+
+// Fingerprint returns the hex encoded SHA-256 hash of the suite identifier and the serialized public key,
+// as published by the server.
+func Fingerprint(suite oprf.Suite, publicKey *oprf.PublicKey) (string, error) {
+	serializedPublicKey, err := publicKey.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(suite.Identifier()))
+	hash.Write(serializedPublicKey)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ParseInfo decodes the hex public information. The clients used to tweak the evaluation with the text of the hex
+// string rather than the decoded bytes, the legacy parsing keeps the pseudonyms of these clients reproducible.
+func ParseInfo(info string, legacy bool) ([]byte, error) {
+	if legacy {
+		return []byte(info), nil
+	}
+
+	return protocol.DecodeInfo(info, protocol.InfoEncodingHex) //nolint:wrapcheck
+}
+
 // DeserializePublicKey deserialize a public key
 func DeserializePublicKey(suite oprf.Suite, serializedPublicKey []byte) (*oprf.PublicKey, error) {
 	publicKey := new(oprf.PublicKey)
@@ -19,37 +51,64 @@ func DeserializePublicKey(suite oprf.Suite, serializedPublicKey []byte) (*oprf.P
 	return publicKey, nil
 }
 
-// DeserializePublicKeys deserialize the server's public keys
-func DeserializePublicKeys(serializedPublicKeys map[string][]byte) (map[string]*oprf.PublicKey, error) {
-	publicKeys := make(map[string]*oprf.PublicKey)
-
-	for suiteID, serializedPublicKey := range serializedPublicKeys {
-		// log.Println(
-		// 	"Suite ID:", suiteID,
-		//	", Public key : ", base64.StdEncoding.EncodeToString(serializedPublicKey),
-		// )
+// DeserializePublicKeys deserialize the server's published public keys into a suite:kid:public key map.
+// The published fingerprints are checked against the public keys.
+func DeserializePublicKeys(serializedPublicKeys map[string][]protocol.PublicKeyInfo) (map[string]map[string]*oprf.PublicKey, error) {
+	publicKeys := make(map[string]map[string]*oprf.PublicKey)
 
+	for suiteID, publicKeyInfos := range serializedPublicKeys {
 		suite, err := oprf.GetSuite(suiteID)
 		if err != nil {
 			return nil, err
 		}
 
-		publicKey, err := DeserializePublicKey(suite, serializedPublicKey)
-		if err != nil {
-			return nil, err
-		}
+		publicKeys[suiteID] = make(map[string]*oprf.PublicKey)
+
+		for _, publicKeyInfo := range publicKeyInfos {
+			publicKey, err := DeserializePublicKey(suite, publicKeyInfo.PublicKey)
+			if err != nil {
+				return nil, err
+			}
 
-		publicKeys[suiteID] = publicKey
+			if publicKeyInfo.Fingerprint != "" {
+				fingerprint, err := Fingerprint(suite, publicKey)
+				if err != nil {
+					return nil, err
+				}
+
+				if fingerprint != publicKeyInfo.Fingerprint {
+					return nil, fmt.Errorf("%w : key %s of the suite %s", ErrFingerprintMismatch, publicKeyInfo.ID, suiteID)
+				}
+			}
+
+			publicKeys[suiteID][publicKeyInfo.ID] = publicKey
+		}
 	}
 
 	return publicKeys, nil
 }
 
+// ActiveKeyIDs returns the key ID of the active key of each suite
+func ActiveKeyIDs(serializedPublicKeys map[string][]protocol.PublicKeyInfo) map[string]string {
+	activeKeyIDs := make(map[string]string)
+
+	for suiteID, publicKeyInfos := range serializedPublicKeys {
+		for _, publicKeyInfo := range publicKeyInfos {
+			if publicKeyInfo.State == protocol.KeyStateActive {
+				activeKeyIDs[suiteID] = publicKeyInfo.ID
+			}
+		}
+	}
+
+	return activeKeyIDs
+}
+
+// SerializeElements serializes the elements in the compressed form of RFC 9497.
 func SerializeElements(elements []group.Element) ([][]byte, error) {
 	blindedElements := make([][]byte, len(elements))
 
 	for index, element := range elements {
-		blindedElement, err := element.MarshalBinary()
+		blindedElement, err := element.MarshalBinaryCompress()
 		if err != nil {
 			log.Println(err)
 
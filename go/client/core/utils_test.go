@@ -0,0 +1,52 @@
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+func TestDeserializePublicKeysFingerprint(t *testing.T) {
+	publicKey, _ := base64.StdEncoding.DecodeString("AwOv3aK6UMsgdyEb5RX1/7qnHamgalwnY/Qm9WizuSYn")
+
+	// fingerprint published by the server for the private key AtzyGS8NoBjEjqbhwdGY/zWyqdFkJghyTttoIGq4UoM=
+	publicKeyInfo := protocol.PublicKeyInfo{
+		ID:          "4ec7c3c90cd723d8",
+		State:       protocol.KeyStateActive,
+		PublicKey:   publicKey,
+		Fingerprint: "4ec7c3c90cd723d83f4c50425bbc9fd95aa38a0b426cb9d1aff299706c162c9b",
+	}
+
+	if _, err := DeserializePublicKeys(map[string][]protocol.PublicKeyInfo{
+		oprf.SuiteP256.Identifier(): {publicKeyInfo},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	publicKeyInfo.Fingerprint = "00" + publicKeyInfo.Fingerprint[2:]
+
+	if _, err := DeserializePublicKeys(map[string][]protocol.PublicKeyInfo{
+		oprf.SuiteP256.Identifier(): {publicKeyInfo},
+	}); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("a wrong fingerprint should be rejected : %v", err)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	info, err := ParseInfo("7465737420696e666f", false)
+	if err != nil || string(info) != "test info" {
+		t.Errorf("decoded info %q (%v), expected %q", info, err, "test info")
+	}
+
+	// the legacy info is the text of the hex string
+	if info, _ := ParseInfo("7465737420696e666f", true); string(info) != "7465737420696e666f" {
+		t.Errorf("legacy info %q, expected the hex text", info)
+	}
+
+	if _, err := ParseInfo("test info", false); !errors.Is(err, protocol.ErrInvalidEncoding) {
+		t.Errorf("invalid hex info accepted : %v", err)
+	}
+}
@@ -2,10 +2,17 @@ module github.com/ensimag-oprf/go/client
 
 go 1.17
 
-require github.com/cloudflare/circl v1.3.7
+require (
+	github.com/cloudflare/circl v1.3.7
+	github.com/ensimag-oprf/go/protocol v0.0.0
+)
 
 require (
 	github.com/bwesterb/go-ristretto v1.2.3 // indirect
+	github.com/fxamacker/cbor/v2 v2.7.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/crypto v0.17.0 // indirect
 	golang.org/x/sys v0.15.0 // indirect
 )
+
+replace github.com/ensimag-oprf/go/protocol => ../protocol
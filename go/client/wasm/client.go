@@ -10,7 +10,7 @@ import (
 	"github.com/ensimag-oprf/go/client/core"
 )
 
-// PseudonymizeResponse contains the pseudonymized data output and the public information if requested.
+// PseudonymizeResponse contains the pseudonymized data output and the hex public information if requested.
 type PseudonymizeResponse struct {
 	Info    string
 	Outputs [][]byte
@@ -20,13 +20,21 @@ type PseudonymizeResponse struct {
 // It creates the client request, generate the random static information, call the server for
 // an evaluation and finalize the protocol.
 func pseudonymize(request *PseudonimizeRequest) (*PseudonymizeResponse, error) {
-	suite, err := oprf.GetSuite(request.Suite)
-	if err != nil {
-		return nil, fmt.Errorf("the suite is not suppported : %w", err)
+	// An empty suite is negotiated with the server
+	var suite oprf.Suite
+
+	if request.Suite != "" {
+		var err error
+		if suite, err = oprf.GetSuite(request.Suite); err != nil {
+			return nil, fmt.Errorf("the suite is not suppported : %w", err)
+		}
 	}
 
 	// Set up the client with the mode and suite
-	client := core.NewClient(serverURL, suite, request.Mode)
+	client, err := core.NewClient(serverURL, suite, request.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't set up the client : %w", err)
+	}
 
 	// Request of pseudonymization
 	finalizeData, oprfEvaluationRequest, err := client.Blind(request.Data)
@@ -42,15 +50,30 @@ func pseudonymize(request *PseudonimizeRequest) (*PseudonymizeResponse, error) {
 	// Any system which has multiple POPRF applications should distinguish client inputs to
 	// ensure the POPRF results are separate.
 	// info := "7465737420696e666f"
-	// Generate a random information for each request for non-deterministic results
-	token := make([]byte, 256)
-	if _, err := rand.Read(token); err != nil {
-		log.Println(err)
+	// Generate a random information for each request for non-deterministic results, the public information is only
+	// used in the partially oblivious mode
+	var (
+		infoHex = request.Info
+		info    []byte
+	)
 
-		return nil, fmt.Errorf("couldn't generate random information : %w", err)
-	}
+	if request.Mode == oprf.PartialObliviousMode {
+		if infoHex == "" {
+			token := make([]byte, 256)
+			if _, err := rand.Read(token); err != nil {
+				log.Println(err)
+
+				return nil, fmt.Errorf("couldn't generate random information : %w", err)
+			}
 
-	info := hex.EncodeToString(token)
+			infoHex = hex.EncodeToString(token)
+		}
+
+		info, err = core.ParseInfo(infoHex, request.LegacyInfo)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't decode the public information : %w", err)
+		}
+	}
 	// DO NOT SHARE THE PUBLIC INFORMATION
 	// log.Println("Public information : ", info)
 
@@ -61,7 +84,7 @@ func pseudonymize(request *PseudonimizeRequest) (*PseudonymizeResponse, error) {
 
 	// Evaluate the request
 	evaluationRequest := core.NewEvaluationRequest(
-		suite, request.Mode, info, blindedElements,
+		client.Suite(), request.Mode, info, blindedElements,
 	)
 
 	evaluationResponse, err := client.EvaluateRequest(evaluationRequest)
@@ -80,7 +103,7 @@ func pseudonymize(request *PseudonimizeRequest) (*PseudonymizeResponse, error) {
 	// }
 
 	// Finalize the protocol
-	outputs, err := client.Finalize(finalizeData, evaluationResponse.Evaluation, info)
+	outputs, err := client.Finalize(finalizeData, evaluationResponse, info)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't finalize the request : %w", err)
 	}
@@ -88,7 +111,7 @@ func pseudonymize(request *PseudonimizeRequest) (*PseudonymizeResponse, error) {
 	// Build the response
 	response := &PseudonymizeResponse{Outputs: outputs}
 	if request.ReturnInfo {
-		response.Info = info
+		response.Info = infoHex
 	}
 
 	return response, nil
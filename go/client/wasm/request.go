@@ -13,6 +13,8 @@ type WrappedPseudonimizeRequest struct {
 	Data       []json.RawMessage `json:"data"`
 	Mode       oprf.Mode         `json:"mode"`
 	ReturnInfo bool              `json:"return-info"`
+	Info       string            `json:"info"`
+	LegacyInfo bool              `json:"legacy-info"`
 }
 
 // PseudonimizeRequest holds the data and the client setup parameters.
@@ -21,22 +23,28 @@ type PseudonimizeRequest struct {
 	Data       [][]byte  `json:"data"`
 	Mode       oprf.Mode `json:"mode"`
 	ReturnInfo bool      `json:"return-info"`
+	// Info is the hex encoded public information, it is random if empty
+	Info string `json:"info"`
+	// LegacyInfo uses the text of the hex public information rather than its bytes, as the previous clients did
+	LegacyInfo bool `json:"legacy-info"`
 }
 
-// ValidateMode validates the client mode (Base or Verifiable).
+// ValidateMode validates the client mode (Base, Verifiable or PartialOblivious).
 func (p *PseudonimizeRequest) ValidateMode() error {
 	switch p.Mode {
-	case oprf.BaseMode, oprf.VerifiableMode:
+	case oprf.BaseMode, oprf.VerifiableMode, oprf.PartialObliviousMode:
 		return nil
 	}
 
 	return errors.New("invalid mode")
 }
 
-// ValidateSuite validates the encryption suite (P256, P384, P521).
+// ValidateSuite validates the encryption suite (P256, P384, P521, Ristretto255), an empty suite is negotiated with
+// the server.
 func (p *PseudonimizeRequest) ValidateSuite() error {
 	switch p.Suite {
-	case oprf.SuiteP256.Identifier(), oprf.SuiteP384.Identifier(), oprf.SuiteP521.Identifier():
+	case "", oprf.SuiteP256.Identifier(), oprf.SuiteP384.Identifier(), oprf.SuiteP521.Identifier(),
+		oprf.SuiteRistretto255.Identifier():
 		return nil
 	}
 
@@ -64,6 +72,8 @@ func (p *PseudonimizeRequest) UnmarshalJSON(data []byte) error {
 	p.Suite = wp.Suite
 	p.Mode = wp.Mode
 	p.ReturnInfo = wp.ReturnInfo
+	p.Info = wp.Info
+	p.LegacyInfo = wp.LegacyInfo
 
 	return nil
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ensimag-oprf/go/client/core"
+	"github.com/ensimag-oprf/go/conformance"
+	"github.com/ensimag-oprf/go/server/controllers"
+)
+
+const serverURL = "http://localhost:1323/api"
+
+var (
+	server  string
+	suiteID string
+	output  string
+	help    bool
+)
+
+func commandLine() {
+	flag.StringVar(&server, "server", serverURL, "URL of the server API")
+	flag.StringVar(&suiteID, "suite", "", "Cipher suite of the vectors, all the supported suites if empty")
+	flag.StringVar(&output, "out", "", "Output file of the vectors, the standard output if empty")
+	flag.BoolVar(&help, "help", false, "Show the usage")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Evaluates the inputs and blinds of the RFC 9497 test vectors "+
+			"with the active keys of the server.\n")
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	commandLine()
+	flag.Parse()
+
+	if help {
+		flag.Usage()
+		os.Exit(0)
+	}
+
+	templates, err := conformance.RFC9497Vectors(controllers.SupportedSuites())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	generated := make([]*conformance.SuiteVectors, 0, len(templates))
+
+	for index := range templates {
+		if suiteID != "" && templates[index].Identifier != suiteID {
+			continue
+		}
+
+		suite, err := templates[index].Suite()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		client, err := core.NewClient(server, suite, templates[index].Mode)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		vectors, err := conformance.GenerateVectors(client, &templates[index])
+		if err != nil {
+			log.Fatalf("%s mode %d : %v", templates[index].Identifier, templates[index].Mode, err)
+		}
+
+		log.Println(vectors.Identifier, vectors.Mode, "key", vectors.KeyID, "fingerprint", vectors.Fingerprint)
+
+		generated = append(generated, vectors)
+	}
+
+	if len(generated) == 0 {
+		log.Fatalf("unsupported suite %q", suiteID)
+	}
+
+	data, err := json.MarshalIndent(generated, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if output == "" {
+		fmt.Println(string(data))
+
+		return
+	}
+
+	if err := os.WriteFile(output, append(data, '\n'), 0o644); err != nil { //nolint:gosec
+		log.Fatal(err)
+	}
+}
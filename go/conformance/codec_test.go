@@ -0,0 +1,95 @@
+package conformance
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+
+	"github.com/ensimag-oprf/go/client/core"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/ensimag-oprf/go/server/controllers"
+)
+
+const benchmarkBatchSize = 100
+
+// benchmarkEncoding encodes a request of the client, decodes it on the server, encodes the response of the server
+// and decodes it on the client for a verifiable evaluation of a batch of P-256 elements.
+func benchmarkEncoding(b *testing.B, contentType string) {
+	b.Helper()
+
+	suite := oprf.SuiteP256
+
+	privateKey, err := oprf.GenerateKey(suite, rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	inputs := make([][]byte, benchmarkBatchSize)
+	for index := range inputs {
+		inputs[index] = []byte{byte(index)}
+	}
+
+	_, oprfEvaluationRequest, err := oprf.NewVerifiableClient(suite, privateKey.Public()).Blind(inputs)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	evaluation, err := oprf.NewVerifiableServer(suite, privateKey).Evaluate(oprfEvaluationRequest)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	blindedElements, err := core.SerializeElements(oprfEvaluationRequest.Elements)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	evaluationRequest := core.NewEvaluationRequest(suite, oprf.VerifiableMode, nil, blindedElements)
+
+	evaluationResponse, err := controllers.NewEvaluationResponse(evaluation, suite.Identifier(), "kid",
+		controllers.SerializePublicKey(privateKey))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var encodedBytes int
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		requestData, err := protocol.EncodeEvaluationRequest(contentType, evaluationRequest)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if err := protocol.DecodeEvaluationRequest(contentType, requestData, new(protocol.EvaluationRequest)); err != nil {
+			b.Fatal(err)
+		}
+
+		responseData, err := protocol.EncodeEvaluationResponse(contentType, evaluationResponse)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if err := core.DecodeEvaluationResponse(contentType, responseData, new(core.EvaluationResponse)); err != nil {
+			b.Fatal(err)
+		}
+
+		encodedBytes = len(requestData) + len(responseData)
+	}
+
+	b.ReportMetric(float64(encodedBytes), "wire-bytes/op")
+}
+
+func BenchmarkEncodingJSON(b *testing.B) {
+	benchmarkEncoding(b, protocol.ContentTypeJSON)
+}
+
+func BenchmarkEncodingCBOR(b *testing.B) {
+	benchmarkEncoding(b, protocol.ContentTypeCBOR)
+}
+
+func BenchmarkEncodingBinary(b *testing.B) {
+	benchmarkEncoding(b, protocol.ContentTypeBinary)
+}
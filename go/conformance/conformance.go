@@ -0,0 +1,303 @@
+// Package conformance checks the server and the client against the RFC 9497 test vectors and generates test
+// vectors for a deployment key, so that other implementations can check their interoperability with it.
+package conformance
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/cloudflare/circl/zk/dleq"
+
+	"github.com/ensimag-oprf/go/client/core"
+	"github.com/ensimag-oprf/go/server/controllers"
+	"github.com/ensimag-oprf/go/server/routers"
+)
+
+// APIPath is the path of the API on the server
+const APIPath = "/api"
+
+var ErrVectorMismatch = errors.New("the test vector doesn't match")
+
+// NewVectorServer starts a server evaluating with the private key of the RFC vectors.
+// The keys of the other suites are generated.
+func NewVectorServer(vectors *SuiteVectors) (*httptest.Server, error) {
+	suite, err := vectors.Suite()
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := vectors.PrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	keySet := controllers.NewKeySet(suite)
+	if err := keySet.Add(controllers.NewKey(suite, privateKey)); err != nil {
+		return nil, err
+	}
+
+	oprfServerController := controllers.NewOPRFServerController()
+	if err := oprfServerController.Initialize(controllers.NewMemoryKeyProvider(keySet)); err != nil {
+		return nil, err
+	}
+
+	return httptest.NewServer(routers.NewRouter(oprfServerController)), nil
+}
+
+// CheckVectors evaluates the RFC vectors on the server through the client and checks the blinded elements,
+// the evaluated elements, the outputs and the wire format of the evaluations.
+func CheckVectors(client *core.Client, vectors *SuiteVectors) error {
+	suite, err := vectors.Suite()
+	if err != nil {
+		return err
+	}
+
+	privateKey, err := vectors.PrivateKey()
+	if err != nil {
+		return err
+	}
+
+	keyID := controllers.NewKeyID(suite, privateKey.Public())
+
+	for index := range vectors.Vectors {
+		expected := &vectors.Vectors[index]
+
+		evaluated, _, err := EvaluateVector(client, suite, vectors.Mode, expected)
+		if err != nil {
+			return fmt.Errorf("vector %d : %w", index, err)
+		}
+
+		if err := compareVectors(expected, evaluated); err != nil {
+			return fmt.Errorf("vector %d : %w", index, err)
+		}
+
+		if err := checkWireFormat(client, suite, keyID, expected); err != nil {
+			return fmt.Errorf("vector %d : %w", index, err)
+		}
+	}
+
+	return nil
+}
+
+// EvaluateVector evaluates the inputs of the vector on the server with the blinds of the vector and returns the
+// evaluated vector with the server proof. The proof randomness of the server is unknown.
+func EvaluateVector(client *core.Client, suite oprf.Suite, mode oprf.Mode,
+	vector *Vector,
+) (*Vector, *core.EvaluationResponse, error) {
+	inputs, err := vector.Inputs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blinds, err := vector.Blinds(suite)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := vector.InfoBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	finalizeData, oprfEvaluationRequest, err := client.DeterministicBlind(inputs, blinds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blindedElements, err := core.SerializeElements(oprfEvaluationRequest.Elements)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	evaluationResponse, err := client.EvaluateRequest(
+		core.NewEvaluationRequest(suite, mode, info, blindedElements),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outputs, err := client.Finalize(finalizeData, evaluationResponse, info)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	evaluatedElements, err := core.SerializeElements(evaluationResponse.Evaluation.Elements)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	evaluated := &Vector{
+		Batch:             vector.Batch,
+		Blind:             vector.Blind,
+		Info:              vector.Info,
+		Input:             vector.Input,
+		BlindedElement:    encodeBatch(blindedElements),
+		EvaluationElement: encodeBatch(evaluatedElements),
+		Output:            encodeBatch(outputs),
+	}
+
+	if evaluationResponse.Evaluation.Proof != nil {
+		proof, err := evaluationResponse.Evaluation.Proof.MarshalBinary()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		evaluated.Proof = &VectorProof{Proof: hex.EncodeToString(proof)} //nolint:exhaustivestruct
+	}
+
+	return evaluated, evaluationResponse, nil
+}
+
+// GenerateVectors evaluates the inputs of the template vectors with the active key of the server.
+func GenerateVectors(client *core.Client, templates *SuiteVectors) (*SuiteVectors, error) {
+	suite, err := templates.Suite()
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := &SuiteVectors{ //nolint:exhaustivestruct
+		Identifier: templates.Identifier,
+		Mode:       templates.Mode,
+		Hash:       templates.Hash,
+		GroupDST:   templates.GroupDST,
+		Vectors:    make([]Vector, 0, len(templates.Vectors)),
+	}
+
+	for index := range templates.Vectors {
+		evaluated, evaluationResponse, err := EvaluateVector(client, suite, templates.Mode, &templates.Vectors[index])
+		if err != nil {
+			return nil, fmt.Errorf("vector %d : %w", index, err)
+		}
+
+		if vectors.KeyID != "" && evaluationResponse.KeyID != vectors.KeyID {
+			return nil, fmt.Errorf("%w : the key %s was rotated during the generation", ErrVectorMismatch,
+				vectors.KeyID)
+		}
+
+		publicKey, err := core.DeserializePublicKey(suite, evaluationResponse.SerializedPublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		fingerprint, err := core.Fingerprint(suite, publicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		vectors.KeyID = evaluationResponse.KeyID
+		vectors.Fingerprint = fingerprint
+		vectors.PkSm = hex.EncodeToString(evaluationResponse.SerializedPublicKey)
+		vectors.Vectors = append(vectors.Vectors, *evaluated)
+	}
+
+	return vectors, nil
+}
+
+// compareVectors compares the evaluated vector to the expected vector. The proofs differ as the server proof
+// randomness is random.
+func compareVectors(expected, evaluated *Vector) error {
+	for _, field := range []struct {
+		name                string
+		expected, evaluated string
+	}{
+		{"BlindedElement", expected.BlindedElement, evaluated.BlindedElement},
+		{"EvaluationElement", expected.EvaluationElement, evaluated.EvaluationElement},
+		{"Output", expected.Output, evaluated.Output},
+	} {
+		if field.expected != field.evaluated {
+			return fmt.Errorf("%w : %s %s, expected %s", ErrVectorMismatch, field.name, field.evaluated,
+				field.expected)
+		}
+	}
+
+	return nil
+}
+
+// checkWireFormat serializes the RFC evaluation as the server does, parses it as the client does and finalizes it.
+// The client verifies the RFC proof in the verifiable modes.
+func checkWireFormat(client *core.Client, suite oprf.Suite, keyID string, vector *Vector) error {
+	serializedElements, err := decodeBatch(vector.EvaluationElement, vector.Batch, "EvaluationElement")
+	if err != nil {
+		return err
+	}
+
+	elements, err := controllers.DeserializeElements(serializedElements, suite.Group())
+	if err != nil {
+		return fmt.Errorf("%w : EvaluationElement : %s", ErrVectorMismatch, err.Error())
+	}
+
+	var proof *dleq.Proof
+
+	if vector.Proof != nil {
+		serializedProof, err := hex.DecodeString(vector.Proof.Proof)
+		if err != nil {
+			return fmt.Errorf("%w : Proof : %s", ErrInvalidVector, err.Error())
+		}
+
+		proof = new(dleq.Proof)
+		if err := proof.UnmarshalBinary(suite.Group(), serializedProof); err != nil {
+			return fmt.Errorf("%w : Proof : %s", ErrVectorMismatch, err.Error())
+		}
+	}
+
+	wireResponse, err := controllers.NewEvaluationResponse(
+		&oprf.Evaluation{Elements: elements, Proof: proof}, suite.Identifier(), keyID, nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	serializedResponse, err := json.Marshal(wireResponse)
+	if err != nil {
+		return err
+	}
+
+	evaluationResponse := new(core.EvaluationResponse)
+	if err := json.Unmarshal(serializedResponse, evaluationResponse); err != nil {
+		return fmt.Errorf("%w : %s", ErrVectorMismatch, err.Error())
+	}
+
+	parsedElements, err := core.SerializeElements(evaluationResponse.Evaluation.Elements)
+	if err != nil {
+		return err
+	}
+
+	if encodeBatch(parsedElements) != vector.EvaluationElement {
+		return fmt.Errorf("%w : the parsed evaluation elements differ", ErrVectorMismatch)
+	}
+
+	inputs, err := vector.Inputs()
+	if err != nil {
+		return err
+	}
+
+	blinds, err := vector.Blinds(suite)
+	if err != nil {
+		return err
+	}
+
+	info, err := vector.InfoBytes()
+	if err != nil {
+		return err
+	}
+
+	finalizeData, _, err := client.DeterministicBlind(inputs, blinds)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := client.Finalize(finalizeData, evaluationResponse, info)
+	if err != nil {
+		return fmt.Errorf("%w : %s", ErrVectorMismatch, err.Error())
+	}
+
+	if encodeBatch(outputs) != vector.Output {
+		return fmt.Errorf("%w : Output of the parsed evaluation", ErrVectorMismatch)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package conformance
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ensimag-oprf/go/client/core"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/ensimag-oprf/go/server/controllers"
+)
+
+func TestRFC9497Vectors(t *testing.T) {
+	suiteVectors, err := RFC9497Vectors(controllers.SupportedSuites())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 modes per supported suite
+	if len(suiteVectors) != 3*len(controllers.SupportedSuites()) {
+		t.Fatalf("%d suite vectors for %d suites", len(suiteVectors), len(controllers.SupportedSuites()))
+	}
+
+	for index := range suiteVectors {
+		vectors := &suiteVectors[index]
+
+		t.Run(fmt.Sprintf("%s/mode %d", vectors.Identifier, vectors.Mode), func(t *testing.T) {
+			server, err := NewVectorServer(vectors)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			suite, err := vectors.Suite()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client, err := core.NewClient(server.URL+APIPath, suite, vectors.Mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, contentType := range []string{
+				protocol.ContentTypeJSON, protocol.ContentTypeCBOR, protocol.ContentTypeBinary,
+			} {
+				if err := client.SetContentType(contentType); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := CheckVectors(client, vectors); err != nil {
+					t.Error(contentType, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateVectors(t *testing.T) {
+	suiteVectors, err := RFC9497Vectors(controllers.SupportedSuites())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the vectors generated with the RFC key match the RFC vectors but the proofs
+	templates := &suiteVectors[0]
+
+	server, err := NewVectorServer(templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	suite, err := templates.Suite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := core.NewClient(server.URL+APIPath, suite, templates.Mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := GenerateVectors(client, templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey, err := templates.PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if generated.KeyID != controllers.NewKeyID(suite, privateKey.Public()) || generated.SkSm != "" {
+		t.Errorf("unexpected key %s %q", generated.KeyID, generated.SkSm)
+	}
+
+	for index := range generated.Vectors {
+		if err := compareVectors(&templates.Vectors[index], &generated.Vectors[index]); err != nil {
+			t.Error(err)
+		}
+	}
+}
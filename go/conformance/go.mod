@@ -0,0 +1,35 @@
+module github.com/ensimag-oprf/go/conformance
+
+go 1.17
+
+require (
+	github.com/cloudflare/circl v1.3.7
+	github.com/ensimag-oprf/go/client v0.0.0
+	github.com/ensimag-oprf/go/protocol v0.0.0
+	github.com/ensimag-oprf/go/server v0.0.0
+)
+
+require (
+	github.com/bwesterb/go-ristretto v1.2.3 // indirect
+	github.com/fxamacker/cbor/v2 v2.7.0 // indirect
+	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
+	github.com/labstack/echo/v4 v4.10.2 // indirect
+	github.com/labstack/gommon v0.4.0 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.18 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.2 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/term v0.15.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	golang.org/x/time v0.3.0 // indirect
+)
+
+replace (
+	github.com/ensimag-oprf/go/client => ../client
+	github.com/ensimag-oprf/go/protocol => ../protocol
+	github.com/ensimag-oprf/go/server => ../server
+)
@@ -0,0 +1,167 @@
+package conformance
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+var ErrInvalidVector = errors.New("invalid test vector")
+
+// SuiteVectors are the test vectors of a suite and a mode, all evaluated with the same key.
+// The private key and its seed are omitted from the vectors of a deployment key.
+type SuiteVectors struct {
+	Identifier string    `json:"identifier"`
+	Mode       oprf.Mode `json:"mode"`
+	Hash       string    `json:"hash,omitempty"`
+	GroupDST   string    `json:"groupDST,omitempty"`
+	Seed       string    `json:"seed,omitempty"`
+	KeyInfo    string    `json:"keyInfo,omitempty"`
+	SkSm       string    `json:"skSm,omitempty"`
+	PkSm       string    `json:"pkSm,omitempty"`
+	// KeyID and Fingerprint identify the deployment key of the generated vectors
+	KeyID       string   `json:"kid,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	Vectors     []Vector `json:"vectors"`
+}
+
+// Vector is a test vector, the batched values are hex encoded and comma-separated.
+type Vector struct {
+	Batch             int          `json:"Batch"`
+	Blind             string       `json:"Blind"`
+	Info              string       `json:"Info,omitempty"`
+	Input             string       `json:"Input"`
+	BlindedElement    string       `json:"BlindedElement"`
+	EvaluationElement string       `json:"EvaluationElement"`
+	Proof             *VectorProof `json:"Proof,omitempty"`
+	Output            string       `json:"Output"`
+}
+
+// VectorProof is the DLEQ proof of the evaluation. The proof randomness is only known for the RFC vectors.
+type VectorProof struct {
+	Proof string `json:"proof"`
+	R     string `json:"r,omitempty"`
+}
+
+// RFC9497Vectors returns the RFC 9497 test vectors of the supported suites.
+func RFC9497Vectors(suites []oprf.Suite) ([]SuiteVectors, error) {
+	var allVectors []SuiteVectors
+	if err := json.Unmarshal(protocol.RFC9497TestVectors, &allVectors); err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidVector, err.Error())
+	}
+
+	supported := make(map[string]bool)
+	for _, suite := range suites {
+		supported[suite.Identifier()] = true
+	}
+
+	suiteVectors := make([]SuiteVectors, 0, len(allVectors))
+
+	for _, vectors := range allVectors {
+		if supported[vectors.Identifier] {
+			suiteVectors = append(suiteVectors, vectors)
+		}
+	}
+
+	return suiteVectors, nil
+}
+
+// Suite returns the suite of the vectors.
+func (v *SuiteVectors) Suite() (oprf.Suite, error) {
+	suite, err := oprf.GetSuite(v.Identifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidVector, err.Error())
+	}
+
+	return suite, nil
+}
+
+// PrivateKey returns the private key of the RFC vectors.
+func (v *SuiteVectors) PrivateKey() (*oprf.PrivateKey, error) {
+	suite, err := v.Suite()
+	if err != nil {
+		return nil, err
+	}
+
+	serializedKey, err := hex.DecodeString(v.SkSm)
+	if err != nil {
+		return nil, fmt.Errorf("%w : skSm : %s", ErrInvalidVector, err.Error())
+	}
+
+	privateKey := new(oprf.PrivateKey)
+	if err := privateKey.UnmarshalBinary(suite, serializedKey); err != nil {
+		return nil, fmt.Errorf("%w : skSm : %s", ErrInvalidVector, err.Error())
+	}
+
+	return privateKey, nil
+}
+
+// Inputs returns the decoded inputs of the batch.
+func (v *Vector) Inputs() ([][]byte, error) {
+	return decodeBatch(v.Input, v.Batch, "Input")
+}
+
+// Blinds returns the decoded blinds of the batch for the suite.
+func (v *Vector) Blinds(suite oprf.Suite) ([]oprf.Blind, error) {
+	serializedBlinds, err := decodeBatch(v.Blind, v.Batch, "Blind")
+	if err != nil {
+		return nil, err
+	}
+
+	blinds := make([]oprf.Blind, len(serializedBlinds))
+
+	for index, serializedBlind := range serializedBlinds {
+		blinds[index] = suite.Group().NewScalar()
+		if err := blinds[index].UnmarshalBinary(serializedBlind); err != nil {
+			return nil, fmt.Errorf("%w : Blind : %s", ErrInvalidVector, err.Error())
+		}
+	}
+
+	return blinds, nil
+}
+
+// InfoBytes returns the decoded public input of the partially oblivious mode.
+func (v *Vector) InfoBytes() ([]byte, error) {
+	info, err := hex.DecodeString(v.Info)
+	if err != nil {
+		return nil, fmt.Errorf("%w : Info : %s", ErrInvalidVector, err.Error())
+	}
+
+	return info, nil
+}
+
+// decodeBatch decodes the comma-separated hex values of a batch.
+func decodeBatch(values string, batch int, name string) ([][]byte, error) {
+	hexValues := strings.Split(values, ",")
+	if len(hexValues) != batch {
+		return nil, fmt.Errorf("%w : %s : %d values for a batch of %d", ErrInvalidVector, name, len(hexValues), batch)
+	}
+
+	decoded := make([][]byte, len(hexValues))
+
+	for index, hexValue := range hexValues {
+		value, err := hex.DecodeString(hexValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w : %s : %s", ErrInvalidVector, name, err.Error())
+		}
+
+		decoded[index] = value
+	}
+
+	return decoded, nil
+}
+
+// encodeBatch hex encodes and comma-separates the values of a batch.
+func encodeBatch(values [][]byte) string {
+	hexValues := make([]string, len(values))
+	for index, value := range values {
+		hexValues[index] = hex.EncodeToString(value)
+	}
+
+	return strings.Join(hexValues, ",")
+}
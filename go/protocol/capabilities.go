@@ -0,0 +1,96 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CapabilitiesEndpoint describes the suites, the modes and the limits of the server
+const CapabilitiesEndpoint = "/capabilities"
+
+// Versions of the API
+const (
+	APIVersion1 = "v1"
+	APIVersion2 = "v2"
+)
+
+var ErrNotOffered = errors.New("not offered by the server")
+
+// Capabilities describe what a server offers, so that the clients negotiate the suite, the mode and the encoding
+// instead of hard-coding them. The suites and the encodings are in the order of preference of the server.
+type Capabilities struct {
+	APIVersions []string            `json:"api_versions"`
+	Suites      []SuiteCapabilities `json:"suites"`
+	// Encodings are the content types of the evaluation requests and responses
+	Encodings []string    `json:"encodings"`
+	Limits    LimitsOffer `json:"limits"`
+}
+
+// SuiteCapabilities are the modes of a suite and its published key IDs
+type SuiteCapabilities struct {
+	Suite       string   `json:"suite"`
+	Modes       []Mode   `json:"modes"`
+	KeyIDs      []string `json:"kids"`
+	ActiveKeyID string   `json:"active_kid,omitempty"`
+}
+
+// LimitsOffer are the limits of the evaluation requests
+type LimitsOffer struct {
+	MaxBodySize   int64 `json:"max_body_size"`
+	MaxBatchSize  int   `json:"max_batch_size"`
+	MaxInfoLength int   `json:"max_info_length"`
+}
+
+// Suite returns the capabilities of the suite, nil if it isn't offered.
+func (c *Capabilities) Suite(suiteID string) *SuiteCapabilities {
+	for index := range c.Suites {
+		if c.Suites[index].Suite == suiteID {
+			return &c.Suites[index]
+		}
+	}
+
+	return nil
+}
+
+// CheckSuite returns an ErrNotOffered error listing the offers if the suite isn't offered in the mode.
+func (c *Capabilities) CheckSuite(suiteID string, mode Mode) error {
+	if suite := c.Suite(suiteID); suite != nil && suite.HasMode(mode) {
+		return nil
+	}
+
+	offers := make([]string, len(c.Suites))
+	for index, suite := range c.Suites {
+		modes := make([]string, len(suite.Modes))
+		for modeIndex, suiteMode := range suite.Modes {
+			modes[modeIndex] = string(suiteMode)
+		}
+
+		offers[index] = fmt.Sprintf("%s (%s)", suite.Suite, strings.Join(modes, ", "))
+	}
+
+	return fmt.Errorf("the suite %s in the %s mode is %w, which offers %s", suiteID, mode, ErrNotOffered,
+		strings.Join(offers, ", "))
+}
+
+// HasEncoding reports whether the content type is offered.
+func (c *Capabilities) HasEncoding(contentType string) bool {
+	for _, encoding := range c.Encodings {
+		if encoding == contentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasMode reports whether the mode is offered with the suite.
+func (s *SuiteCapabilities) HasMode(mode Mode) bool {
+	for _, suiteMode := range s.Modes {
+		if suiteMode == mode {
+			return true
+		}
+	}
+
+	return false
+}
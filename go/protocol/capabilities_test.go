@@ -0,0 +1,33 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCapabilitiesCheckSuite(t *testing.T) {
+	capabilities := &Capabilities{
+		APIVersions: []string{APIVersion1, APIVersion2},
+		Suites: []SuiteCapabilities{
+			{Suite: "P256-SHA256", Modes: []Mode{ModeBase, ModeVerifiable}, KeyIDs: []string{"2022"}, ActiveKeyID: "2022"},
+			{Suite: "P384-SHA384", Modes: []Mode{ModeBase}, KeyIDs: []string{"2022"}, ActiveKeyID: "2022"},
+		},
+		Encodings: []string{ContentTypeBinary, ContentTypeJSON},
+		Limits:    LimitsOffer{MaxBodySize: 1 << 20, MaxBatchSize: 1024, MaxInfoLength: 1024},
+	}
+
+	if err := capabilities.CheckSuite("P256-SHA256", ModeVerifiable); err != nil {
+		t.Error(err)
+	}
+
+	// the error lists the offers
+	err := capabilities.CheckSuite("P384-SHA384", ModePartialOblivious)
+	if !errors.Is(err, ErrNotOffered) || !strings.Contains(err.Error(), "P256-SHA256 (base, verifiable)") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if capabilities.HasEncoding(ContentTypeCBOR) || !capabilities.HasEncoding(ContentTypeBinary) {
+		t.Errorf("unexpected encodings")
+	}
+}
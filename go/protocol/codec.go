@@ -0,0 +1,617 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"strings"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// Encodings of the evaluation requests and responses, negotiated with the Content-Type and Accept headers
+const (
+	ContentTypeJSON = "application/json"
+	// ContentTypeCBOR is the CBOR encoding of the JSON messages
+	ContentTypeCBOR = "application/cbor"
+	// ContentTypeBinary is the compact encoding with the fixed-length elements of RFC 9497
+	ContentTypeBinary = "application/octet-stream"
+)
+
+var (
+	ErrInvalidEncoding = errors.New("invalid encoding")
+	ErrUnknownEncoding = errors.New("unknown encoding")
+)
+
+// cborEncMode and cborDecMode encode the messages as their JSON encoding rather than their binary encoding
+var (
+	cborEncMode, _ = cbor.EncOptions{BinaryMarshaler: cbor.BinaryMarshalerNone}.EncMode()     //nolint:exhaustivestruct
+	cborDecMode, _ = cbor.DecOptions{BinaryUnmarshaler: cbor.BinaryUnmarshalerNone}.DecMode() //nolint:exhaustivestruct
+)
+
+// Encodings maps the encoding names of the command line to their content type
+var Encodings = map[string]string{
+	"json":   ContentTypeJSON,
+	"cbor":   ContentTypeCBOR,
+	"binary": ContentTypeBinary,
+}
+
+// ValidateContentType checks that the content type is one of the supported encodings.
+func ValidateContentType(contentType string) error {
+	switch contentType {
+	case ContentTypeJSON, ContentTypeCBOR, ContentTypeBinary:
+		return nil
+	default:
+		return fmt.Errorf("%w : %q", ErrUnknownEncoding, contentType)
+	}
+}
+
+// Flags of the mode byte of a binary request
+const (
+	// groupedModeFlag is set if the request has a public information per element
+	groupedModeFlag = 0x80
+	// partialSuccessModeFlag is set if the request is a partial-success batch
+	partialSuccessModeFlag = 0x40
+)
+
+// Extensions of a binary response
+const (
+	infoExtension     = 0x01
+	statusesExtension = 0x02
+)
+
+// MarshalBinary encodes the request as :
+// mode (1 byte) | suite (1-byte length) | kid (1-byte length) | info (2-byte length, raw bytes) | count (2 bytes) |
+// elements [| infos (2-byte length each)]
+// The mode is the number of the mode in the oprf package, with the 0x80 flag if the request has a public information
+// per element and the 0x40 flag if the request is a partial-success batch. The elements have the compressed length
+// of the suite elements. The integers are big-endian.
+func (r *EvaluationRequest) MarshalBinary() ([]byte, error) {
+	mode, err := r.Mode.OPRFMode()
+	if err != nil {
+		return nil, err
+	}
+
+	elementLength, err := compressedElementLength(r.Suite)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.BlindedElements) > math.MaxUint16 {
+		return nil, fmt.Errorf("%w : %d elements", ErrInvalidEncoding, len(r.BlindedElements))
+	}
+
+	if len(r.Infos) > 0 {
+		if len(r.Infos) != len(r.BlindedElements) {
+			return nil, fmt.Errorf("%w : %d infos for %d elements", ErrInvalidEncoding, len(r.Infos),
+				len(r.BlindedElements))
+		}
+
+		mode |= groupedModeFlag
+	}
+
+	if r.PartialSuccess {
+		mode |= partialSuccessModeFlag
+	}
+
+	builder := cryptobyte.NewBuilder(nil)
+	builder.AddUint8(mode)
+	addUint8LengthPrefixed(builder, []byte(r.Suite))
+	addUint8LengthPrefixed(builder, []byte(r.KeyID))
+	addUint16LengthPrefixed(builder, r.Info)
+
+	if err := addElements(builder, r.BlindedElements, elementLength); err != nil {
+		return nil, err
+	}
+
+	for _, info := range r.Infos {
+		addUint16LengthPrefixed(builder, info)
+	}
+
+	return builder.Bytes() //nolint:wrapcheck
+}
+
+// UnmarshalBinary decodes a request encoded by MarshalBinary.
+func (r *EvaluationRequest) UnmarshalBinary(data []byte) error {
+	input := cryptobyte.String(data)
+
+	var suite, keyID, info cryptobyte.String
+
+	var mode oprf.Mode
+
+	if !input.ReadUint8(&mode) || !input.ReadUint8LengthPrefixed(&suite) || !input.ReadUint8LengthPrefixed(&keyID) ||
+		!input.ReadUint16LengthPrefixed(&info) {
+		return fmt.Errorf("%w : truncated request", ErrInvalidEncoding)
+	}
+
+	r.Mode, r.Suite, r.KeyID = NewMode(mode&^(groupedModeFlag|partialSuccessModeFlag)), string(suite), string(keyID)
+	r.PartialSuccess = mode&partialSuccessModeFlag != 0
+
+	// the public information is raw bytes
+	r.Info = nil
+	if len(info) > 0 {
+		r.Info = info
+	}
+
+	elementLength, err := compressedElementLength(r.Suite)
+	if err != nil {
+		return err
+	}
+
+	blindedElements, err := readElements(&input, elementLength)
+	if err != nil {
+		return err
+	}
+
+	r.BlindedElements, r.Infos = blindedElements, nil
+
+	if mode&groupedModeFlag != 0 {
+		r.Infos = make([][]byte, len(blindedElements))
+
+		for index := range r.Infos {
+			var elementInfo cryptobyte.String
+			if !input.ReadUint16LengthPrefixed(&elementInfo) {
+				return fmt.Errorf("%w : truncated info %d", ErrInvalidEncoding, index)
+			}
+
+			if len(elementInfo) > 0 {
+				r.Infos[index] = elementInfo
+			}
+		}
+	}
+
+	if !input.Empty() {
+		return fmt.Errorf("%w : trailing data", ErrInvalidEncoding)
+	}
+
+	return nil
+}
+
+// MarshalBinary encodes the response as :
+// suite (1-byte length) | kid (1-byte length) | share index (2 bytes) | public key (2-byte length) | count (2 bytes) |
+// elements | proof (2-byte length, empty in base mode) | extensions
+// The count of a grouped response is 0, it is followed by the groups and the extensions :
+// group count (2 bytes) | groups : count (2 bytes) | indices (2 bytes each) | elements | proof (2-byte length) |
+// info (2-byte length, empty if the server didn't change it)
+// The extensions are type (1 byte) | value (3-byte length) : the evaluated public information (1) if the server
+// changed it, and the statuses (2) of a partial-success batch.
+// The elements have the compressed length of the suite elements. The integers are big-endian.
+func (r *EvaluationResponse) MarshalBinary() ([]byte, error) {
+	if r.Evaluation == nil && len(r.Groups) == 0 {
+		return nil, fmt.Errorf("%w : no evaluation", ErrInvalidEncoding)
+	}
+
+	elementLength, err := compressedElementLength(r.Suite)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := cryptobyte.NewBuilder(nil)
+	addUint8LengthPrefixed(builder, []byte(r.Suite))
+	addUint8LengthPrefixed(builder, []byte(r.KeyID))
+	builder.AddUint16(r.ShareIndex)
+	addUint16LengthPrefixed(builder, r.SerializedPublicKey)
+
+	// the count of a grouped response is 0
+	if r.Evaluation == nil {
+		builder.AddUint16(0)
+
+		if err := addGroups(builder, r.Groups, elementLength); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := addElements(builder, r.Evaluation.Elements, elementLength); err != nil {
+			return nil, err
+		}
+
+		addUint16LengthPrefixed(builder, r.Evaluation.Proof)
+	}
+
+	if err := r.addExtensions(builder); err != nil {
+		return nil, err
+	}
+
+	return builder.Bytes() //nolint:wrapcheck
+}
+
+// addExtensions adds the evaluated public information and the statuses of the response, if any.
+func (r *EvaluationResponse) addExtensions(builder *cryptobyte.Builder) error {
+	if len(r.Info) > 0 {
+		builder.AddUint8(infoExtension)
+		builder.AddUint24LengthPrefixed(func(child *cryptobyte.Builder) {
+			child.AddBytes(r.Info)
+		})
+	}
+
+	if len(r.Statuses) == 0 {
+		return nil
+	}
+
+	if len(r.Statuses) > math.MaxUint16 {
+		return fmt.Errorf("%w : %d statuses", ErrInvalidEncoding, len(r.Statuses))
+	}
+
+	// status : evaluated (1 byte) [| code (1-byte length) | message (2-byte length)]
+	builder.AddUint8(statusesExtension)
+	builder.AddUint24LengthPrefixed(func(child *cryptobyte.Builder) {
+		child.AddUint16(uint16(len(r.Statuses)))
+
+		for _, status := range r.Statuses {
+			if status.Evaluated || status.Error == nil {
+				child.AddUint8(1)
+
+				continue
+			}
+
+			child.AddUint8(0)
+			addUint8LengthPrefixed(child, []byte(status.Error.Code))
+			addUint16LengthPrefixed(child, []byte(status.Error.Message))
+		}
+	})
+
+	return nil
+}
+
+// readExtensions reads the extensions of the response up to the end of the input.
+func (r *EvaluationResponse) readExtensions(input *cryptobyte.String) error {
+	for !input.Empty() {
+		var (
+			extension uint8
+			value     cryptobyte.String
+		)
+
+		if !input.ReadUint8(&extension) || !input.ReadUint24LengthPrefixed(&value) {
+			return fmt.Errorf("%w : truncated extension", ErrInvalidEncoding)
+		}
+
+		switch extension {
+		case infoExtension:
+			if len(value) == 0 {
+				return fmt.Errorf("%w : empty info", ErrInvalidEncoding)
+			}
+
+			r.Info = value
+		case statusesExtension:
+			statuses, err := readStatuses(&value)
+			if err != nil {
+				return err
+			}
+
+			r.Statuses = statuses
+		default:
+			return fmt.Errorf("%w : unknown extension %d", ErrInvalidEncoding, extension)
+		}
+	}
+
+	return nil
+}
+
+func readStatuses(input *cryptobyte.String) ([]ElementStatus, error) {
+	var count uint16
+	if !input.ReadUint16(&count) {
+		return nil, fmt.Errorf("%w : truncated statuses", ErrInvalidEncoding)
+	}
+
+	statuses := make([]ElementStatus, count)
+
+	for index := range statuses {
+		var evaluated uint8
+		if !input.ReadUint8(&evaluated) {
+			return nil, fmt.Errorf("%w : truncated status %d", ErrInvalidEncoding, index)
+		}
+
+		if evaluated == 1 {
+			statuses[index].Evaluated = true
+
+			continue
+		}
+
+		var code, message cryptobyte.String
+		if !input.ReadUint8LengthPrefixed(&code) || !input.ReadUint16LengthPrefixed(&message) {
+			return nil, fmt.Errorf("%w : truncated status %d", ErrInvalidEncoding, index)
+		}
+
+		// the status of the error isn't encoded, as in the other encodings
+		statuses[index].Error = &Error{Code: ErrorCode(code), Message: string(message), Status: 0}
+	}
+
+	if !input.Empty() {
+		return nil, fmt.Errorf("%w : trailing statuses", ErrInvalidEncoding)
+	}
+
+	return statuses, nil
+}
+
+// UnmarshalBinary decodes a response encoded by MarshalBinary.
+func (r *EvaluationResponse) UnmarshalBinary(data []byte) error {
+	input := cryptobyte.String(data)
+
+	var suite, keyID, publicKey, proof cryptobyte.String
+
+	if !input.ReadUint8LengthPrefixed(&suite) || !input.ReadUint8LengthPrefixed(&keyID) ||
+		!input.ReadUint16(&r.ShareIndex) || !input.ReadUint16LengthPrefixed(&publicKey) {
+		return fmt.Errorf("%w : truncated response", ErrInvalidEncoding)
+	}
+
+	r.Suite, r.KeyID, r.SerializedPublicKey = string(suite), string(keyID), publicKey
+	r.Evaluation, r.Info, r.Groups, r.Statuses = nil, nil, nil, nil
+
+	elementLength, err := compressedElementLength(r.Suite)
+	if err != nil {
+		return err
+	}
+
+	// the count of a grouped response is 0
+	if groupedInput, count := input, uint16(0); groupedInput.ReadUint16(&count) && count == 0 {
+		if r.Groups, err = readGroups(&groupedInput, elementLength); err != nil {
+			return err
+		}
+
+		return r.readExtensions(&groupedInput)
+	}
+
+	elements, err := readElements(&input, elementLength)
+	if err != nil {
+		return err
+	}
+
+	if !input.ReadUint16LengthPrefixed(&proof) {
+		return fmt.Errorf("%w : invalid proof", ErrInvalidEncoding)
+	}
+
+	r.Evaluation = &Evaluation{Proof: proof, Elements: elements}
+
+	return r.readExtensions(&input)
+}
+
+// EncodeEvaluationRequest encodes the request with the encoding of the content type, JSON by default.
+func EncodeEvaluationRequest(contentType string, evaluationRequest *EvaluationRequest) ([]byte, error) {
+	return encode(contentType, evaluationRequest)
+}
+
+// DecodeEvaluationRequest decodes the request with the encoding of the content type, JSON by default.
+func DecodeEvaluationRequest(contentType string, data []byte, evaluationRequest *EvaluationRequest) error {
+	return decode(contentType, data, evaluationRequest)
+}
+
+// DecodeV1EvaluationRequest decodes a request of the v1 API, whose JSON and CBOR encodings have the number
+// of the mode. The binary encoding is the same in both versions.
+func DecodeV1EvaluationRequest(contentType string, data []byte, evaluationRequest *EvaluationRequest) error {
+	if MediaType(contentType) == ContentTypeBinary {
+		return evaluationRequest.UnmarshalBinary(data)
+	}
+
+	var v1EvaluationRequest V1EvaluationRequest
+	if err := decode(contentType, data, &v1EvaluationRequest); err != nil {
+		return err
+	}
+
+	*evaluationRequest = *v1EvaluationRequest.V2()
+
+	return nil
+}
+
+// EncodeEvaluationResponse encodes the response with the encoding of the content type, JSON by default.
+func EncodeEvaluationResponse(contentType string, evaluationResponse *EvaluationResponse) ([]byte, error) {
+	return encode(contentType, evaluationResponse)
+}
+
+// DecodeEvaluationResponse decodes the response with the encoding of the content type, JSON by default.
+func DecodeEvaluationResponse(contentType string, data []byte, evaluationResponse *EvaluationResponse) error {
+	if err := decode(contentType, data, evaluationResponse); err != nil {
+		return err
+	}
+
+	if evaluationResponse.Evaluation == nil && len(evaluationResponse.Groups) == 0 {
+		return fmt.Errorf("%w : the response has no evaluation", ErrInvalidEncoding)
+	}
+
+	return nil
+}
+
+// NegotiateContentType returns the first supported encoding of the Accept header. The encoding of the request is
+// used if the client accepts any encoding.
+func NegotiateContentType(accept, requestContentType string) string {
+	for _, accepted := range strings.Split(accept, ",") {
+		switch contentType := MediaType(accepted); contentType {
+		case ContentTypeJSON, ContentTypeCBOR, ContentTypeBinary:
+			return contentType
+		case "", "*/*", "application/*":
+			if contentType := MediaType(requestContentType); contentType == ContentTypeCBOR ||
+				contentType == ContentTypeBinary {
+				return contentType
+			}
+
+			return ContentTypeJSON
+		}
+	}
+
+	return ContentTypeJSON
+}
+
+// MediaType returns the media type of a header value without its parameters.
+func MediaType(value string) string {
+	parsedMediaType, _, err := mime.ParseMediaType(strings.TrimSpace(value))
+	if err != nil {
+		return ""
+	}
+
+	return parsedMediaType
+}
+
+func encode(contentType string, message interface{}) ([]byte, error) {
+	switch MediaType(contentType) {
+	case ContentTypeCBOR:
+		return cborEncMode.Marshal(message) //nolint:wrapcheck
+	case ContentTypeBinary:
+		binaryMessage, ok := message.(interface{ MarshalBinary() ([]byte, error) })
+		if !ok {
+			return nil, fmt.Errorf("%w : no binary encoding of %T", ErrUnknownEncoding, message)
+		}
+
+		return binaryMessage.MarshalBinary()
+	default:
+		return json.Marshal(message) //nolint:wrapcheck
+	}
+}
+
+func decode(contentType string, data []byte, message interface{}) error {
+	switch MediaType(contentType) {
+	case ContentTypeCBOR:
+		return cborDecMode.Unmarshal(data, message) //nolint:wrapcheck
+	case ContentTypeBinary:
+		binaryMessage, ok := message.(interface{ UnmarshalBinary(data []byte) error })
+		if !ok {
+			return fmt.Errorf("%w : no binary encoding of %T", ErrUnknownEncoding, message)
+		}
+
+		return binaryMessage.UnmarshalBinary(data)
+	default:
+		return json.Unmarshal(data, message) //nolint:wrapcheck
+	}
+}
+
+func compressedElementLength(suiteID string) (int, error) {
+	suite, err := oprf.GetSuite(suiteID)
+	if err != nil {
+		return 0, NewError(CodeUnknownSuite, "the suite %q isn't supported", suiteID)
+	}
+
+	return int(suite.Group().Params().CompressedElementLength), nil
+}
+
+// addElements adds the number of elements on 2 bytes and the fixed-length elements.
+func addElements(builder *cryptobyte.Builder, elements [][]byte, elementLength int) error {
+	if len(elements) > math.MaxUint16 {
+		return fmt.Errorf("%w : %d elements", ErrInvalidEncoding, len(elements))
+	}
+
+	builder.AddUint16(uint16(len(elements)))
+
+	for _, element := range elements {
+		if len(element) != elementLength {
+			return fmt.Errorf("%w : %d bytes element, expected %d", ErrInvalidEncoding, len(element), elementLength)
+		}
+
+		builder.AddBytes(element)
+	}
+
+	return nil
+}
+
+// readElements reads the number of elements on 2 bytes and the fixed-length elements.
+func readElements(input *cryptobyte.String, elementLength int) ([][]byte, error) {
+	var count uint16
+	if !input.ReadUint16(&count) || len(*input) < int(count)*elementLength {
+		return nil, fmt.Errorf("%w : truncated elements", ErrInvalidEncoding)
+	}
+
+	elements := make([][]byte, count)
+
+	for index := range elements {
+		if !input.ReadBytes(&elements[index], elementLength) {
+			return nil, fmt.Errorf("%w : truncated element %d", ErrInvalidEncoding, index)
+		}
+	}
+
+	return elements, nil
+}
+
+// addGroups adds the groups of a grouped response.
+func addGroups(builder *cryptobyte.Builder, groups []EvaluationGroup, elementLength int) error {
+	if len(groups) > math.MaxUint16 {
+		return fmt.Errorf("%w : %d groups", ErrInvalidEncoding, len(groups))
+	}
+
+	builder.AddUint16(uint16(len(groups)))
+
+	for index, group := range groups {
+		if group.Evaluation == nil || len(group.Indices) != len(group.Evaluation.Elements) {
+			return fmt.Errorf("%w : group %d doesn't have an element per index", ErrInvalidEncoding, index)
+		}
+
+		builder.AddUint16(uint16(len(group.Indices)))
+
+		for _, elementIndex := range group.Indices {
+			builder.AddUint16(elementIndex)
+		}
+
+		for _, element := range group.Evaluation.Elements {
+			if len(element) != elementLength {
+				return fmt.Errorf("%w : %d bytes element, expected %d", ErrInvalidEncoding, len(element),
+					elementLength)
+			}
+
+			builder.AddBytes(element)
+		}
+
+		addUint16LengthPrefixed(builder, group.Evaluation.Proof)
+		addUint16LengthPrefixed(builder, group.Info)
+	}
+
+	return nil
+}
+
+// readGroups reads the groups of a grouped response, followed by the extensions.
+func readGroups(input *cryptobyte.String, elementLength int) ([]EvaluationGroup, error) {
+	var count uint16
+	if !input.ReadUint16(&count) || count == 0 {
+		return nil, fmt.Errorf("%w : no group", ErrInvalidEncoding)
+	}
+
+	groups := make([]EvaluationGroup, count)
+
+	for index := range groups {
+		var elementCount uint16
+		if !input.ReadUint16(&elementCount) || elementCount == 0 {
+			return nil, fmt.Errorf("%w : empty group %d", ErrInvalidEncoding, index)
+		}
+
+		indices := make([]uint16, elementCount)
+		for indexIndex := range indices {
+			if !input.ReadUint16(&indices[indexIndex]) {
+				return nil, fmt.Errorf("%w : truncated group %d", ErrInvalidEncoding, index)
+			}
+		}
+
+		elements := make([][]byte, elementCount)
+		for elementIndex := range elements {
+			if !input.ReadBytes(&elements[elementIndex], elementLength) {
+				return nil, fmt.Errorf("%w : truncated group %d", ErrInvalidEncoding, index)
+			}
+		}
+
+		var proof, info cryptobyte.String
+		if !input.ReadUint16LengthPrefixed(&proof) || !input.ReadUint16LengthPrefixed(&info) {
+			return nil, fmt.Errorf("%w : truncated group %d", ErrInvalidEncoding, index)
+		}
+
+		groups[index] = EvaluationGroup{
+			Indices:    indices,
+			Evaluation: &Evaluation{Proof: proof, Elements: elements},
+			Info:       nil,
+		}
+
+		if len(info) > 0 {
+			groups[index].Info = info
+		}
+	}
+
+	return groups, nil
+}
+
+func addUint8LengthPrefixed(builder *cryptobyte.Builder, data []byte) {
+	builder.AddUint8LengthPrefixed(func(child *cryptobyte.Builder) {
+		child.AddBytes(data)
+	})
+}
+
+func addUint16LengthPrefixed(builder *cryptobyte.Builder, data []byte) {
+	builder.AddUint16LengthPrefixed(func(child *cryptobyte.Builder) {
+		child.AddBytes(data)
+	})
+}
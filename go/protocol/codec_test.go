@@ -0,0 +1,267 @@
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+func newTestEvaluationRequest(t *testing.T, suite oprf.Suite, mode Mode) *EvaluationRequest {
+	t.Helper()
+
+	_, oprfEvaluationRequest, err := oprf.NewClient(suite).Blind([][]byte{{0x00}, {0xFF}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blindedElements := make([][]byte, len(oprfEvaluationRequest.Elements))
+	for index, element := range oprfEvaluationRequest.Elements {
+		blindedElements[index], _ = element.MarshalBinaryCompress()
+	}
+
+	return &EvaluationRequest{
+		Suite:           suite.Identifier(),
+		Mode:            mode,
+		Info:            []byte{0x00, 0xFF, 0x10, 0x80},
+		BlindedElements: blindedElements,
+		KeyID:           "2021-12",
+	}
+}
+
+func TestEvaluationRequestEncodings(t *testing.T) {
+	evaluationRequest := newTestEvaluationRequest(t, oprf.SuiteP384, ModePartialOblivious)
+
+	for _, contentType := range []string{ContentTypeJSON, ContentTypeCBOR, ContentTypeBinary} {
+		data, err := EncodeEvaluationRequest(contentType, evaluationRequest)
+		if err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		decodedRequest := new(EvaluationRequest)
+		if err := DecodeEvaluationRequest(contentType, data, decodedRequest); err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		if !reflect.DeepEqual(evaluationRequest, decodedRequest) {
+			t.Errorf("%s : decoded %v, expected %v", contentType, decodedRequest, evaluationRequest)
+		}
+	}
+
+	data, _ := evaluationRequest.MarshalBinary()
+	for _, invalid := range [][]byte{data[:len(data)-1], append(data, 0x00), {}} {
+		if err := new(EvaluationRequest).UnmarshalBinary(invalid); !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("invalid request %x decoded : %v", invalid, err)
+		}
+	}
+
+	// the uncompressed elements don't have the fixed length
+	_, oprfEvaluationRequest, _ := oprf.NewClient(oprf.SuiteP384).Blind([][]byte{{0x00}})
+	evaluationRequest.BlindedElements[0], _ = oprfEvaluationRequest.Elements[0].MarshalBinary()
+
+	if _, err := evaluationRequest.MarshalBinary(); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("uncompressed element encoded : %v", err)
+	}
+}
+
+func TestV1EvaluationRequest(t *testing.T) {
+	evaluationRequest := new(EvaluationRequest)
+
+	data := []byte(`{"suite": "P256-SHA256", "mode": 2, "info": "test info", "blinded_elements": []}`)
+	if err := DecodeV1EvaluationRequest(ContentTypeJSON, data, evaluationRequest); err != nil {
+		t.Fatal(err)
+	}
+
+	// the v1 public information is the text of the string
+	if evaluationRequest.Mode != ModePartialOblivious || evaluationRequest.Suite != "P256-SHA256" ||
+		string(evaluationRequest.Info) != "test info" {
+		t.Errorf("unexpected v2 request %v", evaluationRequest)
+	}
+
+	// the numeric modes are rejected by the v2 API
+	if err := DecodeEvaluationRequest(ContentTypeJSON, data, evaluationRequest); err == nil {
+		t.Errorf("numeric mode decoded as a v2 request")
+	}
+
+	if _, err := NewMode(3).OPRFMode(); !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("unknown mode accepted : %v", err)
+	}
+}
+
+func TestEvaluationResponseEncodings(t *testing.T) {
+	suite := oprf.SuiteP256
+	evaluationRequest := newTestEvaluationRequest(t, suite, ModeVerifiable)
+
+	evaluationResponse := &EvaluationResponse{
+		Evaluation: &Evaluation{
+			Proof:    make([]byte, 2*suite.Group().Params().ScalarLength),
+			Elements: evaluationRequest.BlindedElements,
+		},
+		Suite:               suite.Identifier(),
+		KeyID:               "2021-12",
+		SerializedPublicKey: evaluationRequest.BlindedElements[0],
+		ShareIndex:          2,
+		Info:                []byte{0x00, 0xFF},
+	}
+
+	for _, contentType := range []string{ContentTypeJSON, ContentTypeCBOR, ContentTypeBinary} {
+		data, err := EncodeEvaluationResponse(contentType, evaluationResponse)
+		if err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		decodedResponse := new(EvaluationResponse)
+		if err := DecodeEvaluationResponse(contentType, data, decodedResponse); err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		if !reflect.DeepEqual(evaluationResponse, decodedResponse) {
+			t.Errorf("%s : decoded %v, expected %v", contentType, decodedResponse, evaluationResponse)
+		}
+	}
+
+	// the errors are JSON encoded
+	if err := DecodeEvaluationResponse(ContentTypeJSON, []byte(`{"message": "error"}`),
+		new(EvaluationResponse)); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("error decoded as an evaluation : %v", err)
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	for _, test := range []struct {
+		accept, requestContentType, expected string
+	}{
+		{"", ContentTypeJSON, ContentTypeJSON},
+		{"", ContentTypeCBOR, ContentTypeCBOR},
+		{"*/*", ContentTypeBinary + "; charset=binary", ContentTypeBinary},
+		{"text/html, application/octet-stream;q=0.9", ContentTypeJSON, ContentTypeBinary},
+		{ContentTypeCBOR, ContentTypeBinary, ContentTypeCBOR},
+		{"text/html", ContentTypeBinary, ContentTypeJSON},
+	} {
+		if contentType := NegotiateContentType(test.accept, test.requestContentType); contentType != test.expected {
+			t.Errorf("%s request accepting %q : %s response, expected %s", test.requestContentType, test.accept,
+				contentType, test.expected)
+		}
+	}
+}
+
+func TestGroupedEncodings(t *testing.T) {
+	evaluationRequest := newTestEvaluationRequest(t, oprf.SuiteP256, ModePartialOblivious)
+	evaluationRequest.Info = nil
+	evaluationRequest.Infos = [][]byte{[]byte("survey"), {0x00, 0xFF}}
+
+	evaluationResponse := &EvaluationResponse{
+		Evaluation:          nil,
+		Suite:               evaluationRequest.Suite,
+		KeyID:               "2021-12",
+		SerializedPublicKey: evaluationRequest.BlindedElements[0],
+		ShareIndex:          0,
+		Info:                nil,
+		Groups: []EvaluationGroup{
+			{Indices: []uint16{1}, Evaluation: &Evaluation{
+				Proof:    []byte{0x01},
+				Elements: evaluationRequest.BlindedElements[1:],
+			}, Info: nil},
+			{Indices: []uint16{0}, Evaluation: &Evaluation{
+				Proof:    []byte{0x02},
+				Elements: evaluationRequest.BlindedElements[:1],
+			}, Info: []byte("injected")},
+		},
+	}
+
+	for _, contentType := range []string{ContentTypeJSON, ContentTypeCBOR, ContentTypeBinary} {
+		data, err := EncodeEvaluationRequest(contentType, evaluationRequest)
+		if err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		decodedRequest := new(EvaluationRequest)
+		if err := DecodeEvaluationRequest(contentType, data, decodedRequest); err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		if !reflect.DeepEqual(evaluationRequest, decodedRequest) {
+			t.Errorf("%s : decoded %v, expected %v", contentType, decodedRequest, evaluationRequest)
+		}
+
+		data, err = EncodeEvaluationResponse(contentType, evaluationResponse)
+		if err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		decodedResponse := new(EvaluationResponse)
+		if err := DecodeEvaluationResponse(contentType, data, decodedResponse); err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		if !reflect.DeepEqual(evaluationResponse, decodedResponse) {
+			t.Errorf("%s : decoded %v, expected %v", contentType, decodedResponse, evaluationResponse)
+		}
+	}
+
+	// the request has an info per element
+	evaluationRequest.Infos = evaluationRequest.Infos[:1]
+	if _, err := evaluationRequest.MarshalBinary(); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("missing info encoded : %v", err)
+	}
+}
+
+func TestPartialSuccessEncodings(t *testing.T) {
+	evaluationRequest := newTestEvaluationRequest(t, oprf.SuiteP256, ModeVerifiable)
+	evaluationRequest.PartialSuccess = true
+
+	evaluationResponse := &EvaluationResponse{
+		Evaluation: &Evaluation{
+			Proof:    []byte{0x01},
+			Elements: evaluationRequest.BlindedElements[1:],
+		},
+		Suite:               evaluationRequest.Suite,
+		KeyID:               "2021-12",
+		SerializedPublicKey: evaluationRequest.BlindedElements[0],
+		ShareIndex:          0,
+		Info:                []byte("injected"),
+		Groups:              nil,
+		Statuses: []ElementStatus{
+			{Evaluated: false, Error: &Error{Code: CodeInvalidElement, Message: "element 0", Status: 0}},
+			{Evaluated: true, Error: nil},
+		},
+	}
+
+	for _, contentType := range []string{ContentTypeJSON, ContentTypeCBOR, ContentTypeBinary} {
+		data, err := EncodeEvaluationRequest(contentType, evaluationRequest)
+		if err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		decodedRequest := new(EvaluationRequest)
+		if err := DecodeEvaluationRequest(contentType, data, decodedRequest); err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		if !reflect.DeepEqual(evaluationRequest, decodedRequest) {
+			t.Errorf("%s : decoded %v, expected %v", contentType, decodedRequest, evaluationRequest)
+		}
+
+		data, err = EncodeEvaluationResponse(contentType, evaluationResponse)
+		if err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		decodedResponse := new(EvaluationResponse)
+		if err := DecodeEvaluationResponse(contentType, data, decodedResponse); err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		if !reflect.DeepEqual(evaluationResponse, decodedResponse) {
+			t.Errorf("%s : decoded %v, expected %v", contentType, decodedResponse, evaluationResponse)
+		}
+	}
+
+	// an unknown extension is rejected
+	data, _ := evaluationResponse.MarshalBinary()
+	if err := new(EvaluationResponse).UnmarshalBinary(append(data, 0x03, 0x00, 0x00, 0x00)); !errors.Is(err,
+		ErrInvalidEncoding) {
+		t.Errorf("unknown extension decoded : %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrorCode is the stable machine-readable code of an API error
+type ErrorCode string
+
+const (
+	// CodeInvalidRequest is a request that can't be decoded or that is missing a field
+	CodeInvalidRequest ErrorCode = "invalid_request"
+	CodeUnknownSuite   ErrorCode = "unknown_suite"
+	// CodeUnsupportedMode is an unknown mode or a mode the key of the suite can't evaluate
+	CodeUnsupportedMode ErrorCode = "unsupported_mode"
+	// CodeInvalidElement is a blinded element that isn't an element of the group of the suite
+	CodeInvalidElement ErrorCode = "invalid_element"
+	CodeBatchTooLarge  ErrorCode = "batch_too_large"
+	CodeEmptyBatch     ErrorCode = "empty_batch"
+	CodeInfoTooLong    ErrorCode = "info_too_long"
+	// CodeInfoNotAllowed is a public information sent in a mode other than the partially oblivious mode
+	CodeInfoNotAllowed ErrorCode = "info_not_allowed"
+	// CodeInfoRejected is a public information refused by the info policy of the server
+	CodeInfoRejected ErrorCode = "info_rejected"
+	// CodeRequestTooLarge is a request body larger than the limit of the server
+	CodeRequestTooLarge ErrorCode = "request_too_large"
+	// CodeKeyUnavailable is a requested key ID that is unknown, retired or not yet valid
+	CodeKeyUnavailable     ErrorCode = "key_unavailable"
+	CodeUnauthorized       ErrorCode = "unauthorized"
+	CodeNotFound           ErrorCode = "not_found"
+	CodeMethodNotAllowed   ErrorCode = "method_not_allowed"
+	CodeServiceUnavailable ErrorCode = "service_unavailable"
+	CodeInternal           ErrorCode = "internal_error"
+)
+
+// codeStatuses maps the error codes to their HTTP status
+var codeStatuses = map[ErrorCode]int{
+	CodeInvalidRequest:     http.StatusBadRequest,
+	CodeUnknownSuite:       http.StatusBadRequest,
+	CodeUnsupportedMode:    http.StatusBadRequest,
+	CodeInvalidElement:     http.StatusBadRequest,
+	CodeBatchTooLarge:      http.StatusRequestEntityTooLarge,
+	CodeEmptyBatch:         http.StatusBadRequest,
+	CodeInfoTooLong:        http.StatusBadRequest,
+	CodeInfoNotAllowed:     http.StatusBadRequest,
+	CodeInfoRejected:       http.StatusBadRequest,
+	CodeRequestTooLarge:    http.StatusRequestEntityTooLarge,
+	CodeKeyUnavailable:     http.StatusUnprocessableEntity,
+	CodeUnauthorized:       http.StatusUnauthorized,
+	CodeNotFound:           http.StatusNotFound,
+	CodeMethodNotAllowed:   http.StatusMethodNotAllowed,
+	CodeServiceUnavailable: http.StatusServiceUnavailable,
+	CodeInternal:           http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status of the code, 500 for an unknown code.
+func (c ErrorCode) Status() int {
+	if status, ok := codeStatuses[c]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// StatusCode returns the generic code of an HTTP status.
+func StatusCode(status int) ErrorCode {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return CodeUnauthorized
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		return CodeRequestTooLarge
+	case http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	}
+
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+
+	return CodeInvalidRequest
+}
+
+// Error is the JSON body of the API errors. The errors are compared with errors.Is by their code.
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// Status is the HTTP status of the response, the status of the code by default
+	Status int `json:"-"`
+}
+
+// Sentinel errors of each code, for errors.Is
+//
+//nolint:exhaustivestruct
+var (
+	ErrInvalidRequest     = &Error{Code: CodeInvalidRequest, Message: "invalid request"}
+	ErrUnknownSuite       = &Error{Code: CodeUnknownSuite, Message: "unknown suite"}
+	ErrUnsupportedMode    = &Error{Code: CodeUnsupportedMode, Message: "unsupported mode"}
+	ErrInvalidElement     = &Error{Code: CodeInvalidElement, Message: "invalid element"}
+	ErrBatchTooLarge      = &Error{Code: CodeBatchTooLarge, Message: "batch too large"}
+	ErrEmptyBatch         = &Error{Code: CodeEmptyBatch, Message: "empty batch"}
+	ErrInfoTooLong        = &Error{Code: CodeInfoTooLong, Message: "info too long"}
+	ErrInfoNotAllowed     = &Error{Code: CodeInfoNotAllowed, Message: "info not allowed"}
+	ErrInfoRejected       = &Error{Code: CodeInfoRejected, Message: "info rejected"}
+	ErrRequestTooLarge    = &Error{Code: CodeRequestTooLarge, Message: "request too large"}
+	ErrKeyUnavailable     = &Error{Code: CodeKeyUnavailable, Message: "key unavailable"}
+	ErrUnauthorized       = &Error{Code: CodeUnauthorized, Message: "unauthorized"}
+	ErrNotFound           = &Error{Code: CodeNotFound, Message: "not found"}
+	ErrMethodNotAllowed   = &Error{Code: CodeMethodNotAllowed, Message: "method not allowed"}
+	ErrServiceUnavailable = &Error{Code: CodeServiceUnavailable, Message: "service unavailable"}
+	ErrInternal           = &Error{Code: CodeInternal, Message: "internal error"}
+)
+
+// NewError returns an error of the code with the status of the code.
+func NewError(code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...), Status: code.Status()}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s : %s", e.Code, e.Message)
+}
+
+// Is reports whether the target is an error of the same code.
+func (e *Error) Is(target error) bool {
+	targetError, ok := target.(*Error) //nolint:errorlint
+	if !ok {
+		return false
+	}
+
+	return targetError.Code == e.Code
+}
+
+// HTTPStatus returns the HTTP status of the error.
+func (e *Error) HTTPStatus() int {
+	if e.Status != 0 {
+		return e.Status
+	}
+
+	return e.Code.Status()
+}
+
+// DecodeError decodes the JSON body of an error response. A body without a code, such as the error page of a proxy
+// or the error of a v1 server, is returned with the code of the status.
+func DecodeError(status int, data []byte) *Error {
+	apiError := new(Error)
+	if err := json.Unmarshal(data, apiError); err != nil || apiError.Message == "" {
+		apiError.Message = strings.TrimSpace(string(data))
+	}
+
+	if apiError.Message == "" {
+		apiError.Message = http.StatusText(status)
+	}
+
+	if apiError.Code == "" {
+		apiError.Code = StatusCode(status)
+	}
+
+	apiError.Status = status
+
+	return apiError
+}
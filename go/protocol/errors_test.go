@@ -0,0 +1,46 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	err := fmt.Errorf("evaluation : %w", NewError(CodeInvalidElement, "blinded element %d", 3))
+
+	if !errors.Is(err, ErrInvalidElement) {
+		t.Errorf("%v is not an invalid element error", err)
+	}
+
+	if errors.Is(err, ErrUnknownSuite) {
+		t.Errorf("%v is an unknown suite error", err)
+	}
+
+	var apiError *Error
+	if !errors.As(err, &apiError) || apiError.HTTPStatus() != http.StatusBadRequest {
+		t.Errorf("%v, expected a 400 error", err)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	for _, test := range []struct {
+		status  int
+		body    string
+		code    ErrorCode
+		message string
+	}{
+		{http.StatusUnprocessableEntity, `{"code":"key_unavailable","message":"unknown key ID"}`, CodeKeyUnavailable,
+			"unknown key ID"},
+		// the errors of a v1 server and of a proxy have no code
+		{http.StatusBadRequest, `{"message":"No server"}`, CodeInvalidRequest, "No server"},
+		{http.StatusBadGateway, "Bad Gateway\n", CodeInternal, "Bad Gateway"},
+		{http.StatusNotFound, "", CodeNotFound, "Not Found"},
+	} {
+		apiError := DecodeError(test.status, []byte(test.body))
+		if apiError.Code != test.code || apiError.Message != test.message || apiError.HTTPStatus() != test.status {
+			t.Errorf("%d %q decoded as %v (%d)", test.status, test.body, apiError, apiError.HTTPStatus())
+		}
+	}
+}
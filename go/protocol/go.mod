@@ -0,0 +1,15 @@
+module github.com/ensimag-oprf/go/protocol
+
+go 1.17
+
+require (
+	github.com/cloudflare/circl v1.3.7
+	github.com/fxamacker/cbor/v2 v2.7.0
+	golang.org/x/crypto v0.17.0
+)
+
+require (
+	github.com/bwesterb/go-ristretto v1.2.3 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+)
@@ -0,0 +1,130 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// InfoEncoding is the encoding of the public information in the JSON requests
+type InfoEncoding string
+
+const (
+	InfoEncodingBase64 InfoEncoding = "base64"
+	InfoEncodingHex    InfoEncoding = "hex"
+)
+
+var ErrUnknownInfoEncoding = errors.New("unknown info encoding")
+
+// EncodeInfo encodes the public information, base64 by default.
+func EncodeInfo(info []byte, encoding InfoEncoding) (string, error) {
+	switch encoding {
+	case "", InfoEncodingBase64:
+		return base64.StdEncoding.EncodeToString(info), nil
+	case InfoEncodingHex:
+		return hex.EncodeToString(info), nil
+	default:
+		return "", unknownInfoEncoding(encoding)
+	}
+}
+
+// DecodeInfo decodes the public information, base64 by default.
+func DecodeInfo(info string, encoding InfoEncoding) ([]byte, error) {
+	var (
+		decoded []byte
+		err     error
+	)
+
+	switch encoding {
+	case "", InfoEncodingBase64:
+		decoded, err = base64.StdEncoding.DecodeString(info)
+	case InfoEncodingHex:
+		decoded, err = hex.DecodeString(info)
+	default:
+		return nil, unknownInfoEncoding(encoding)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s info : %s", ErrInvalidEncoding, encoding, err.Error())
+	}
+
+	if len(decoded) == 0 {
+		return nil, nil
+	}
+
+	return decoded, nil
+}
+
+func unknownInfoEncoding(encoding InfoEncoding) error {
+	return fmt.Errorf("%w : %q, expected %q or %q", ErrUnknownInfoEncoding, string(encoding), InfoEncodingBase64,
+		InfoEncodingHex)
+}
+
+// evaluationRequest has the fields of the EvaluationRequest without its methods
+type evaluationRequest EvaluationRequest
+
+// MarshalJSON encodes the public information with the InfoEncoding of the request.
+func (r *EvaluationRequest) MarshalJSON() ([]byte, error) {
+	info, err := EncodeInfo(r.Info, r.InfoEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []string
+
+	for _, elementInfo := range r.Infos {
+		encodedInfo, err := EncodeInfo(elementInfo, r.InfoEncoding)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, encodedInfo)
+	}
+
+	return json.Marshal(&struct { //nolint:wrapcheck
+		*evaluationRequest
+		Info  string   `json:"info,omitempty"`
+		Infos []string `json:"infos,omitempty"`
+	}{
+		evaluationRequest: (*evaluationRequest)(r),
+		Info:              info,
+		Infos:             infos,
+	})
+}
+
+// UnmarshalJSON decodes the public information with the declared info_encoding.
+func (r *EvaluationRequest) UnmarshalJSON(data []byte) error {
+	wireRequest := struct {
+		*evaluationRequest
+		Info  string   `json:"info"`
+		Infos []string `json:"infos"`
+	}{
+		evaluationRequest: (*evaluationRequest)(r),
+		Info:              "",
+		Infos:             nil,
+	}
+
+	if err := json.Unmarshal(data, &wireRequest); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	info, err := DecodeInfo(wireRequest.Info, r.InfoEncoding)
+	if err != nil {
+		return err
+	}
+
+	r.Info, r.Infos = info, nil
+
+	for _, elementInfo := range wireRequest.Infos {
+		decodedInfo, err := DecodeInfo(elementInfo, r.InfoEncoding)
+		if err != nil {
+			return err
+		}
+
+		r.Infos = append(r.Infos, decodedInfo)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestInfoEncodings(t *testing.T) {
+	info := []byte{0x00, 0xFF, 0x10, 0x80}
+
+	for _, test := range []struct {
+		encoding InfoEncoding
+		encoded  string
+	}{
+		{"", "AP8QgA=="},
+		{InfoEncodingBase64, "AP8QgA=="},
+		{InfoEncodingHex, "00ff1080"},
+	} {
+		encoded, err := EncodeInfo(info, test.encoding)
+		if err != nil || encoded != test.encoded {
+			t.Errorf("%q : encoded %q (%v), expected %q", test.encoding, encoded, err, test.encoded)
+		}
+
+		request := &EvaluationRequest{Mode: ModePartialOblivious, Info: info, InfoEncoding: test.encoding}
+
+		data, err := json.Marshal(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decodedRequest := new(EvaluationRequest)
+		if err := json.Unmarshal(data, decodedRequest); err != nil {
+			t.Fatal(test.encoding, err)
+		}
+
+		if !bytes.Equal(decodedRequest.Info, info) {
+			t.Errorf("%s : decoded info %x, expected %x", data, decodedRequest.Info, info)
+		}
+	}
+
+	if _, err := DecodeInfo("00ff1080", "utf-8"); !errors.Is(err, ErrUnknownInfoEncoding) {
+		t.Errorf("unknown info encoding accepted : %v", err)
+	}
+
+	// a text info isn't silently decoded
+	data := []byte(`{"suite": "P256-SHA256", "mode": "partial-oblivious", "info": "test info", "info_encoding": "hex"}`)
+	if err := json.Unmarshal(data, new(EvaluationRequest)); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("text info decoded as hex : %v", err)
+	}
+}
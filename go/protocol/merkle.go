@@ -0,0 +1,201 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// Prefixes of the RFC 9162 hashes, separating the leaves from the nodes
+const (
+	leafHashPrefix = 0x00
+	nodeHashPrefix = 0x01
+)
+
+// LeafHash is the RFC 9162 hash of the leaf data.
+func LeafHash(data []byte) []byte {
+	hash := sha256.Sum256(append([]byte{leafHashPrefix}, data...))
+
+	return hash[:]
+}
+
+// NodeHash is the RFC 9162 hash of an interior node.
+func NodeHash(left, right []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte{nodeHashPrefix})
+	hash.Write(left)
+	hash.Write(right)
+
+	return hash.Sum(nil)
+}
+
+// RootHash is the RFC 9162 root hash of the tree of the leaf hashes.
+func RootHash(leafHashes [][]byte) []byte {
+	switch len(leafHashes) {
+	case 0:
+		hash := sha256.Sum256(nil)
+
+		return hash[:]
+	case 1:
+		return leafHashes[0]
+	}
+
+	split := splitSize(len(leafHashes))
+
+	return NodeHash(RootHash(leafHashes[:split]), RootHash(leafHashes[split:]))
+}
+
+// NewInclusionProof returns the audit path of the leaf at the index in the tree of the leaf hashes (RFC 9162 2.1.3.1).
+func NewInclusionProof(leafHashes [][]byte, index uint64) *InclusionProof {
+	return &InclusionProof{
+		LeafIndex: index,
+		TreeSize:  uint64(len(leafHashes)),
+		AuditPath: auditPath(leafHashes, int(index)),
+	}
+}
+
+func auditPath(leafHashes [][]byte, index int) [][]byte {
+	if len(leafHashes) <= 1 {
+		return nil
+	}
+
+	split := splitSize(len(leafHashes))
+	if index < split {
+		return append(auditPath(leafHashes[:split], index), RootHash(leafHashes[split:]))
+	}
+
+	return append(auditPath(leafHashes[split:], index-split), RootHash(leafHashes[:split]))
+}
+
+// NewConsistencyProof returns the proof that the tree of the first leaves is a prefix of the tree of the leaf hashes
+// (RFC 9162 2.1.4.1).
+func NewConsistencyProof(leafHashes [][]byte, firstSize uint64) *ConsistencyProof {
+	proof := &ConsistencyProof{FirstSize: firstSize, SecondSize: uint64(len(leafHashes)), Path: nil}
+	if firstSize > 0 && firstSize < proof.SecondSize {
+		proof.Path = subProof(leafHashes, int(firstSize), true)
+	}
+
+	return proof
+}
+
+func subProof(leafHashes [][]byte, size int, complete bool) [][]byte {
+	if size == len(leafHashes) {
+		if complete {
+			return nil
+		}
+
+		return [][]byte{RootHash(leafHashes)}
+	}
+
+	split := splitSize(len(leafHashes))
+	if size <= split {
+		return append(subProof(leafHashes[:split], size, complete), RootHash(leafHashes[split:]))
+	}
+
+	return append(subProof(leafHashes[split:], size-split, false), RootHash(leafHashes[:split]))
+}
+
+// splitSize is the largest power of 2 strictly smaller than the number of leaves, 1 for 2 leaves (RFC 9162 2.1.1).
+func splitSize(leaves int) int {
+	split := 1
+	for split<<1 < leaves {
+		split <<= 1
+	}
+
+	return split
+}
+
+// VerifyInclusion checks the inclusion proof of the leaf hash in the tree of the root hash (RFC 9162 2.1.3.2).
+func VerifyInclusion(leafHash []byte, proof *InclusionProof, rootHash []byte) error {
+	if proof.LeafIndex >= proof.TreeSize {
+		return fmt.Errorf("%w : leaf %d of a tree of %d leaves", ErrInvalidProof, proof.LeafIndex, proof.TreeSize)
+	}
+
+	fn, sn, hash := proof.LeafIndex, proof.TreeSize-1, leafHash
+
+	for _, node := range proof.AuditPath {
+		if sn == 0 {
+			return fmt.Errorf("%w : the audit path is too long", ErrInvalidProof)
+		}
+
+		if fn&1 == 1 || fn == sn {
+			hash = NodeHash(node, hash)
+
+			for fn&1 == 0 && fn != 0 {
+				fn, sn = fn>>1, sn>>1
+			}
+		} else {
+			hash = NodeHash(hash, node)
+		}
+
+		fn, sn = fn>>1, sn>>1
+	}
+
+	if sn != 0 || !bytes.Equal(hash, rootHash) {
+		return fmt.Errorf("%w : the leaf isn't included in the tree", ErrInvalidProof)
+	}
+
+	return nil
+}
+
+// VerifyConsistency checks that the tree of the first root hash is a prefix of the tree of the second root hash
+// (RFC 9162 2.1.4.2).
+func VerifyConsistency(proof *ConsistencyProof, firstRootHash, secondRootHash []byte) error {
+	switch {
+	case proof.FirstSize > proof.SecondSize:
+		return fmt.Errorf("%w : the tree shrank from %d to %d leaves", ErrInvalidProof, proof.FirstSize,
+			proof.SecondSize)
+	case proof.FirstSize == proof.SecondSize:
+		if len(proof.Path) != 0 || !bytes.Equal(firstRootHash, secondRootHash) {
+			return fmt.Errorf("%w : different trees of %d leaves", ErrInvalidProof, proof.FirstSize)
+		}
+
+		return nil
+	case proof.FirstSize == 0:
+		// the empty tree is a prefix of any tree
+		return nil
+	}
+
+	path := proof.Path
+
+	// the first tree is a complete subtree of the second tree, its root hash starts the path
+	if proof.FirstSize&(proof.FirstSize-1) == 0 {
+		path = append([][]byte{firstRootHash}, path...)
+	}
+
+	if len(path) == 0 {
+		return fmt.Errorf("%w : empty consistency path", ErrInvalidProof)
+	}
+
+	fn, sn := proof.FirstSize-1, proof.SecondSize-1
+	for fn&1 == 1 {
+		fn, sn = fn>>1, sn>>1
+	}
+
+	firstHash, secondHash := path[0], path[0]
+
+	for _, node := range path[1:] {
+		if sn == 0 {
+			return fmt.Errorf("%w : the consistency path is too long", ErrInvalidProof)
+		}
+
+		if fn&1 == 1 || fn == sn {
+			firstHash, secondHash = NodeHash(node, firstHash), NodeHash(node, secondHash)
+
+			for fn&1 == 0 && fn != 0 {
+				fn, sn = fn>>1, sn>>1
+			}
+		} else {
+			secondHash = NodeHash(secondHash, node)
+		}
+
+		fn, sn = fn>>1, sn>>1
+	}
+
+	if sn != 0 || !bytes.Equal(firstHash, firstRootHash) || !bytes.Equal(secondHash, secondRootHash) {
+		return fmt.Errorf("%w : the trees of %d and %d leaves aren't consistent", ErrInvalidProof, proof.FirstSize,
+			proof.SecondSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMerkleProofs(t *testing.T) {
+	var leafHashes [][]byte
+
+	for size := 1; size <= 20; size++ {
+		entry := KeyLogEntry{Suite: "P256-SHA256", KeyID: string(rune('a' + size)), PublicKey: []byte{0x02}, State: "active"}
+
+		leafData, err := entry.LeafData()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		leafHashes = append(leafHashes, LeafHash(leafData))
+		rootHash := RootHash(leafHashes)
+
+		for index := range leafHashes {
+			proof := NewInclusionProof(leafHashes, uint64(index))
+			if err := VerifyInclusion(leafHashes[index], proof, rootHash); err != nil {
+				t.Errorf("leaf %d of %d : %v", index, size, err)
+			}
+
+			// the proof of a leaf doesn't prove another leaf
+			if err := VerifyInclusion(leafHashes[(index+1)%size], proof, rootHash); size > 1 &&
+				!errors.Is(err, ErrInvalidProof) {
+				t.Errorf("leaf %d of %d : the proof of another leaf is accepted", index, size)
+			}
+		}
+
+		for firstSize := 0; firstSize <= size; firstSize++ {
+			proof := NewConsistencyProof(leafHashes, uint64(firstSize))
+			firstRootHash := RootHash(leafHashes[:firstSize])
+
+			if err := VerifyConsistency(proof, firstRootHash, rootHash); err != nil {
+				t.Errorf("trees of %d and %d leaves : %v", firstSize, size, err)
+			}
+
+			// a first tree with another leaf isn't consistent
+			if firstSize > 0 && firstSize < size {
+				forked := append(append([][]byte{}, leafHashes[:firstSize-1]...), leafHashes[size-1])
+				if err := VerifyConsistency(proof, RootHash(forked), rootHash); !errors.Is(err, ErrInvalidProof) {
+					t.Errorf("trees of %d and %d leaves : a forked tree is consistent", firstSize, size)
+				}
+			}
+		}
+	}
+}
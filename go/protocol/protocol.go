@@ -0,0 +1,221 @@
+// Package protocol defines the wire protocol shared by the server and the client : the messages of the API,
+// their encodings and the named modes of the v2 API.
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+// Paths of the API, relative to the API root (/api)
+const (
+	// V2Path is the prefix of the v2 API. The v1 API is served at the API root.
+	V2Path             = "/v2"
+	PublicKeysEndpoint = "/request_public_keys"
+	EvaluateEndpoint   = "/evaluate"
+	HealthEndpoint     = "/health"
+	// FullEvaluateEndpoint is the endpoint of the trusted callers, served at the API root
+	FullEvaluateEndpoint = "/full_evaluate"
+	// VerifyEndpoint is the endpoint of the data stewards, served at the API root
+	VerifyEndpoint = "/verify"
+)
+
+// Mode is the name of an OPRF mode in the v2 API
+type Mode string
+
+const (
+	ModeBase             Mode = "base"
+	ModeVerifiable       Mode = "verifiable"
+	ModePartialOblivious Mode = "partial-oblivious"
+)
+
+// KeyStateActive is the state of the key used by the server when no key ID is requested
+const KeyStateActive = "active"
+
+var ErrUnknownMode = errors.New("unknown mode")
+
+// NewMode returns the name of the mode. An unknown mode is named after its number, so that it is rejected
+// by the server.
+func NewMode(mode oprf.Mode) Mode {
+	switch mode {
+	case oprf.BaseMode:
+		return ModeBase
+	case oprf.VerifiableMode:
+		return ModeVerifiable
+	case oprf.PartialObliviousMode:
+		return ModePartialOblivious
+	default:
+		return Mode(strconv.Itoa(int(mode)))
+	}
+}
+
+// OPRFMode returns the mode of the oprf package.
+func (m Mode) OPRFMode() (oprf.Mode, error) {
+	switch m {
+	case ModeBase:
+		return oprf.BaseMode, nil
+	case ModeVerifiable:
+		return oprf.VerifiableMode, nil
+	case ModePartialOblivious:
+		return oprf.PartialObliviousMode, nil
+	default:
+		return 0, fmt.Errorf("%w : %q, expected %q, %q or %q", ErrUnknownMode, string(m), ModeBase, ModeVerifiable,
+			ModePartialOblivious)
+	}
+}
+
+// EvaluationRequest is a request of evaluation of the blinded elements with a key of the suite
+type EvaluationRequest struct {
+	Suite string `json:"suite"`
+	Mode  Mode   `json:"mode"`
+	// Info is the public information of the partially oblivious mode. It is encoded in JSON with the InfoEncoding,
+	// base64 by default, and as raw bytes in CBOR and in the binary encoding.
+	Info         []byte       `json:"info,omitempty"`
+	InfoEncoding InfoEncoding `json:"info_encoding,omitempty" cbor:"-"`
+	// Infos are the public information of each blinded element in the partially oblivious mode, exclusive with Info.
+	// The elements sharing a public information are evaluated as a group, with their own proof.
+	Infos           [][]byte `json:"infos,omitempty"`
+	BlindedElements [][]byte `json:"blinded_elements"`
+	// KeyID selects a published key, the server uses its active key if empty
+	KeyID string `json:"kid,omitempty"`
+	// PartialSuccess evaluates the valid elements of the batch and reports a status per element, instead of failing
+	// the request on the first invalid element
+	PartialSuccess bool `json:"partial_success,omitempty"`
+}
+
+// V1EvaluationRequest is the evaluation request of the v1 API, with the number of the mode. Its public
+// information is the bytes of the string.
+type V1EvaluationRequest struct {
+	Suite           string    `json:"suite"`
+	Info            string    `json:"info"`
+	BlindedElements [][]byte  `json:"blinded_elements"`
+	Mode            oprf.Mode `json:"mode"`
+	KeyID           string    `json:"kid,omitempty"`
+}
+
+// V2 returns the v2 request. The v1 clients send an info in every mode : it is dropped outside of the partially
+// oblivious mode, which ignored it, rather than refused as in the v2 API.
+func (r *V1EvaluationRequest) V2() *EvaluationRequest {
+	var info []byte
+	if r.Mode == oprf.PartialObliviousMode {
+		info = []byte(r.Info)
+	}
+
+	return &EvaluationRequest{
+		Suite:           r.Suite,
+		Mode:            NewMode(r.Mode),
+		Info:            info,
+		BlindedElements: r.BlindedElements,
+		KeyID:           r.KeyID,
+	}
+}
+
+// Evaluation holds the serialized evaluated elements and the serialized proof, empty in base mode
+type Evaluation struct {
+	Proof    []byte   `json:"proof"`
+	Elements [][]byte `json:"elements"`
+}
+
+// EvaluationGroup is the evaluation of the elements of a batch sharing a public information
+type EvaluationGroup struct {
+	// Indices are the positions of the elements in the request, in the order of the evaluated elements
+	Indices    []uint16    `json:"indices"`
+	Evaluation *Evaluation `json:"evaluation"`
+	// Info is the evaluated public information if the server changed it
+	Info []byte `json:"info,omitempty"`
+}
+
+// EvaluationResponse is the evaluation with the key ID and the serialized public key of the key used. The evaluation
+// of a request with a public information per element is split in groups.
+type EvaluationResponse struct {
+	Evaluation          *Evaluation `json:"evaluation,omitempty"`
+	Suite               string      `json:"suite"`
+	KeyID               string      `json:"kid"`
+	SerializedPublicKey []byte      `json:"serialized_public_key"`
+	// ShareIndex is the index of the key share if the evaluation is partial
+	ShareIndex uint16 `json:"share_index,omitempty"`
+	// Info is the evaluated public information if the server changed it, for instance by injecting fields
+	Info   []byte            `json:"info,omitempty"`
+	Groups []EvaluationGroup `json:"groups,omitempty"`
+	// Statuses are the statuses of each element of a partial-success batch. The evaluation only has the evaluated
+	// elements, in the order of the request, and its proof only covers them.
+	Statuses []ElementStatus `json:"statuses,omitempty"`
+}
+
+// FullEvaluationRequest is a request of a trusted caller, computing the PRF outputs of the inputs without blinding.
+// It is only encoded in JSON.
+type FullEvaluationRequest struct {
+	Suite string `json:"suite"`
+	Mode  Mode   `json:"mode"`
+	// Info is the public information of the partially oblivious mode
+	Info   []byte   `json:"info,omitempty"`
+	Inputs [][]byte `json:"inputs"`
+	// KeyID selects a published key, the server uses its active key if empty
+	KeyID string `json:"kid,omitempty"`
+}
+
+// FullEvaluationResponse holds the PRF outputs of the inputs, in the order of the inputs
+type FullEvaluationResponse struct {
+	Suite   string   `json:"suite"`
+	KeyID   string   `json:"kid"`
+	Outputs [][]byte `json:"outputs"`
+	// Info is the evaluated public information if the server changed it
+	Info []byte `json:"info,omitempty"`
+}
+
+// VerificationRequest is a request of a data steward, checking that the output is the PRF output of the input and of
+// the public information with a key of the suite. It is only encoded in JSON.
+type VerificationRequest struct {
+	Suite string `json:"suite"`
+	Mode  Mode   `json:"mode"`
+	// Info is the public information of the partially oblivious mode, as evaluated by the server
+	Info   []byte `json:"info,omitempty"`
+	Input  []byte `json:"input"`
+	Output []byte `json:"output"`
+	// KeyID selects a key, including the retired keys, the server uses its active key if empty
+	KeyID string `json:"kid,omitempty"`
+}
+
+// VerificationResponse tells whether the output matches the input with the key
+type VerificationResponse struct {
+	Suite string `json:"suite"`
+	KeyID string `json:"kid"`
+	Match bool   `json:"match"`
+}
+
+// ElementStatus is the status of a blinded element of a partial-success batch
+type ElementStatus struct {
+	Evaluated bool `json:"evaluated"`
+	// Error is the reason why the element wasn't evaluated
+	Error *Error `json:"error,omitempty"`
+}
+
+// PublicKeyInfo is a published public key with its metadata. The public key of a key share is the public key of
+// the share, the public key of the key being the share group public key.
+type PublicKeyInfo struct {
+	ID string `json:"kid"`
+	// CreatedAt is omitted for a key without creation date, as a key derived from the master seed
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	State     string     `json:"state"`
+	PublicKey []byte     `json:"public_key"`
+	// Fingerprint is the hex encoded SHA-256 hash of the suite identifier and the public key
+	Fingerprint string        `json:"fingerprint"`
+	Share       *KeyShareInfo `json:"share,omitempty"`
+	// NotBefore and NotAfter bound the validity window of a key rotated on schedule. A pending key is published
+	// before its window so the client already knows it when it is promoted.
+	NotBefore      *time.Time `json:"not_before,omitempty"`
+	NotAfter       *time.Time `json:"not_after,omitempty"`
+	MaxEvaluations uint64     `json:"max_evaluations,omitempty"`
+}
+
+// KeyShareInfo describes the Shamir share of the server key held by a threshold evaluator
+type KeyShareInfo struct {
+	Index          uint16 `json:"index"`
+	Threshold      uint16 `json:"threshold"`
+	Total          uint16 `json:"total"`
+	GroupPublicKey []byte `json:"group_public_key"`
+}
@@ -0,0 +1,31 @@
+package protocol
+
+import (
+	"github.com/cloudflare/circl/group"
+)
+
+// LagrangeCoefficient returns the Lagrange coefficient of the share index at x = 0 for the set of share indices.
+func LagrangeCoefficient(suiteGroup group.Group, index uint16, indices []uint16) group.Scalar {
+	return LagrangeCoefficientAt(suiteGroup, 0, index, indices)
+}
+
+// LagrangeCoefficientAt returns the Lagrange coefficient of the share index at x for the set of share indices, so
+// the shares of a polynomial interpolate its value at x.
+func LagrangeCoefficientAt(suiteGroup group.Group, x uint16, index uint16, indices []uint16) group.Scalar {
+	numerator := suiteGroup.NewScalar().SetUint64(1)
+	denominator := suiteGroup.NewScalar().SetUint64(1)
+	xScalar := suiteGroup.NewScalar().SetUint64(uint64(x))
+	xi := suiteGroup.NewScalar().SetUint64(uint64(index))
+
+	for _, other := range indices {
+		if other == index {
+			continue
+		}
+
+		xj := suiteGroup.NewScalar().SetUint64(uint64(other))
+		numerator.Mul(numerator, suiteGroup.NewScalar().Sub(xj, xScalar))
+		denominator.Mul(denominator, suiteGroup.NewScalar().Sub(xj, xi))
+	}
+
+	return numerator.Mul(numerator, denominator.Inv(denominator))
+}
@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/cloudflare/circl/group"
+)
+
+func TestLagrangeCoefficientAt(t *testing.T) {
+	suiteGroup := group.P256
+
+	// f(x) = a + b x, any 2 shares interpolate f at every x
+	a, b := suiteGroup.RandomScalar(rand.Reader), suiteGroup.RandomScalar(rand.Reader)
+	f := func(x uint16) group.Scalar {
+		y := suiteGroup.NewScalar().Mul(b, suiteGroup.NewScalar().SetUint64(uint64(x)))
+
+		return y.Add(y, a)
+	}
+
+	indices := []uint16{2, 5}
+
+	for _, x := range []uint16{0, 1, 2, 7} {
+		interpolated := suiteGroup.NewScalar()
+		for _, index := range indices {
+			term := suiteGroup.NewScalar().Mul(f(index), LagrangeCoefficientAt(suiteGroup, x, index, indices))
+			interpolated.Add(interpolated, term)
+		}
+
+		if !interpolated.IsEqual(f(x)) {
+			t.Errorf("the shares don't interpolate f(%d)", x)
+		}
+	}
+
+	if !LagrangeCoefficient(suiteGroup, 2, indices).IsEqual(LagrangeCoefficientAt(suiteGroup, 0, 2, indices)) {
+		t.Error("the coefficient at 0 differs")
+	}
+}
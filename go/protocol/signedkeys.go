@@ -0,0 +1,158 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// WellKnownKeysPath is the path of the signed key set, at the root of the server
+const WellKnownKeysPath = "/.well-known/oprf-keys"
+
+// The contexts separate the signatures of the delegations from the signatures of the key sets
+const (
+	delegationContext = "ensimag-oprf delegation v1"
+	keySetContext     = "ensimag-oprf key set v1"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid key set signature")
+	ErrExpiredKeySet    = errors.New("expired key set")
+)
+
+// SignedKeySet is the key set served at WellKnownKeysPath. The payload is the JSON KeySetDocument, signed by an
+// online Ed25519 key that the long-term offline root key delegated.
+type SignedKeySet struct {
+	Payload    []byte     `json:"payload"`
+	Signature  []byte     `json:"signature"`
+	Delegation Delegation `json:"delegation"`
+}
+
+// Delegation is the certificate of the online signing key, signed by the root key. The signing key is only trusted
+// from NotBefore until NotAfter.
+type Delegation struct {
+	PublicKey []byte    `json:"public_key"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	Signature []byte    `json:"signature"`
+}
+
+// KeySetDocument lists the published keys of every suite, the clients refuse it after ExpiresAt.
+type KeySetDocument struct {
+	IssuedAt  time.Time   `json:"issued_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	Keys      []KeySetKey `json:"keys"`
+}
+
+// KeySetKey is a published key of the suite
+type KeySetKey struct {
+	Suite string `json:"suite"`
+	PublicKeyInfo
+}
+
+// SignDelegation delegates the signature of the key sets to the public key from notBefore until notAfter.
+func SignDelegation(rootKey ed25519.PrivateKey, publicKey ed25519.PublicKey, notBefore, notAfter time.Time,
+) Delegation {
+	delegation := Delegation{
+		PublicKey: publicKey,
+		NotBefore: notBefore.UTC().Truncate(time.Second),
+		NotAfter:  notAfter.UTC().Truncate(time.Second),
+		Signature: nil,
+	}
+	delegation.Signature = ed25519.Sign(rootKey, delegation.message())
+
+	return delegation
+}
+
+// message is the signed message of the delegation : context | public key (1-byte length) | not before | not after,
+// the times being Unix seconds.
+func (d *Delegation) message() []byte {
+	builder := cryptobyte.NewBuilder([]byte(delegationContext))
+	builder.AddUint8LengthPrefixed(func(builder *cryptobyte.Builder) {
+		builder.AddBytes(d.PublicKey)
+	})
+	builder.AddUint64(uint64(d.NotBefore.Unix()))
+	builder.AddUint64(uint64(d.NotAfter.Unix()))
+
+	return builder.BytesOrPanic()
+}
+
+// Verify checks the signature of the delegation with the root key and that it is valid at the time now.
+func (d *Delegation) Verify(rootKey ed25519.PublicKey, now time.Time) error {
+	if len(rootKey) != ed25519.PublicKeySize || len(d.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w : invalid Ed25519 public key", ErrInvalidSignature)
+	}
+
+	if !ed25519.Verify(rootKey, d.message(), d.Signature) {
+		return fmt.Errorf("%w : the delegation isn't signed by the root key", ErrInvalidSignature)
+	}
+
+	if now.Before(d.NotBefore) || now.After(d.NotAfter) {
+		return fmt.Errorf("%w : the signing key is delegated from %s until %s", ErrExpiredKeySet,
+			d.NotBefore.Format(time.RFC3339), d.NotAfter.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// SignKeySet signs the key set document with the delegated signing key.
+func SignKeySet(signingKey ed25519.PrivateKey, delegation Delegation, document *KeySetDocument,
+) (*SignedKeySet, error) {
+	payload, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't marshal the key set : %w", err)
+	}
+
+	return &SignedKeySet{
+		Payload:    payload,
+		Signature:  ed25519.Sign(signingKey, keySetMessage(payload)),
+		Delegation: delegation,
+	}, nil
+}
+
+// keySetMessage is the signed message of the key set : context | payload.
+func keySetMessage(payload []byte) []byte {
+	return append([]byte(keySetContext), payload...)
+}
+
+// Verify checks the delegation with the root key and the signature of the payload with the delegated key, then
+// returns the key set document if it is valid at the time now.
+func (s *SignedKeySet) Verify(rootKey ed25519.PublicKey, now time.Time) (*KeySetDocument, error) {
+	if err := s.Delegation.Verify(rootKey, now); err != nil {
+		return nil, err
+	}
+
+	if !ed25519.Verify(s.Delegation.PublicKey, keySetMessage(s.Payload), s.Signature) {
+		return nil, fmt.Errorf("%w : the key set isn't signed by the delegated key", ErrInvalidSignature)
+	}
+
+	document := new(KeySetDocument)
+	if err := json.Unmarshal(s.Payload, document); err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidSignature, err.Error())
+	}
+
+	if document.IssuedAt.Before(s.Delegation.NotBefore) || document.IssuedAt.After(s.Delegation.NotAfter) {
+		return nil, fmt.Errorf("%w : the key set was issued outside of the delegation", ErrInvalidSignature)
+	}
+
+	if now.After(document.ExpiresAt) {
+		return nil, fmt.Errorf("%w : the key set expired at %s", ErrExpiredKeySet,
+			document.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return document, nil
+}
+
+// PublicKeys returns the keys of each suite, as published on PublicKeysEndpoint.
+func (d *KeySetDocument) PublicKeys() map[string][]PublicKeyInfo {
+	publicKeys := make(map[string][]PublicKeyInfo)
+	for _, key := range d.Keys {
+		publicKeys[key.Suite] = append(publicKeys[key.Suite], key.PublicKeyInfo)
+	}
+
+	return publicKeys
+}
@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignedKeySetVerify(t *testing.T) {
+	rootPublicKey, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingPublicKey, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	delegation := SignDelegation(rootKey, signingPublicKey, now.Add(-time.Hour), now.AddDate(0, 3, 0))
+
+	document := &KeySetDocument{
+		IssuedAt:  now,
+		ExpiresAt: now.Add(24 * time.Hour),
+		Keys: []KeySetKey{
+			{Suite: "P256-SHA256", PublicKeyInfo: PublicKeyInfo{ID: "2022", State: "active", PublicKey: []byte{0x02}}},
+		},
+	}
+
+	signedKeySet, err := SignKeySet(signingKey, delegation, document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := signedKeySet.Verify(rootPublicKey, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if publicKeys := verified.PublicKeys()["P256-SHA256"]; len(publicKeys) != 1 || publicKeys[0].ID != "2022" {
+		t.Errorf("unexpected keys %v", verified.PublicKeys())
+	}
+
+	otherRootKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := signedKeySet.Verify(otherRootKey, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verified with another root key : %v", err)
+	}
+
+	if _, err := signedKeySet.Verify(rootPublicKey, now.Add(25*time.Hour)); !errors.Is(err, ErrExpiredKeySet) {
+		t.Errorf("verified an expired key set : %v", err)
+	}
+
+	if _, err := signedKeySet.Verify(rootPublicKey, now.AddDate(1, 0, 0)); !errors.Is(err, ErrExpiredKeySet) {
+		t.Errorf("verified with an expired delegation : %v", err)
+	}
+
+	// the key sets signed by the root key itself or with a modified payload are refused
+	forged, err := SignKeySet(rootKey, delegation, document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedKeySet.Payload[len(signedKeySet.Payload)-2] ^= 0x01
+
+	for _, invalid := range []*SignedKeySet{forged, signedKeySet} {
+		if _, err := invalid.Verify(rootPublicKey, now); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("verified an invalid signature : %v", err)
+		}
+	}
+}
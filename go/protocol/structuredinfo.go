@@ -0,0 +1,176 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// StructuredInfoVersion is the first byte of an encoded structured information
+const StructuredInfoVersion = 0x01
+
+// InfoField is the type of a field of a structured public information
+type InfoField uint8
+
+const (
+	InfoFieldApplication InfoField = iota + 1
+	InfoFieldPurpose
+	// InfoFieldEpoch is a big-endian 64-bit integer, for instance a rotation period of the pseudonyms
+	InfoFieldEpoch
+	InfoFieldRecipient
+	// InfoFieldTenant is injected by the server
+	InfoFieldTenant
+)
+
+var ErrInvalidStructuredInfo = errors.New("invalid structured info")
+
+var infoFieldNames = map[InfoField]string{
+	InfoFieldApplication: "application",
+	InfoFieldPurpose:     "purpose",
+	InfoFieldEpoch:       "epoch",
+	InfoFieldRecipient:   "recipient",
+	InfoFieldTenant:      "tenant",
+}
+
+func (f InfoField) String() string {
+	if name, ok := infoFieldNames[f]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("field %d", uint8(f))
+}
+
+// ParseInfoField returns the field of the name.
+func ParseInfoField(name string) (InfoField, error) {
+	for field, fieldName := range infoFieldNames {
+		if fieldName == name {
+			return field, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w : unknown field %q", ErrInvalidStructuredInfo, name)
+}
+
+// StructuredInfo is a public information made of typed fields, as recommended for the domain separation of the
+// partially oblivious mode. Its encoding is prefix-free and canonical :
+// version (1 byte) | count (1 byte) | fields sorted by type : type (1 byte) | value (2-byte length)
+type StructuredInfo map[InfoField][]byte
+
+// EpochValue returns the value of an epoch field.
+func EpochValue(epoch uint64) []byte {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, epoch)
+
+	return value
+}
+
+// Epoch returns the epoch field, if any.
+func (i StructuredInfo) Epoch() (uint64, bool) {
+	value, ok := i[InfoFieldEpoch]
+	if !ok || len(value) != 8 {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint64(value), true
+}
+
+// fields returns the types of the fields in the encoding order.
+func (i StructuredInfo) fields() []InfoField {
+	fields := make([]InfoField, 0, len(i))
+	for field := range i {
+		fields = append(fields, field)
+	}
+
+	sort.Slice(fields, func(a, b int) bool { return fields[a] < fields[b] })
+
+	return fields
+}
+
+// validateField checks the value of a field : the epoch is 8 bytes long, the other fields are non-empty UTF-8 text.
+func validateField(field InfoField, value []byte) error {
+	switch {
+	case infoFieldNames[field] == "":
+		return fmt.Errorf("%w : unknown %s", ErrInvalidStructuredInfo, field)
+	case field == InfoFieldEpoch && len(value) != 8:
+		return fmt.Errorf("%w : the epoch is %d bytes long, expected 8", ErrInvalidStructuredInfo, len(value))
+	case field != InfoFieldEpoch && (len(value) == 0 || !utf8.Valid(value)):
+		return fmt.Errorf("%w : the %s isn't a non-empty UTF-8 text", ErrInvalidStructuredInfo, field)
+	}
+
+	return nil
+}
+
+// MarshalBinary encodes the fields sorted by type.
+func (i StructuredInfo) MarshalBinary() ([]byte, error) {
+	if len(i) == 0 {
+		return nil, fmt.Errorf("%w : no field", ErrInvalidStructuredInfo)
+	}
+
+	builder := cryptobyte.NewBuilder(nil)
+	builder.AddUint8(StructuredInfoVersion)
+	builder.AddUint8(uint8(len(i)))
+
+	for _, field := range i.fields() {
+		if err := validateField(field, i[field]); err != nil {
+			return nil, err
+		}
+
+		builder.AddUint8(uint8(field))
+		addUint16LengthPrefixed(builder, i[field])
+	}
+
+	return builder.Bytes() //nolint:wrapcheck
+}
+
+// ParseStructuredInfo decodes a structured information, the fields must be sorted by type and known.
+func ParseStructuredInfo(data []byte) (StructuredInfo, error) {
+	input := cryptobyte.String(data)
+
+	var version, count uint8
+	if !input.ReadUint8(&version) || !input.ReadUint8(&count) {
+		return nil, fmt.Errorf("%w : truncated info", ErrInvalidStructuredInfo)
+	}
+
+	if version != StructuredInfoVersion {
+		return nil, fmt.Errorf("%w : version %d", ErrInvalidStructuredInfo, version)
+	}
+
+	if count == 0 {
+		return nil, fmt.Errorf("%w : no field", ErrInvalidStructuredInfo)
+	}
+
+	info := make(StructuredInfo, count)
+
+	var previous uint8
+
+	for index := uint8(0); index < count; index++ {
+		var (
+			field uint8
+			value cryptobyte.String
+		)
+
+		if !input.ReadUint8(&field) || !input.ReadUint16LengthPrefixed(&value) {
+			return nil, fmt.Errorf("%w : truncated field %d", ErrInvalidStructuredInfo, index)
+		}
+
+		if field <= previous {
+			return nil, fmt.Errorf("%w : the fields aren't sorted by type", ErrInvalidStructuredInfo)
+		}
+
+		if err := validateField(InfoField(field), value); err != nil {
+			return nil, err
+		}
+
+		info[InfoField(field)], previous = value, field
+	}
+
+	if !input.Empty() {
+		return nil, fmt.Errorf("%w : trailing data", ErrInvalidStructuredInfo)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,66 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStructuredInfo(t *testing.T) {
+	info := StructuredInfo{
+		InfoFieldRecipient:   []byte("lab"),
+		InfoFieldApplication: []byte("survey"),
+		InfoFieldEpoch:       EpochValue(2022),
+	}
+
+	data, err := info.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fields are sorted by type
+	expected := []byte{
+		StructuredInfoVersion, 3,
+		byte(InfoFieldApplication), 0, 6, 's', 'u', 'r', 'v', 'e', 'y',
+		byte(InfoFieldEpoch), 0, 8, 0, 0, 0, 0, 0, 0, 0x07, 0xE6,
+		byte(InfoFieldRecipient), 0, 3, 'l', 'a', 'b',
+	}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("encoded %x, expected %x", data, expected)
+	}
+
+	decodedInfo, err := ParseStructuredInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decodedInfo, info) {
+		t.Errorf("decoded %v, expected %v", decodedInfo, info)
+	}
+
+	if epoch, ok := decodedInfo.Epoch(); !ok || epoch != 2022 {
+		t.Errorf("epoch %d, expected 2022", epoch)
+	}
+
+	for _, invalid := range [][]byte{
+		[]byte("free-form info"),
+		data[:len(data)-1],
+		append(data, 0x00),
+		{StructuredInfoVersion, 0},
+		// unsorted fields
+		{StructuredInfoVersion, 2, byte(InfoFieldPurpose), 0, 1, 'a', byte(InfoFieldApplication), 0, 1, 'a'},
+		// unknown field
+		{StructuredInfoVersion, 1, 0x7F, 0, 1, 'a'},
+		// short epoch
+		{StructuredInfoVersion, 1, byte(InfoFieldEpoch), 0, 1, 0x01},
+	} {
+		if _, err := ParseStructuredInfo(invalid); !errors.Is(err, ErrInvalidStructuredInfo) {
+			t.Errorf("invalid info %x parsed : %v", invalid, err)
+		}
+	}
+
+	if field, err := ParseInfoField("purpose"); err != nil || field != InfoFieldPurpose {
+		t.Errorf("parsed field %v (%v), expected the purpose", field, err)
+	}
+}
@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// Endpoints of the transparency log of the published keys, under the v2 API
+const (
+	TreeHeadEndpoint         = "/transparency/tree_head"
+	InclusionProofEndpoint   = "/transparency/inclusion"
+	ConsistencyProofEndpoint = "/transparency/consistency"
+)
+
+// MaxKeyIDLength is the maximum length of a key ID, it is encoded with a 1-byte length in the log entries
+const MaxKeyIDLength = 255
+
+// treeHeadContext separates the signatures of the tree heads from the signatures of the key sets
+const treeHeadContext = "ensimag-oprf tree head v1"
+
+var (
+	ErrInvalidProof    = errors.New("invalid transparency proof")
+	ErrLogInconsistent = errors.New("the transparency log isn't consistent with the checkpoint")
+	ErrInvalidLogEntry = errors.New("invalid transparency log entry")
+)
+
+// KeyLogEntry is a leaf of the transparency log : the publication of a key or the change of its state
+type KeyLogEntry struct {
+	Suite     string `json:"suite"`
+	KeyID     string `json:"kid"`
+	PublicKey []byte `json:"public_key"`
+	State     string `json:"state"`
+}
+
+// TreeHead is the size and the root hash of the transparency log at a time
+type TreeHead struct {
+	TreeSize  uint64    `json:"tree_size"`
+	RootHash  []byte    `json:"root_hash"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SignedTreeHead is a tree head signed by the key delegated by the root key, as the signed key set
+type SignedTreeHead struct {
+	TreeHead
+	Signature  []byte     `json:"signature"`
+	Delegation Delegation `json:"delegation"`
+}
+
+// InclusionProof is the audit path of a leaf in the tree of TreeSize leaves
+type InclusionProof struct {
+	LeafIndex uint64   `json:"leaf_index"`
+	TreeSize  uint64   `json:"tree_size"`
+	AuditPath [][]byte `json:"audit_path"`
+}
+
+// ConsistencyProof proves that the tree of FirstSize leaves is a prefix of the tree of SecondSize leaves
+type ConsistencyProof struct {
+	FirstSize  uint64   `json:"first_size"`
+	SecondSize uint64   `json:"second_size"`
+	Path       [][]byte `json:"path"`
+}
+
+// LeafData is the encoding of the entry in the log : suite (1-byte length) | kid (1-byte length) |
+// public key (2-byte length) | state (1-byte length). It fails if a field is too long for its length.
+func (e *KeyLogEntry) LeafData() ([]byte, error) {
+	builder := cryptobyte.NewBuilder(nil)
+
+	for _, field := range [][]byte{[]byte(e.Suite), []byte(e.KeyID)} {
+		field := field
+		builder.AddUint8LengthPrefixed(func(builder *cryptobyte.Builder) {
+			builder.AddBytes(field)
+		})
+	}
+
+	builder.AddUint16LengthPrefixed(func(builder *cryptobyte.Builder) {
+		builder.AddBytes(e.PublicKey)
+	})
+	builder.AddUint8LengthPrefixed(func(builder *cryptobyte.Builder) {
+		builder.AddBytes([]byte(e.State))
+	})
+
+	leafData, err := builder.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidLogEntry, err.Error())
+	}
+
+	return leafData, nil
+}
+
+// message is the signed message of the tree head : context | tree size | timestamp (Unix milliseconds) |
+// root hash (1-byte length). It fails if the root hash is too long.
+func (h *TreeHead) message() ([]byte, error) {
+	builder := cryptobyte.NewBuilder([]byte(treeHeadContext))
+	builder.AddUint64(h.TreeSize)
+	builder.AddUint64(uint64(h.Timestamp.UnixMilli()))
+	builder.AddUint8LengthPrefixed(func(builder *cryptobyte.Builder) {
+		builder.AddBytes(h.RootHash)
+	})
+
+	return builder.Bytes() //nolint:wrapcheck
+}
+
+// SignTreeHead signs the tree head with the delegated signing key.
+func SignTreeHead(signingKey ed25519.PrivateKey, delegation Delegation, treeHead TreeHead,
+) (*SignedTreeHead, error) {
+	treeHead.Timestamp = treeHead.Timestamp.UTC().Truncate(time.Millisecond)
+
+	message, err := treeHead.message()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't encode the tree head : %w", err)
+	}
+
+	return &SignedTreeHead{
+		TreeHead:   treeHead,
+		Signature:  ed25519.Sign(signingKey, message),
+		Delegation: delegation,
+	}, nil
+}
+
+// Verify checks the delegation with the root key and the signature of the tree head with the delegated key.
+func (h *SignedTreeHead) Verify(rootKey ed25519.PublicKey, now time.Time) error {
+	if err := h.Delegation.Verify(rootKey, now); err != nil {
+		return err
+	}
+
+	message, err := h.TreeHead.message()
+	if err != nil {
+		return fmt.Errorf("%w : %s", ErrInvalidSignature, err.Error())
+	}
+
+	if !ed25519.Verify(h.Delegation.PublicKey, message, h.Signature) {
+		return fmt.Errorf("%w : the tree head isn't signed by the delegated key", ErrInvalidSignature)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedTreeHeadVerify(t *testing.T) {
+	rootPublicKey, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingPublicKey, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	delegation := SignDelegation(rootKey, signingPublicKey, now.Add(-time.Hour), now.Add(time.Hour))
+
+	signedTreeHead, err := SignTreeHead(signingKey, delegation, TreeHead{TreeSize: 1, RootHash: LeafHash(nil),
+		Timestamp: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signedTreeHead.Verify(rootPublicKey, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// a tree head of another size isn't signed
+	signedTreeHead.TreeSize++
+	if err := signedTreeHead.Verify(rootPublicKey, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verified a modified tree head : %v", err)
+	}
+
+	// a root hash too long for its encoding is refused without panic
+	signedTreeHead.RootHash = make([]byte, 300)
+	if err := signedTreeHead.Verify(rootPublicKey, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verified a tree head with a 300-byte root hash : %v", err)
+	}
+}
+
+func TestKeyLogEntryLeafData(t *testing.T) {
+	entry := KeyLogEntry{Suite: "P256-SHA256", KeyID: strings.Repeat("k", MaxKeyIDLength), PublicKey: []byte{0x02},
+		State: "active"}
+	if _, err := entry.LeafData(); err != nil {
+		t.Fatal(err)
+	}
+
+	entry.KeyID += "k"
+	if _, err := entry.LeafData(); !errors.Is(err, ErrInvalidLogEntry) {
+		t.Errorf("encoded a key ID of %d bytes : %v", len(entry.KeyID), err)
+	}
+}
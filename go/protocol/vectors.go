@@ -0,0 +1,11 @@
+package protocol
+
+import (
+	_ "embed" // the RFC 9497 test vectors
+)
+
+// RFC9497TestVectors are the test vectors of the appendix A of RFC 9497, in the format of the CFRG reference
+// implementation. They are shared by the known-answer tests of the server and by the conformance tests.
+//
+//go:embed rfc9497.json
+var RFC9497TestVectors []byte
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
@@ -9,14 +10,16 @@ import (
 )
 
 func Handler(w http.ResponseWriter, r *http.Request) {
-	serializedBase64KeyMap := controllers.LoadPrivateKeysFromEnv()
-
-	router, err := routers.NewRouter(serializedBase64KeyMap)
+	// the keys are rotated when they are loaded, the usage counters of this controller don't outlast the request
+	oprfServerController, err := controllers.NewOPRFServerControllerFromEnv(true)
 	if err != nil {
 		log.Println(err)
 
-		return
+		// the health endpoint reports a failed self-test, the other endpoints are refused
+		if !errors.Is(err, controllers.ErrSelfTest) {
+			return
+		}
 	}
 
-	router.ServeHTTP(w, r)
+	routers.NewRouter(oprfServerController).ServeHTTP(w, r)
 }
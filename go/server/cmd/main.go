@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/ensimag-oprf/go/server/controllers"
@@ -15,25 +17,58 @@ import (
 const (
 	HOST = "localhost"
 	PORT = "1323"
+	// EnvPort overrides the default port, e.g. to launch several threshold evaluators
+	EnvPort = "PORT"
 )
 
 func main() {
-	serializedBase64KeyMap := controllers.LoadPrivateKeysFromEnv()
-
-	router, err := routers.NewRouter(serializedBase64KeyMap)
+	rotationInterval, err := controllers.LoadDurationFromEnv(controllers.EnvRotationInterval,
+		controllers.DefaultRotationInterval)
 	if err != nil {
 		log.Println(err)
 
 		return
 	}
 
+	oprfServerController, err := controllers.NewOPRFServerControllerFromEnv(false)
+	if err != nil {
+		log.Println(err)
+
+		// the health endpoint reports a failed self-test, the other endpoints are refused
+		if !errors.Is(err, controllers.ErrSelfTest) {
+			return
+		}
+	}
+
+	// Promote the pending keys on schedule
+	go oprfServerController.RotateEvery(rotationInterval)
+
+	router := routers.NewRouter(oprfServerController)
+
+	port, ok := os.LookupEnv(EnvPort)
+	if !ok {
+		port = PORT
+	}
+
 	// Start the server
 	go func() {
-		if err := router.Start(HOST + ":" + PORT); err != nil && err != http.ErrServerClosed {
+		if err := router.Start(HOST + ":" + port); err != nil && err != http.ErrServerClosed {
 			router.Logger.Fatal("shutting down the server")
 		}
 	}()
 
+	// Reload the keys on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	go func() {
+		for range reload {
+			if _, err := oprfServerController.Reload(); err != nil {
+				log.Println("Reload error :", err)
+			}
+		}
+	}()
+
 	// Wait for interrupt signal to gracefully shut down the server with a timeout of 10 seconds.
 	// Use a buffered channel to avoid missing signals as recommended for signal.Notify
 	quit := make(chan os.Signal, 1)
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+// Encodings are the encodings of the evaluations, the most compact first
+func Encodings() []string {
+	return []string{protocol.ContentTypeBinary, protocol.ContentTypeCBOR, protocol.ContentTypeJSON}
+}
+
+// Capabilities returns the suites of the key sets with their modes and their published key IDs, the limits of the
+// evaluation requests, the encodings and the versions of the API. A mode is offered if a key used for evaluations
+// (active or verify-only) has a server in this mode.
+func (s *OPRFServerController) Capabilities() *protocol.Capabilities {
+	capabilities := &protocol.Capabilities{
+		APIVersions: []string{protocol.APIVersion1, protocol.APIVersion2},
+		Suites:      nil,
+		Encodings:   Encodings(),
+		Limits: protocol.LimitsOffer{
+			MaxBodySize:   s.limits.MaxBodySize,
+			MaxBatchSize:  s.limits.MaxBatchSize,
+			MaxInfoLength: s.limits.MaxInfoLength,
+		},
+	}
+
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+
+	s.serversMu.RLock()
+	defer s.serversMu.RUnlock()
+
+	for _, suite := range SupportedSuites() {
+		keySet := s.keys[suite.Identifier()]
+		if keySet == nil {
+			continue
+		}
+
+		suiteCapabilities := protocol.SuiteCapabilities{
+			Suite:       suite.Identifier(),
+			Modes:       nil,
+			KeyIDs:      nil,
+			ActiveKeyID: "",
+		}
+
+		if activeKey := keySet.Active(); activeKey != nil {
+			suiteCapabilities.ActiveKeyID = activeKey.ID
+		}
+
+		for _, key := range keySet.Published() {
+			suiteCapabilities.KeyIDs = append(suiteCapabilities.KeyIDs, key.ID)
+		}
+
+		for _, mode := range []oprf.Mode{oprf.BaseMode, oprf.VerifiableMode, oprf.PartialObliviousMode} {
+			for _, key := range keySet.Published() {
+				if _, ok := s.servers[mode][suite.Identifier()][key.ID]; ok && key.State != KeyStatePending {
+					suiteCapabilities.Modes = append(suiteCapabilities.Modes, protocol.NewMode(mode))
+
+					break
+				}
+			}
+		}
+
+		capabilities.Suites = append(capabilities.Suites, suiteCapabilities)
+	}
+
+	return capabilities
+}
+
+// CapabilitiesHandler is an endpoint describing the capabilities of the server, for the negotiation of the clients.
+func (s *OPRFServerController) CapabilitiesHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.Capabilities()) //nolint:wrapcheck
+}
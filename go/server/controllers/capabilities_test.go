@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+func TestCapabilitiesHandler(t *testing.T) {
+	keySet, err := GenerateKeySet(oprf.SuiteP384)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key shares have no partially oblivious server
+	shares, err := SplitKey(oprf.SuiteP384, keySet.Active(), 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shareSet := NewKeySet(oprf.SuiteP384)
+	if err := shareSet.Add(shares[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider(shareSet)); err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/api/capabilities", nil)
+	recorder := httptest.NewRecorder()
+
+	if err := controller.CapabilitiesHandler(echo.New().NewContext(request, recorder)); err != nil {
+		t.Fatal(err)
+	}
+
+	capabilities := new(protocol.Capabilities)
+	if err := json.Unmarshal(recorder.Body.Bytes(), capabilities); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(capabilities.Suites) != len(SupportedSuites()) {
+		t.Fatalf("%d suites, expected %d", len(capabilities.Suites), len(SupportedSuites()))
+	}
+
+	for index, suite := range SupportedSuites() {
+		suiteCapabilities := capabilities.Suites[index]
+		if suiteCapabilities.Suite != suite.Identifier() {
+			t.Errorf("suite %d : %s, expected %s", index, suiteCapabilities.Suite, suite.Identifier())
+		}
+
+		modes := []protocol.Mode{protocol.ModeBase, protocol.ModeVerifiable, protocol.ModePartialOblivious}
+		if suite == oprf.SuiteP384 {
+			modes = modes[:2]
+		}
+
+		if !reflect.DeepEqual(suiteCapabilities.Modes, modes) {
+			t.Errorf("%s : modes %v, expected %v", suite.Identifier(), suiteCapabilities.Modes, modes)
+		}
+
+		if len(suiteCapabilities.KeyIDs) != 1 || suiteCapabilities.KeyIDs[0] != suiteCapabilities.ActiveKeyID {
+			t.Errorf("%s : key IDs %v, active %q", suite.Identifier(), suiteCapabilities.KeyIDs,
+				suiteCapabilities.ActiveKeyID)
+		}
+	}
+
+	if !reflect.DeepEqual(capabilities.Encodings, Encodings()) {
+		t.Errorf("encodings %v", capabilities.Encodings)
+	}
+
+	if capabilities.Limits.MaxBatchSize != DefaultLimits().MaxBatchSize {
+		t.Errorf("max batch size %d", capabilities.Limits.MaxBatchSize)
+	}
+
+	if err := capabilities.CheckSuite(oprf.SuiteP384.Identifier(), protocol.ModePartialOblivious); err == nil {
+		t.Error("the partially oblivious mode is offered with a key share")
+	}
+}
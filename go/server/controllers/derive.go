@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+const (
+	// DerivationMode is the mode of the RFC 9497 DeriveKeyPair context string. The derived key is used in every mode.
+	DerivationMode = oprf.BaseMode
+	// DerivationInfoPrefix is the prefix of the per-suite/per-kid info string of the key derivation
+	DerivationInfoPrefix = "ensimag-oprf"
+	// MinMasterSeedLength is the minimum length of the master seed, i.e. Nseed in RFC 9497
+	MinMasterSeedLength = 32
+	// DefaultDerivedKeyID is the key ID of the derived key if none is configured
+	DefaultDerivedKeyID = "v1"
+)
+
+var (
+	ErrMasterSeedTooShort = errors.New("the master seed is too short")
+	ErrNoDerivedKeyID     = errors.New("no key ID to derive")
+)
+
+// DerivationInfo returns the info string of the derivation of the key kid of the suite.
+// The suite identifiers don't contain a colon so the encoding is unambiguous.
+func DerivationInfo(suite oprf.Suite, kid string) []byte {
+	return []byte(DerivationInfoPrefix + ":" + suite.Identifier() + ":" + kid)
+}
+
+// DeriveKey derives the private key kid of the suite from the master seed with oprf.DeriveKey.
+func DeriveKey(suite oprf.Suite, seed []byte, kid string) (*oprf.PrivateKey, error) {
+	if len(seed) < MinMasterSeedLength {
+		return nil, fmt.Errorf("%w : %d bytes, expected at least %d", ErrMasterSeedTooShort,
+			len(seed), MinMasterSeedLength)
+	}
+
+	privateKey, err := oprf.DeriveKey(suite, DerivationMode, seed, DerivationInfo(suite, kid))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't derive the key %s of the suite %s : %w", kid, suite.Identifier(), err)
+	}
+
+	return privateKey, nil
+}
+
+// DeriveKeySet derives the keys of the suite from the master seed. The last key ID is the active key,
+// the previous ones are verify-only.
+func DeriveKeySet(suite oprf.Suite, seed []byte, kids []string) (*KeySet, error) {
+	if len(kids) == 0 {
+		return nil, ErrNoDerivedKeyID
+	}
+
+	keySet := NewKeySet(suite)
+
+	for index, kid := range kids {
+		privateKey, err := DeriveKey(suite, seed, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		state := KeyStateVerifyOnly
+		if index == len(kids)-1 {
+			state = KeyStateActive
+		}
+
+		if err := keySet.Add(&Key{ID: kid, State: state, PrivateKey: privateKey}); err != nil { //nolint:exhaustivestruct
+			return nil, err
+		}
+	}
+
+	return keySet, nil
+}
+
+// DerivedKeyProvider derives the key sets of every suite from a master seed, so the keys are
+// reproducible across the serverless instances.
+type DerivedKeyProvider struct {
+	Seed   []byte
+	KeyIDs []string
+}
+
+func (p DerivedKeyProvider) LoadKeySets(suites []oprf.Suite) (KeySetMap, error) {
+	keySets := make(KeySetMap)
+
+	for _, suite := range suites {
+		keySet, err := DeriveKeySet(suite, p.Seed, p.KeyIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		keySets[suite.Identifier()] = keySet
+	}
+
+	return keySets, nil
+}
+
+// ParseDerivedKeyIDs parses a comma-separated list of derived key IDs, the spaces and the empty IDs are ignored.
+func ParseDerivedKeyIDs(value string) []string {
+	var kids []string
+
+	for _, kid := range strings.Split(value, ",") {
+		if kid = strings.TrimSpace(kid); kid != "" {
+			kids = append(kids, kid)
+		}
+	}
+
+	return kids
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+func TestDerivedKeyProvider(t *testing.T) {
+	seed := bytes.Repeat([]byte{0xA3}, MinMasterSeedLength)
+	keyProvider := DerivedKeyProvider{Seed: seed, KeyIDs: []string{"v1", "v2"}}
+
+	keySets, err := keyProvider.LoadKeySets(SupportedSuites())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another serverless instance derives the same keys
+	otherKeySets, err := keyProvider.LoadKeySets(SupportedSuites())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, suite := range SupportedSuites() {
+		keySet := keySets[suite.Identifier()]
+		if keySet.Active().ID != "v2" {
+			t.Errorf("the last key ID should be active")
+		}
+
+		previous, _ := keySet.Get("v1")
+		if bytes.Equal(SerializePublicKey(previous.PrivateKey), SerializePublicKey(keySet.Active().PrivateKey)) {
+			t.Errorf("the key IDs should derive different keys")
+		}
+
+		otherKey := otherKeySets[suite.Identifier()].Active()
+		if !bytes.Equal(SerializePublicKey(otherKey.PrivateKey), SerializePublicKey(keySet.Active().PrivateKey)) {
+			t.Errorf("the derivation of the suite %s is not reproducible", suite)
+		}
+
+		// a derived key has no creation date to publish
+		publicKeyInfo, err := json.Marshal(NewPublicKeyInfo(suite, keySet.Active()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Contains(publicKeyInfo, []byte("created_at")) {
+			t.Errorf("the derived key is published with a creation date : %s", publicKeyInfo)
+		}
+	}
+
+	if _, err := DeriveKey(oprf.SuiteP256, seed[:16], "v1"); !errors.Is(err, ErrMasterSeedTooShort) {
+		t.Errorf("a short master seed should be rejected : %v", err)
+	}
+}
+
+func TestParseDerivedKeyIDs(t *testing.T) {
+	// key-gen derive and the server derive the same keys for the same list
+	if kids := ParseDerivedKeyIDs(" v1, ,v2 ,"); strings.Join(kids, ",") != "v1,v2" {
+		t.Errorf("unexpected key IDs %q", kids)
+	}
+}
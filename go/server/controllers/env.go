@@ -1,22 +1,109 @@
 package controllers
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
 )
 
 const (
-	EnvP256PrivateKey = "P256_PRIVATE_KEY"
-	EnvP384PrivateKey = "P384_PRIVATE_KEY"
-	EnvP521PrivateKey = "P521_PRIVATE_KEY"
+	EnvP256PrivateKey         = "P256_PRIVATE_KEY"
+	EnvP384PrivateKey         = "P384_PRIVATE_KEY"
+	EnvP521PrivateKey         = "P521_PRIVATE_KEY"
+	EnvRistretto255PrivateKey = "RISTRETTO255_PRIVATE_KEY"
+
+	EnvP256KeySet         = "P256_KEY_SET"
+	EnvP384KeySet         = "P384_KEY_SET"
+	EnvP521KeySet         = "P521_KEY_SET"
+	EnvRistretto255KeySet = "RISTRETTO255_KEY_SET"
+
+	// EnvKeyDir is the directory holding the key files, for instance /run/secrets
+	EnvKeyDir = "OPRF_KEY_DIR"
+	// EnvStrictKeys refuses to start the server if a key is missing instead of generating it
+	EnvStrictKeys = "OPRF_STRICT_KEYS"
+
+	// EnvKeystore is the path of the passphrase-encrypted keystore
+	EnvKeystore = "OPRF_KEYSTORE"
+	// EnvKeystorePassphrase is the passphrase of the keystore
+	EnvKeystorePassphrase = "OPRF_KEYSTORE_PASSPHRASE"
+	// EnvKeystorePassphraseFile is the path of a file holding the passphrase of the keystore
+	EnvKeystorePassphraseFile = "OPRF_KEYSTORE_PASSPHRASE_FILE"
+
+	// EnvMasterSeed is the base64 encoded master seed of the derived keys
+	EnvMasterSeed = "OPRF_MASTER_SEED"
+	// EnvDerivedKeyIDs is the comma-separated list of the derived key IDs, the last one is active
+	EnvDerivedKeyIDs = "OPRF_DERIVED_KEY_IDS"
+
+	// EnvKeyGracePeriod is the time a replaced key stays verify-only before being retired, e.g. 720h
+	EnvKeyGracePeriod = "OPRF_KEY_GRACE_PERIOD"
+	// EnvServeExpiredKeys keeps evaluating with an expired active key without successor instead of refusing
+	EnvServeExpiredKeys = "OPRF_SERVE_EXPIRED_KEYS"
+	// EnvRotationInterval is the interval between the rotation checks of the keys, 1 minute by default
+	EnvRotationInterval = "OPRF_ROTATION_INTERVAL"
+
+	// EnvKeySigner is the JSON key signer of the well-known key set, generated by key-gen delegate
+	EnvKeySigner = "OPRF_KEY_SIGNER"
+	// EnvTransparencyLog is the path of the append-only log of the published keys on a durable storage, it is kept
+	// in memory and not served if not set
+	EnvTransparencyLog = "OPRF_TRANSPARENCY_LOG"
+
+	// EnvAdminToken is the bearer token of the admin endpoints, they are disabled if it is not set
+	EnvAdminToken = "OPRF_ADMIN_TOKEN"
+	// EnvTrustedCallerToken is the bearer token of the full evaluation endpoint, it is disabled if it is not set
+	EnvTrustedCallerToken = "OPRF_TRUSTED_CALLER_TOKEN"
+	// EnvVerifierToken is the bearer token of the verification endpoint, it is disabled if it is not set
+	EnvVerifierToken = "OPRF_VERIFIER_TOKEN"
+
+	// EnvTrustedProxies is the comma-separated list of the CIDR ranges of the reverse proxies whose X-Forwarded-For
+	// header gives the client address of the audit logs, the address of the connection is used if it is not set
+	EnvTrustedProxies = "OPRF_TRUSTED_PROXIES"
+
+	// EnvMaxBodySize is the maximum size of an evaluation request body in bytes
+	EnvMaxBodySize = "OPRF_MAX_BODY_SIZE"
+	// EnvMaxBatchSize is the maximum number of blinded elements of an evaluation request
+	EnvMaxBatchSize = "OPRF_MAX_BATCH_SIZE"
+	// EnvMaxInfoLength is the maximum length of the public information in bytes
+	EnvMaxInfoLength = "OPRF_MAX_INFO_LENGTH"
+
+	// EnvInfoPolicy is the policy of the public information : free (default) or structured
+	EnvInfoPolicy = "OPRF_INFO_POLICY"
+	// EnvInfoRequired is the comma-separated list of the fields the client must send, e.g. application,purpose
+	EnvInfoRequired = "OPRF_INFO_REQUIRED"
+	// EnvInfoAllowlist is the JSON map of the fields to their allowed values
+	EnvInfoAllowlist = "OPRF_INFO_ALLOWLIST"
+	// EnvTenantID is the tenant ID injected in the structured public information
+	EnvTenantID = "OPRF_TENANT_ID"
+)
+
+// Info policies of EnvInfoPolicy
+const (
+	InfoPolicyFree       = "free"
+	InfoPolicyStructured = "structured"
 )
 
 func GetEnvPrivateKeySuiteMap() map[string]string {
 	return map[string]string{
-		EnvP256PrivateKey: oprf.SuiteP256.Identifier(),
-		EnvP384PrivateKey: oprf.SuiteP384.Identifier(),
-		EnvP521PrivateKey: oprf.SuiteP521.Identifier(),
+		EnvP256PrivateKey:         oprf.SuiteP256.Identifier(),
+		EnvP384PrivateKey:         oprf.SuiteP384.Identifier(),
+		EnvP521PrivateKey:         oprf.SuiteP521.Identifier(),
+		EnvRistretto255PrivateKey: oprf.SuiteRistretto255.Identifier(),
+	}
+}
+
+func GetEnvKeySetSuiteMap() map[string]string {
+	return map[string]string{
+		EnvP256KeySet:         oprf.SuiteP256.Identifier(),
+		EnvP384KeySet:         oprf.SuiteP384.Identifier(),
+		EnvP521KeySet:         oprf.SuiteP521.Identifier(),
+		EnvRistretto255KeySet: oprf.SuiteRistretto255.Identifier(),
 	}
 }
 
@@ -34,3 +121,325 @@ func LoadPrivateKeysFromEnv() SerializedBase64KeyMap {
 
 	return serializedBase64KeyMap
 }
+
+// LoadKeySetsFromEnv load the JSON serialized key sets from the environment variables.
+// A suite without key set falls back on its single private key environment variable, loaded as the active key.
+// For instance :
+// P256_KEY_SET='[{"kid": "2022-01", "state": "verify-only", "created_at": "2022-01-01T00:00:00Z",
+// "private_key": "AtzyGS8NoBjEjqbhwdGY/zWyqdFkJghyTttoIGq4UoM="}, {"kid": "2022-06", "state": "active", ...}]'
+func LoadKeySetsFromEnv() (SerializedKeySetMap, error) {
+	serializedKeySetMap := make(SerializedKeySetMap)
+
+	for suiteID, serializedBase64PrivateKey := range LoadPrivateKeysFromEnv() {
+		serializedKeySetMap[suiteID] = []SerializedKey{{PrivateKey: serializedBase64PrivateKey}}
+	}
+
+	for envKeySet, suiteID := range GetEnvKeySetSuiteMap() {
+		serializedKeySet, ok := os.LookupEnv(envKeySet)
+		if !ok {
+			continue
+		}
+
+		var serializedKeys []SerializedKey
+		if err := json.Unmarshal([]byte(serializedKeySet), &serializedKeys); err != nil {
+			return nil, fmt.Errorf("couldn't parse %s : %w", envKeySet, err)
+		}
+
+		serializedKeySetMap[suiteID] = serializedKeys
+	}
+
+	return serializedKeySetMap, nil
+}
+
+// PassphraseSourceFromEnv returns the keystore passphrase source configured by the environment variables :
+// the passphrase file, the passphrase variable or, by default, a prompt. The prompted passphrase is only read once,
+// at startup, and reused by the reloads.
+func PassphraseSourceFromEnv() PassphraseSource {
+	if path, ok := os.LookupEnv(EnvKeystorePassphraseFile); ok {
+		return PassphraseFromFile(path)
+	}
+
+	if _, ok := os.LookupEnv(EnvKeystorePassphrase); ok {
+		return PassphraseFromEnv(EnvKeystorePassphrase)
+	}
+
+	return CachedPassphrase(PassphraseFromPrompt("Keystore passphrase : "))
+}
+
+// LoadMasterSeedFromEnv decodes the master seed from its environment variable, or from its key file.
+func LoadMasterSeedFromEnv() ([]byte, bool, error) {
+	serializedBase64Seed, ok := os.LookupEnv(EnvMasterSeed)
+	if !ok {
+		var err error
+
+		serializedBase64Seed, ok, err = FileKeyProvider{Dir: os.Getenv(EnvKeyDir)}.readFile(EnvMasterSeed)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(serializedBase64Seed))
+	if err != nil {
+		return nil, false, fmt.Errorf("couldn't decode %s : %w", EnvMasterSeed, err)
+	}
+
+	return seed, true, nil
+}
+
+// LoadDerivedKeyIDsFromEnv returns the derived key IDs, DefaultDerivedKeyID by default.
+func LoadDerivedKeyIDsFromEnv() []string {
+	value, ok := os.LookupEnv(EnvDerivedKeyIDs)
+	if !ok {
+		return []string{DefaultDerivedKeyID}
+	}
+
+	return ParseDerivedKeyIDs(value)
+}
+
+// NewKeyProviderFromEnv returns the key provider configured by the environment variables.
+// The keys are loaded from the keystore if any, the master seed if any, then from the environment
+// variables and the key files.
+func NewKeyProviderFromEnv() (KeyProvider, error) {
+	var keyProviders ChainKeyProvider
+
+	if path, ok := os.LookupEnv(EnvKeystore); ok {
+		keyProviders = append(keyProviders, KeystoreKeyProvider{
+			Path:       path,
+			Passphrase: PassphraseSourceFromEnv(),
+		})
+	}
+
+	seed, ok, err := LoadMasterSeedFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		keyProviders = append(keyProviders, DerivedKeyProvider{
+			Seed:   seed,
+			KeyIDs: LoadDerivedKeyIDsFromEnv(),
+		})
+	}
+
+	var keyProvider KeyProvider = append(keyProviders,
+		EnvKeyProvider{},
+		FileKeyProvider{Dir: os.Getenv(EnvKeyDir)},
+	)
+
+	if value, ok := os.LookupEnv(EnvStrictKeys); ok {
+		strict, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %s : %w", EnvStrictKeys, err)
+		}
+
+		if strict {
+			keyProvider = StrictKeyProvider{keyProvider}
+		}
+	}
+
+	return keyProvider, nil
+}
+
+// LoadDurationFromEnv parses the duration of the environment variable, defaultDuration is returned if it is not set.
+func LoadDurationFromEnv(name string, defaultDuration time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return defaultDuration, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse %s : %w", name, err)
+	}
+
+	return duration, nil
+}
+
+// LoadBoolFromEnv parses the boolean of the environment variable, defaultValue is returned if it is not set.
+func LoadBoolFromEnv(name string, defaultValue bool) (bool, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("couldn't parse %s : %w", name, err)
+	}
+
+	return parsed, nil
+}
+
+// LoadTrustedProxiesFromEnv returns the CIDR ranges of the trusted reverse proxies, none by default.
+func LoadTrustedProxiesFromEnv() ([]*net.IPNet, error) {
+	var trustedProxies []*net.IPNet
+
+	for _, cidr := range strings.Split(os.Getenv(EnvTrustedProxies), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+
+		_, ipRange, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %s : %w", EnvTrustedProxies, err)
+		}
+
+		trustedProxies = append(trustedProxies, ipRange)
+	}
+
+	return trustedProxies, nil
+}
+
+// LoadLimitsFromEnv returns the limits of the evaluation requests, the default limits are used for the variables
+// that are not set.
+func LoadLimitsFromEnv() (Limits, error) {
+	limits := DefaultLimits()
+
+	maxBodySize, err := loadPositiveIntFromEnv(EnvMaxBodySize, int(limits.MaxBodySize))
+	if err != nil {
+		return limits, err
+	}
+
+	limits.MaxBodySize = int64(maxBodySize)
+
+	if limits.MaxBatchSize, err = loadPositiveIntFromEnv(EnvMaxBatchSize, limits.MaxBatchSize); err != nil {
+		return limits, err
+	}
+
+	if limits.MaxInfoLength, err = loadPositiveIntFromEnv(EnvMaxInfoLength, limits.MaxInfoLength); err != nil {
+		return limits, err
+	}
+
+	return limits, nil
+}
+
+// loadPositiveIntFromEnv parses the positive integer of the environment variable, defaultValue is returned if it is
+// not set.
+func loadPositiveIntFromEnv(name string, defaultValue int) (int, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+
+	parsedValue, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse %s : %w", name, err)
+	}
+
+	if parsedValue <= 0 {
+		return 0, fmt.Errorf("couldn't parse %s : %d is not positive", name, parsedValue)
+	}
+
+	return parsedValue, nil
+}
+
+// LoadInfoPolicyFromEnv returns the policy of the public information. The schema variables require the structured
+// policy. For instance :
+// OPRF_INFO_POLICY=structured OPRF_INFO_REQUIRED=application,purpose OPRF_TENANT_ID=ensimag
+// OPRF_INFO_ALLOWLIST='{"application": ["survey"], "purpose": ["statistics", "audit"]}'
+func LoadInfoPolicyFromEnv() (InfoPolicy, error) {
+	var infoPolicy InfoPolicy
+
+	switch value := os.Getenv(EnvInfoPolicy); value {
+	case "", InfoPolicyFree:
+	case InfoPolicyStructured:
+		infoPolicy.Structured = true
+	default:
+		return infoPolicy, fmt.Errorf("couldn't parse %s : unknown policy %q", EnvInfoPolicy, value)
+	}
+
+	for _, name := range strings.Split(os.Getenv(EnvInfoRequired), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+
+		field, err := protocol.ParseInfoField(name)
+		if err != nil {
+			return infoPolicy, fmt.Errorf("couldn't parse %s : %w", EnvInfoRequired, err)
+		}
+
+		infoPolicy.Required = append(infoPolicy.Required, field)
+	}
+
+	if value, ok := os.LookupEnv(EnvInfoAllowlist); ok && value != "" {
+		var allowlist map[string][]string
+		if err := json.Unmarshal([]byte(value), &allowlist); err != nil {
+			return infoPolicy, fmt.Errorf("couldn't parse %s : %w", EnvInfoAllowlist, err)
+		}
+
+		infoPolicy.Allowed = make(map[protocol.InfoField][]string, len(allowlist))
+
+		for name, values := range allowlist {
+			field, err := protocol.ParseInfoField(name)
+			if err != nil {
+				return infoPolicy, fmt.Errorf("couldn't parse %s : %w", EnvInfoAllowlist, err)
+			}
+
+			infoPolicy.Allowed[field] = values
+		}
+	}
+
+	if tenantID := os.Getenv(EnvTenantID); tenantID != "" {
+		infoPolicy.Injected = map[protocol.InfoField]string{protocol.InfoFieldTenant: tenantID}
+	}
+
+	return infoPolicy, infoPolicy.Validate()
+}
+
+// NewOPRFServerControllerFromEnv returns the controller configured by the environment variables, initialized with the
+// keys of the key provider. perRequest marks a controller created for a single request, as the serverless function.
+// The controller is also returned with an error wrapping ErrSelfTest : its health endpoint reports the failed
+// self-test.
+func NewOPRFServerControllerFromEnv(perRequest bool) (*OPRFServerController, error) {
+	keyProvider, err := NewKeyProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	gracePeriod, err := LoadDurationFromEnv(EnvKeyGracePeriod, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := LoadLimitsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	infoPolicy, err := LoadInfoPolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	serveExpiredKeys, err := LoadBoolFromEnv(EnvServeExpiredKeys, false)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies, err := LoadTrustedProxiesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	keySigner, err := LoadKeySignerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	transparencyLog, err := LoadTransparencyLogFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	controller := NewOPRFServerController()
+	controller.SetGracePeriod(gracePeriod)
+	controller.SetPerRequest(perRequest)
+	controller.SetServeExpiredKeys(serveExpiredKeys)
+	controller.SetLimits(limits)
+	controller.SetInfoPolicy(infoPolicy)
+	controller.SetKeySigner(keySigner)
+	controller.SetTrustedProxies(trustedProxies)
+	controller.SetTransparencyLog(transparencyLog)
+
+	return controller, controller.Initialize(keyProvider)
+}
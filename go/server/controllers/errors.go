@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+// APIError returns the typed API error of an error. The errors of the key sets are key_unavailable and the unknown
+// errors are internal errors, their message isn't sent to the client.
+func APIError(err error) *protocol.Error {
+	var apiError *protocol.Error
+
+	var httpError *echo.HTTPError
+
+	switch {
+	case errors.As(err, &apiError):
+		return apiError
+	case errors.Is(err, ErrUnknownKeyID), errors.Is(err, ErrRetiredKey), errors.Is(err, ErrPendingKey),
+		errors.Is(err, ErrNoActiveKey), errors.Is(err, ErrExpiredKey):
+		return protocol.NewError(protocol.CodeKeyUnavailable, err.Error())
+	case errors.Is(err, protocol.ErrUnknownMode):
+		return protocol.NewError(protocol.CodeUnsupportedMode, err.Error())
+	case errors.Is(err, protocol.ErrInvalidEncoding):
+		return protocol.NewError(protocol.CodeInvalidRequest, err.Error())
+	case errors.As(err, &httpError):
+		// the errors of echo and of its middlewares, such as the authentication of the admin endpoints
+		message, ok := httpError.Message.(string)
+		if !ok {
+			message = http.StatusText(httpError.Code)
+		}
+
+		return &protocol.Error{Code: protocol.StatusCode(httpError.Code), Message: message, Status: httpError.Code}
+	default:
+		log.Println("Internal error :", err)
+
+		return protocol.NewError(protocol.CodeInternal, "internal error")
+	}
+}
+
+// HTTPErrorHandler sends the errors of the handlers as typed JSON error bodies.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	apiError := APIError(err)
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(apiError.HTTPStatus())
+	} else {
+		err = c.JSON(apiError.HTTPStatus(), apiError)
+	}
+
+	if err != nil {
+		log.Println("Error response error :", err)
+	}
+}
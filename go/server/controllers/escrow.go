@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+const (
+	// EscrowSharePrefix identifies the version of the printable escrow share format
+	EscrowSharePrefix = "oprf-escrow1"
+	// escrowChecksumLength is the number of bytes of the SHA-256 checksum of a printable share
+	escrowChecksumLength = 4
+	// escrowFingerprintLength is the number of hex characters of the key fingerprint in a printable share
+	escrowFingerprintLength = 16
+	escrowShareFields       = 8
+)
+
+var (
+	ErrInvalidEscrowShare = errors.New("invalid escrow share")
+	ErrEscrowChecksum     = errors.New("escrow share checksum mismatch")
+	ErrFingerprintMatch   = errors.New("the rebuilt key does not match the fingerprint")
+)
+
+// EscrowShare is a Shamir share of a key given to a custodian. Its printable form is :
+// oprf-escrow1:<suite>:<kid>:<index>/<total>:<threshold>:<truncated key fingerprint>:<hex share>:<hex checksum>
+type EscrowShare struct {
+	Suite          oprf.Suite
+	KeyID          string
+	Index          uint16
+	Total          uint16
+	Threshold      uint16
+	KeyFingerprint string
+	PrivateKey     *oprf.PrivateKey
+}
+
+// ExportEscrowShares splits the key into total printable shares, any threshold of them rebuild the key.
+func ExportEscrowShares(suite oprf.Suite, key *Key, threshold, total int) ([]*EscrowShare, error) {
+	if strings.Contains(key.ID, ":") {
+		return nil, fmt.Errorf("%w : the key ID %q contains a colon", ErrInvalidEscrowShare, key.ID)
+	}
+
+	shares, err := SplitKey(suite, key, threshold, total)
+	if err != nil {
+		return nil, err
+	}
+
+	keyFingerprint := Fingerprint(suite, key.PrivateKey.Public())[:escrowFingerprintLength]
+	escrowShares := make([]*EscrowShare, len(shares))
+
+	for index, share := range shares {
+		escrowShares[index] = &EscrowShare{
+			Suite:          suite,
+			KeyID:          key.ID,
+			Index:          share.Share.Index,
+			Total:          share.Share.Total,
+			Threshold:      share.Share.Threshold,
+			KeyFingerprint: keyFingerprint,
+			PrivateKey:     share.PrivateKey,
+		}
+	}
+
+	return escrowShares, nil
+}
+
+// String returns the printable share with its checksum.
+func (s *EscrowShare) String() string {
+	serializedShare, _ := s.PrivateKey.MarshalBinary()
+
+	body := strings.Join([]string{
+		EscrowSharePrefix,
+		s.Suite.Identifier(),
+		s.KeyID,
+		fmt.Sprintf("%d/%d", s.Index, s.Total),
+		strconv.Itoa(int(s.Threshold)),
+		s.KeyFingerprint,
+		hex.EncodeToString(serializedShare),
+	}, ":")
+
+	return body + ":" + escrowChecksum(body)
+}
+
+// Label describes the share for its custodian.
+func (s *EscrowShare) Label() string {
+	return fmt.Sprintf("Share %d of %d (threshold %d) of the %s key %s, fingerprint %s",
+		s.Index, s.Total, s.Threshold, s.Suite.Identifier(), s.KeyID, s.KeyFingerprint)
+}
+
+// ParseEscrowShare verifies the checksum of the printable share and parses it.
+func ParseEscrowShare(line string) (*EscrowShare, error) {
+	line = strings.TrimSpace(line)
+
+	separator := strings.LastIndex(line, ":")
+	if separator < 0 {
+		return nil, ErrInvalidEscrowShare
+	}
+
+	body, checksum := line[:separator], line[separator+1:]
+	if escrowChecksum(body) != strings.ToLower(checksum) {
+		return nil, ErrEscrowChecksum
+	}
+
+	fields := strings.Split(line, ":")
+	if len(fields) != escrowShareFields || fields[0] != EscrowSharePrefix {
+		return nil, fmt.Errorf("%w : unknown format", ErrInvalidEscrowShare)
+	}
+
+	suite, err := oprf.GetSuite(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidEscrowShare, err.Error())
+	}
+
+	var index, total, threshold uint16
+	if _, err := fmt.Sscanf(fields[3]+" "+fields[4], "%d/%d %d", &index, &total, &threshold); err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidEscrowShare, err.Error())
+	}
+
+	serializedShare, err := hex.DecodeString(fields[6])
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidEscrowShare, err.Error())
+	}
+
+	privateKey := new(oprf.PrivateKey)
+	if err := privateKey.UnmarshalBinary(suite, serializedShare); err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidEscrowShare, err.Error())
+	}
+
+	return &EscrowShare{
+		Suite:          suite,
+		KeyID:          fields[2],
+		Index:          index,
+		Total:          total,
+		Threshold:      threshold,
+		KeyFingerprint: fields[5],
+		PrivateKey:     privateKey,
+	}, nil
+}
+
+// ImportEscrowShares rebuilds the key from a threshold of shares and checks that its public key matches
+// the published fingerprint. The shares must be numbered from 1 and agree on the key, the threshold and the total.
+func ImportEscrowShares(escrowShares []*EscrowShare, fingerprint string) (*Key, error) {
+	if len(escrowShares) == 0 {
+		return nil, fmt.Errorf("%w : no share", ErrInvalidThreshold)
+	}
+
+	first := escrowShares[0]
+	shares := make([]*Key, 0, len(escrowShares))
+	indices := make(map[uint16]bool)
+
+	for _, escrowShare := range escrowShares {
+		if escrowShare.Suite.Identifier() != first.Suite.Identifier() || escrowShare.KeyID != first.KeyID ||
+			escrowShare.Threshold != first.Threshold || escrowShare.Total != first.Total ||
+			escrowShare.KeyFingerprint != first.KeyFingerprint {
+			return nil, fmt.Errorf("%w : the shares belong to different keys or splits", ErrInvalidEscrowShare)
+		}
+
+		// the share 0 would be the key itself
+		if escrowShare.Index == 0 || escrowShare.Index > escrowShare.Total {
+			return nil, fmt.Errorf("%w : share %d of %d", ErrInvalidEscrowShare, escrowShare.Index, escrowShare.Total)
+		}
+
+		// a custodian may provide the same share twice
+		if indices[escrowShare.Index] {
+			continue
+		}
+
+		indices[escrowShare.Index] = true
+
+		shares = append(shares, &Key{ //nolint:exhaustivestruct
+			PrivateKey: escrowShare.PrivateKey,
+			Share:      &KeyShareInfo{Index: escrowShare.Index, Threshold: escrowShare.Threshold}, //nolint:exhaustivestruct
+		})
+	}
+
+	privateKey, err := CombineKeyShares(first.Suite, shares)
+	if err != nil {
+		return nil, err
+	}
+
+	rebuiltFingerprint := Fingerprint(first.Suite, privateKey.Public())
+	if rebuiltFingerprint != strings.ToLower(fingerprint) {
+		return nil, fmt.Errorf("%w : rebuilt %s, published %s", ErrFingerprintMatch, rebuiltFingerprint, fingerprint)
+	}
+
+	// the creation time is not escrowed
+	key := NewKey(first.Suite, privateKey)
+	key.ID = first.KeyID
+
+	return key, nil
+}
+
+// escrowChecksum returns the hex encoded truncated SHA-256 hash of the share body.
+func escrowChecksum(body string) string {
+	hash := sha256.Sum256([]byte(body))
+
+	return hex.EncodeToString(hash[:escrowChecksumLength])
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+func TestEscrowShares(t *testing.T) {
+	suite := oprf.SuiteP384
+
+	keySet, err := GenerateKeySet(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := keySet.Active()
+	fingerprint := Fingerprint(suite, key.PrivateKey.Public())
+
+	escrowShares, err := ExportEscrowShares(suite, key, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the custodians 1, 3 and 5 type their printed shares
+	custodianShares := make([]*EscrowShare, 0, 3)
+
+	for _, index := range []int{0, 2, 4} {
+		escrowShare, err := ParseEscrowShare(escrowShares[index].String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		custodianShares = append(custodianShares, escrowShare)
+	}
+
+	importedKey, err := ImportEscrowShares(custodianShares, fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if importedKey.ID != key.ID || Fingerprint(suite, importedKey.PrivateKey.Public()) != fingerprint {
+		t.Errorf("the imported key does not match")
+	}
+
+	if _, err := ImportEscrowShares(custodianShares[:2], fingerprint); !errors.Is(err, ErrInvalidThreshold) {
+		t.Errorf("2 shares should not be enough : %v", err)
+	}
+
+	if _, err := ImportEscrowShares(custodianShares, strings.Repeat("0", 64)); !errors.Is(err, ErrFingerprintMatch) {
+		t.Errorf("a wrong fingerprint should be rejected : %v", err)
+	}
+
+	// the shares of another split or a share 0 aren't combined
+	otherSplit := *custodianShares[2]
+	otherSplit.Total = 6
+
+	zeroShare := *custodianShares[2]
+	zeroShare.Index = 0
+
+	for _, share := range []*EscrowShare{&otherSplit, &zeroShare} {
+		shares := []*EscrowShare{custodianShares[0], custodianShares[1], share}
+		if _, err := ImportEscrowShares(shares, fingerprint); !errors.Is(err, ErrInvalidEscrowShare) {
+			t.Errorf("the share %d of %d should be rejected : %v", share.Index, share.Total, err)
+		}
+	}
+
+	// a typo in the share
+	printed := []byte(escrowShares[1].String())
+	printed[len(printed)-20] ^= 1
+
+	if _, err := ParseEscrowShare(string(printed)); !errors.Is(err, ErrEscrowChecksum) {
+		t.Errorf("a modified share should be rejected : %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+// FullEvaluateHandler is the endpoint of the trusted callers, computing the PRF outputs of the inputs without
+// blinding in every mode. The router authenticates it with its own bearer token, every call is audited.
+// The inputs are checked with the limits of the blinded elements, and the public information with the info policy.
+// For instance :
+// curl -X POST http://localhost:1323/api/full_evaluate -H "Authorization: Bearer $OPRF_TRUSTED_CALLER_TOKEN" \
+// -H 'Content-Type: application/json' -d '{"suite": "P256-SHA256", "mode": "verifiable", "inputs": ["AA==", "/w=="]}'
+func (s *OPRFServerController) FullEvaluateHandler(c echo.Context) (err error) {
+	fullEvaluationRequest := new(protocol.FullEvaluationRequest)
+	keyID := ""
+
+	defer func() {
+		auditFullEvaluation(c, fullEvaluationRequest, keyID, err)
+	}()
+
+	body, err := s.limits.ReadBody(c)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, fullEvaluationRequest); err != nil {
+		return protocol.NewError(protocol.CodeInvalidRequest, err.Error())
+	}
+
+	mode, err := fullEvaluationRequest.Mode.OPRFMode()
+	if err != nil {
+		return protocol.NewError(protocol.CodeUnsupportedMode, err.Error())
+	}
+
+	if err := s.limits.ValidateInputs(mode, fullEvaluationRequest); err != nil {
+		return err
+	}
+
+	key, server, err := s.evaluationServer(mode, fullEvaluationRequest.Suite, fullEvaluationRequest.KeyID)
+	if err != nil {
+		return APIError(err)
+	}
+
+	keyID = key.ID
+
+	// the output of a key share is a partial evaluation, not a PRF output
+	if key.Share != nil {
+		return protocol.NewError(protocol.CodeUnsupportedMode, "the full evaluation is not supported with a key share")
+	}
+
+	if server == nil {
+		return protocol.NewError(protocol.CodeUnsupportedMode, "the %s mode isn't served with the key %q",
+			fullEvaluationRequest.Mode, key.ID)
+	}
+
+	info := fullEvaluationRequest.Info
+	if mode == oprf.PartialObliviousMode {
+		if info, err = s.infoPolicy.Apply(fullEvaluationRequest.Info); err != nil {
+			return APIError(err)
+		}
+	}
+
+	outputs := make([][]byte, len(fullEvaluationRequest.Inputs))
+
+	for index, input := range fullEvaluationRequest.Inputs {
+		if outputs[index], err = server.FullEvaluate(input, info); err != nil {
+			return APIError(err)
+		}
+	}
+
+	s.recordEvaluations(key, len(outputs))
+
+	response := &protocol.FullEvaluationResponse{
+		Suite:   server.Suite().Identifier(),
+		KeyID:   key.ID,
+		Outputs: outputs,
+		Info:    nil,
+	}
+
+	if !bytes.Equal(info, fullEvaluationRequest.Info) {
+		response.Info = info
+	}
+
+	return c.JSON(http.StatusOK, response) //nolint:wrapcheck
+}
+
+// AuditUnauthenticatedFullEvaluation logs the caller of a full evaluation refused by the authentication.
+func AuditUnauthenticatedFullEvaluation(c echo.Context, err error) {
+	log.Printf("Audit : unauthenticated full evaluation by %s : %s\n", c.RealIP(), err)
+}
+
+// auditFullEvaluation logs the caller, the request and the result of a full evaluation. The inputs aren't logged.
+func auditFullEvaluation(c echo.Context, fullEvaluationRequest *protocol.FullEvaluationRequest, keyID string,
+	err error,
+) {
+	result := "evaluated"
+	if err != nil {
+		result = string(APIError(err).Code)
+	}
+
+	log.Printf("Audit : full evaluation by %s of %d inputs, suite %q, mode %q, key %q, %d bytes info : %s\n",
+		c.RealIP(), len(fullEvaluationRequest.Inputs), fullEvaluationRequest.Suite, fullEvaluationRequest.Mode, keyID,
+		len(fullEvaluationRequest.Info), result)
+}
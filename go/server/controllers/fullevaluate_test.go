@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+func TestFullEvaluateHandler(t *testing.T) {
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	var auditLog bytes.Buffer
+
+	log.SetOutput(&auditLog)
+	defer log.SetOutput(os.Stderr)
+
+	fullEvaluate := func(fullEvaluationRequest *protocol.FullEvaluationRequest) (*protocol.FullEvaluationResponse,
+		error,
+	) {
+		body, _ := json.Marshal(fullEvaluationRequest)
+		request := httptest.NewRequest(http.MethodPost, "/api/full_evaluate", bytes.NewReader(body))
+		request.Header.Set(echo.HeaderContentType, protocol.ContentTypeJSON)
+
+		recorder := httptest.NewRecorder()
+		if err := controller.FullEvaluateHandler(echo.New().NewContext(request, recorder)); err != nil {
+			return nil, err
+		}
+
+		response := new(protocol.FullEvaluationResponse)
+
+		return response, json.Unmarshal(recorder.Body.Bytes(), response)
+	}
+
+	inputs := [][]byte{{0x00}, {0xFF}}
+
+	for _, mode := range []oprf.Mode{oprf.BaseMode, oprf.VerifiableMode, oprf.PartialObliviousMode} {
+		var info []byte
+		if mode == oprf.PartialObliviousMode {
+			info = []byte("survey")
+		}
+
+		response, err := fullEvaluate(&protocol.FullEvaluationRequest{
+			Suite:  oprf.SuiteP256.Identifier(),
+			Mode:   protocol.NewMode(mode),
+			Info:   info,
+			Inputs: inputs,
+			KeyID:  "",
+		})
+		if err != nil {
+			t.Fatal(mode, err)
+		}
+
+		// the outputs are the outputs of the OPRF protocol with the key
+		_, server, err := controller.evaluationServer(mode, response.Suite, response.KeyID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for index, input := range inputs {
+			if !server.VerifyFinalize(input, info, response.Outputs[index]) {
+				t.Errorf("%s mode : unexpected output %d", protocol.NewMode(mode), index)
+			}
+		}
+	}
+
+	if _, err := fullEvaluate(&protocol.FullEvaluationRequest{
+		Suite:  oprf.SuiteP256.Identifier(),
+		Mode:   protocol.ModeVerifiable,
+		Info:   []byte("survey"),
+		Inputs: inputs,
+		KeyID:  "",
+	}); !errors.Is(err, protocol.ErrInfoNotAllowed) {
+		t.Errorf("info evaluated in the verifiable mode : %v", err)
+	}
+
+	// every call is audited, without the inputs
+	if audits := strings.Count(auditLog.String(), "Audit : full evaluation"); audits != 4 {
+		t.Errorf("%d audited calls, expected 4", audits)
+	}
+
+	if !strings.Contains(auditLog.String(), string(protocol.CodeInfoNotAllowed)) {
+		t.Errorf("the refused call isn't audited with its error : %s", auditLog.String())
+	}
+}
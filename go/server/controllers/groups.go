@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"bytes"
+
+	"github.com/cloudflare/circl/group"
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+// evaluationGroup holds the elements of a batch sharing a public information
+type evaluationGroup struct {
+	// indices are the positions of the elements in the request
+	indices  []uint16
+	elements []group.Element
+	info     []byte
+}
+
+// groupElements groups the elements by public information, in the order of the first element of each group.
+// The elements share the info if there is no info per element, the invalid (nil) elements of a partial-success
+// batch are skipped. The number of elements has been checked by the limits.
+func groupElements(elements []group.Element, info []byte, infos [][]byte) []*evaluationGroup {
+	groupIndices := make(map[string]int)
+
+	var groups []*evaluationGroup
+
+	for index, element := range elements {
+		if element == nil {
+			continue
+		}
+
+		if len(infos) > 0 {
+			info = infos[index]
+		}
+
+		groupIndex, ok := groupIndices[string(info)]
+		if !ok {
+			groupIndex = len(groups)
+			groupIndices[string(info)] = groupIndex
+			groups = append(groups, &evaluationGroup{indices: nil, elements: nil, info: info})
+		}
+
+		groups[groupIndex].indices = append(groups[groupIndex].indices, uint16(index))
+		groups[groupIndex].elements = append(groups[groupIndex].elements, element)
+	}
+
+	return groups
+}
+
+// evaluateGroups evaluates each group with its own public information and proof. In the partially oblivious mode
+// the public information is checked against the policy, which may inject server-side fields.
+// The groups rejected by the policy are skipped in a partial-success batch, the statuses of their elements report
+// the error, the statuses of the evaluated elements are updated.
+func (s *OPRFServerController) evaluateGroups(server Server, mode oprf.Mode, groups []*evaluationGroup,
+	statuses []protocol.ElementStatus,
+) ([]protocol.EvaluationGroup, error) {
+	evaluationGroups := make([]protocol.EvaluationGroup, 0, len(groups))
+
+	for _, elementGroup := range groups {
+		info := elementGroup.info
+		if mode == oprf.PartialObliviousMode {
+			var err error
+			if info, err = s.infoPolicy.Apply(elementGroup.info); err != nil && statuses != nil {
+				for _, index := range elementGroup.indices {
+					statuses[index].Error = APIError(err)
+				}
+
+				continue
+			} else if err != nil {
+				return nil, APIError(err)
+			}
+		}
+
+		evaluation, err := server.Evaluate(&oprf.EvaluationRequest{Elements: elementGroup.elements}, info)
+		if err != nil {
+			return nil, APIError(err)
+		} else if evaluation == nil {
+			return nil, protocol.NewError(protocol.CodeInternal, "no evaluation")
+		}
+
+		serializedEvaluation, err := NewEvaluation(evaluation)
+		if err != nil {
+			return nil, APIError(err)
+		}
+
+		evaluatedGroup := protocol.EvaluationGroup{
+			Indices:    elementGroup.indices,
+			Evaluation: serializedEvaluation,
+			Info:       nil,
+		}
+
+		// the client finalizes with the evaluated public information
+		if !bytes.Equal(info, elementGroup.info) {
+			evaluatedGroup.Info = info
+		}
+
+		evaluationGroups = append(evaluationGroups, evaluatedGroup)
+
+		if statuses != nil {
+			for _, index := range elementGroup.indices {
+				statuses[index].Evaluated = true
+			}
+		}
+	}
+
+	return evaluationGroups, nil
+}
+
+// failedEvaluation returns the error of the first failed element of a partial-success batch without any evaluated
+// element.
+func failedEvaluation(statuses []protocol.ElementStatus) error {
+	for _, status := range statuses {
+		if status.Error != nil {
+			return status.Error
+		}
+	}
+
+	return protocol.NewError(protocol.CodeInvalidElement, "no element was evaluated")
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+func TestEvaluateHandlerGroups(t *testing.T) {
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	evaluate := func(evaluationRequest *protocol.EvaluationRequest, contentType string) *protocol.EvaluationResponse {
+		body, _ := protocol.EncodeEvaluationRequest(contentType, evaluationRequest)
+		request := httptest.NewRequest(http.MethodPost, "/api/v2/evaluate", bytes.NewReader(body))
+		request.Header.Set(echo.HeaderContentType, contentType)
+
+		recorder := httptest.NewRecorder()
+		if err := controller.EvaluateHandler(echo.New().NewContext(request, recorder)); err != nil {
+			t.Fatal(err)
+		}
+
+		response := new(protocol.EvaluationResponse)
+		if err := protocol.DecodeEvaluationResponse(contentType, recorder.Body.Bytes(), response); err != nil {
+			t.Fatal(err)
+		}
+
+		return response
+	}
+
+	evaluationRequest := newTestEvaluationRequest(t, oprf.SuiteP256, oprf.PartialObliviousMode)
+	elements := append(evaluationRequest.BlindedElements, evaluationRequest.BlindedElements[0])
+	evaluationRequest.Info = nil
+	evaluationRequest.BlindedElements = elements
+	evaluationRequest.Infos = [][]byte{[]byte("survey"), []byte("census"), []byte("survey")}
+
+	for _, contentType := range []string{protocol.ContentTypeJSON, protocol.ContentTypeBinary} {
+		response := evaluate(evaluationRequest, contentType)
+
+		// the groups are in the order of their first element
+		if response.Evaluation != nil || len(response.Groups) != 2 ||
+			!reflect.DeepEqual(response.Groups[0].Indices, []uint16{0, 2}) ||
+			!reflect.DeepEqual(response.Groups[1].Indices, []uint16{1}) {
+			t.Fatalf("%s : unexpected groups %v", contentType, response.Groups)
+		}
+
+		// each group is evaluated with its public information
+		for _, evaluationGroup := range response.Groups {
+			groupRequest := &protocol.EvaluationRequest{ //nolint:exhaustivestruct
+				Suite: evaluationRequest.Suite,
+				Mode:  evaluationRequest.Mode,
+				Info:  evaluationRequest.Infos[evaluationGroup.Indices[0]],
+			}
+
+			for _, index := range evaluationGroup.Indices {
+				groupRequest.BlindedElements = append(groupRequest.BlindedElements, elements[index])
+			}
+
+			if groupResponse := evaluate(groupRequest, protocol.ContentTypeJSON); !reflect.DeepEqual(
+				groupResponse.Evaluation.Elements, evaluationGroup.Evaluation.Elements) {
+				t.Errorf("%s : group %v evaluated as %x, expected %x", contentType, evaluationGroup.Indices,
+					evaluationGroup.Evaluation.Elements, groupResponse.Evaluation.Elements)
+			}
+		}
+	}
+
+	// the verifiable mode has no public information
+	evaluationRequest.Mode = protocol.ModeVerifiable
+	body, _ := json.Marshal(evaluationRequest)
+	request := httptest.NewRequest(http.MethodPost, "/api/v2/evaluate", bytes.NewReader(body))
+	request.Header.Set(echo.HeaderContentType, protocol.ContentTypeJSON)
+
+	if err := controller.EvaluateHandler(echo.New().NewContext(request, httptest.NewRecorder())); err == nil {
+		t.Errorf("grouped batch evaluated in the verifiable mode")
+	}
+}
@@ -1,52 +1,45 @@
 package controllers
 
 import (
-	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"time"
 
+	"github.com/cloudflare/circl/group"
 	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
 	"github.com/labstack/echo/v4"
 )
 
-// EvaluationRequest represents an evaluation requests
-type EvaluationRequest struct {
-	Suite           string    `json:"suite"`
-	Info            string    `json:"info"`
-	BlindedElements [][]byte  `json:"blinded_elements"`
-	Mode            oprf.Mode `json:"mode"`
-}
-
-type EvaluationResponse struct {
-	Evaluation          *oprf.Evaluation `json:"evaluation"`
-	Suite               string           `json:"suite"`
-	SerializedPublicKey []byte           `json:"serialized_public_key"`
-}
-
-// WrappedEvaluationResponse allows to partially parse the JSON input
-type WrappedEvaluationResponse struct {
-	Evaluation          *WrappedEvaluation `json:"evaluation"`
-	Suite               string             `json:"suite"`
-	SerializedPublicKey []byte             `json:"serialized_public_key"`
-}
+// NewEvaluationResponse serializes the evaluation in the compressed form of RFC 9497.
+func NewEvaluationResponse(evaluation *oprf.Evaluation, suiteID string, keyID string,
+	serializedPublicKey []byte,
+) (*protocol.EvaluationResponse, error) {
+	serializedEvaluation, err := NewEvaluation(evaluation)
+	if err != nil {
+		return nil, err
+	}
 
-type WrappedEvaluation struct {
-	Proof    []byte   `json:"proof"`
-	Elements [][]byte `json:"elements"`
+	return &protocol.EvaluationResponse{
+		Evaluation:          serializedEvaluation,
+		Suite:               suiteID,
+		KeyID:               keyID,
+		SerializedPublicKey: serializedPublicKey,
+	}, nil
 }
 
-// UnmarshalJSON first parse the JSON input into a WrappedEvaluationResponse
-// and then convert the string into a byte array into EvaluationResponse.
-func (r *EvaluationResponse) MarshalJSON() ([]byte, error) {
-	elements := r.Evaluation.Elements
-	rawElements := make([][]byte, len(elements))
+// NewEvaluation serializes the elements and the proof of the evaluation in the compressed form of RFC 9497.
+func NewEvaluation(evaluation *oprf.Evaluation) (*protocol.Evaluation, error) {
+	elements := make([][]byte, len(evaluation.Elements))
 
-	for index, element := range elements {
-		blindedElement, err := element.MarshalBinary()
+	for index, element := range evaluation.Elements {
+		serializedElement, err := element.MarshalBinaryCompress()
 		if err != nil {
-			return nil, err
+			return nil, err //nolint:wrapcheck
 		}
 
-		rawElements[index] = blindedElement
+		elements[index] = serializedElement
 	}
 
 	var (
@@ -54,90 +47,230 @@ func (r *EvaluationResponse) MarshalJSON() ([]byte, error) {
 		err   error
 	)
 
-	if r.Evaluation.Proof != nil {
-		proof, err = r.Evaluation.Proof.MarshalBinary()
+	if evaluation.Proof != nil {
+		proof, err = evaluation.Proof.MarshalBinary()
 		if err != nil {
-			return nil, err
+			return nil, err //nolint:wrapcheck
 		}
 	}
 
-	return json.Marshal(WrappedEvaluationResponse{
-		Evaluation: &WrappedEvaluation{
-			Proof:    proof,
-			Elements: rawElements,
-		},
-		Suite:               r.Suite,
-		SerializedPublicKey: r.SerializedPublicKey,
-	})
+	return &protocol.Evaluation{
+		Proof:    proof,
+		Elements: elements,
+	}, nil
 }
 
-func NewEvaluationResponse(evaluation *oprf.Evaluation, suiteID string, serializedPublicKey []byte) *EvaluationResponse {
-	return &EvaluationResponse{
-		Evaluation:          evaluation,
-		Suite:               suiteID,
-		SerializedPublicKey: serializedPublicKey,
+// NewPublicKeyInfo returns the published metadata of the key. The fingerprint of the public key can be compared
+// out of band.
+func NewPublicKeyInfo(suite oprf.Suite, key *Key) *protocol.PublicKeyInfo {
+	publicKeyInfo := &protocol.PublicKeyInfo{
+		ID:             key.ID,
+		State:          string(key.State),
+		PublicKey:      SerializePublicKey(key.PrivateKey),
+		Fingerprint:    Fingerprint(suite, key.PrivateKey.Public()),
+		NotBefore:      key.NotBefore,
+		NotAfter:       key.NotAfter,
+		MaxEvaluations: key.MaxEvaluations,
+	}
+
+	// the keys derived from the master seed have no creation date
+	if !key.CreatedAt.IsZero() {
+		createdAt := key.CreatedAt
+		publicKeyInfo.CreatedAt = &createdAt
 	}
+
+	if key.Share != nil {
+		publicKeyInfo.Share = &protocol.KeyShareInfo{
+			Index:          key.Share.Index,
+			Threshold:      key.Share.Threshold,
+			Total:          key.Share.Total,
+			GroupPublicKey: key.Share.GroupPublicKey,
+		}
+	}
+
+	return publicKeyInfo
 }
 
-// GetKeysHandler is an endpoint returning the static keys
+// GetKeysHandler is an endpoint returning the non-retired keys of each suite with their metadata
 func (s *OPRFServerController) GetKeysHandler(c echo.Context) error {
 	s.keysMu.RLock()
-	keys := make(map[string][]byte)
+	keys := make(map[string][]*protocol.PublicKeyInfo)
 
-	for suiteID, privateKey := range s.keys {
-		keys[suiteID] = SerializePublicKey(privateKey)
+	for suiteID, keySet := range s.keys {
+		for _, key := range keySet.Published() {
+			keys[suiteID] = append(keys[suiteID], NewPublicKeyInfo(keySet.Suite(), key))
+		}
 	}
 	s.keysMu.RUnlock()
 
 	return c.JSON(http.StatusOK, &keys) //nolint:wrapcheck
 }
 
-// EvaluateHandler is an endpoint that evaluate an EvaluationRequest.
-// It returns an HTTP 400 Bad Request Error on incorrect input and
-// an HTTP 500 Internal OPRFServerController Error if the evaluation fails.
+// EvaluateHandler is the v2 endpoint evaluating an EvaluationRequest with a named mode.
+// It returns a protocol.Error with the status of its code : an HTTP 400 Bad Request Error on incorrect input,
+// an HTTP 422 Unprocessable Entity Error if the key is unavailable and an HTTP 500 Internal Server Error
+// if the evaluation fails.
+// The optional "kid" selects a published key, otherwise the active key of the suite is used.
 // For instance :
-// curl -X POST http://localhost:1323/api/evaluate -H 'Content-Type: application/json' -d \
-// '{"suite": 3, "mode": 1, "info": "7465737420696e666f", "blinded_elements": \
-// [[2, 99, 233, 95, 211, 165, 194, 204, 118, 22, 17, 134, 162, 84, 135, 138, 180, 7, \
-// 229, 225, 238, 137, 138, 247, 196, 178, 119, 121, 218, 135, 36, 201, 132],[2, 61, 128, \
-// 127, 32, 157, 20, 86, 131, 22, 159, 225, 197, 38, 118, 154, 158, 71, 70, 50, 188, 116, \
-// 40, 80, 108, 72, 139, 91, 98, 146, 135, 105, 40]]}'
+// curl -X POST http://localhost:1323/api/v2/evaluate -H 'Content-Type: application/json' -d \
+// '{"suite": "P256-SHA256", "mode": "verifiable", "info": "", "blinded_elements": \
+// ["A2ERb8B86HOCglcuMkqa3BY3o2tM6BXbWYyqJ8XkApha", "Aqr6Aui1wWPnfEmTRxzq3BnMvGc2FzRM4wnoJSCiCk7f"]}'
 func (s *OPRFServerController) EvaluateHandler(c echo.Context) error {
-	evaluationRequest := new(EvaluationRequest)
-	if err := c.Bind(evaluationRequest); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	body, err := s.limits.ReadBody(c)
+	if err != nil {
+		return err
 	}
 
-	s.serversMu.RLock()
-	server := s.servers[evaluationRequest.Mode][evaluationRequest.Suite]
-	s.serversMu.RUnlock()
+	evaluationRequest := new(protocol.EvaluationRequest)
+	if err := protocol.DecodeEvaluationRequest(c.Request().Header.Get(echo.HeaderContentType), body,
+		evaluationRequest); err != nil {
+		return decodingError(err)
+	}
 
-	if server == nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "No server")
+	return s.evaluate(c, evaluationRequest)
+}
+
+// EvaluateV1Handler is the v1 endpoint, whose JSON and CBOR requests have the number of the mode
+// (0 base, 1 verifiable, 2 partially oblivious).
+func (s *OPRFServerController) EvaluateV1Handler(c echo.Context) error {
+	body, err := s.limits.ReadBody(c)
+	if err != nil {
+		return err
 	}
 
-	blindedElements, err := DeserializeElements(evaluationRequest.BlindedElements, server.Suite().Group())
+	evaluationRequest := new(protocol.EvaluationRequest)
+	if err := protocol.DecodeV1EvaluationRequest(c.Request().Header.Get(echo.HeaderContentType), body,
+		evaluationRequest); err != nil {
+		return decodingError(err)
+	}
+
+	return s.evaluate(c, evaluationRequest)
+}
+
+// decodingError returns the typed error of a request that can't be decoded, such as the unknown suite of a binary
+// request whose element length depends on the suite, and invalid_request otherwise.
+func decodingError(err error) error {
+	var apiError *protocol.Error
+	if errors.As(err, &apiError) {
+		return apiError
+	}
+
+	return protocol.NewError(protocol.CodeInvalidRequest, err.Error())
+}
+
+// evaluate evaluates the request and sends the response with the first supported encoding of the Accept header.
+func (s *OPRFServerController) evaluate(c echo.Context, evaluationRequest *protocol.EvaluationRequest) error {
+	mode, err := evaluationRequest.Mode.OPRFMode()
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Couldn't decode the blinded elements")
+		return protocol.NewError(protocol.CodeUnsupportedMode, err.Error())
+	}
+
+	// the limits are checked before any group operation
+	if err := s.limits.Validate(mode, evaluationRequest); err != nil {
+		return err
 	}
 
-	// Calculate the evaluation
-	evaluation, err := server.Evaluate(
-		&oprf.EvaluationRequest{
-			Elements: blindedElements,
-		},
-		[]byte(evaluationRequest.Info),
+	key, server, err := s.evaluationServer(mode, evaluationRequest.Suite, evaluationRequest.KeyID)
+	if err != nil {
+		return APIError(err)
+	}
+
+	if key.Share != nil && mode == oprf.PartialObliviousMode {
+		return protocol.NewError(protocol.CodeUnsupportedMode,
+			"the partially oblivious mode is not supported with a key share")
+	}
+
+	if server == nil {
+		return protocol.NewError(protocol.CodeUnsupportedMode, "the %s mode isn't served with the key %q",
+			evaluationRequest.Mode, key.ID)
+	}
+
+	// a partial-success batch reports the invalid elements in their statuses instead of failing
+	var (
+		blindedElements []group.Element
+		statuses        []protocol.ElementStatus
 	)
-	if err != nil || evaluation == nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+
+	if evaluationRequest.PartialSuccess {
+		blindedElements, statuses = DeserializeValidElements(evaluationRequest.BlindedElements,
+			server.Suite().Group())
+	} else if blindedElements, err = DeserializeElements(evaluationRequest.BlindedElements,
+		server.Suite().Group()); err != nil {
+		return APIError(err)
 	}
 
-	// Send the public key to the client for the finalization (needed for Serverless Functions)
-	s.keysMu.RLock()
-	serializedPublicKey := SerializePublicKey(s.keys[evaluationRequest.Suite])
-	s.keysMu.RUnlock()
+	// a grouped batch is evaluated by group of elements sharing a public information
+	groups := groupElements(blindedElements, evaluationRequest.Info, evaluationRequest.Infos)
 
-	response := NewEvaluationResponse(evaluation, server.Suite().Identifier(), serializedPublicKey)
+	evaluationGroups, err := s.evaluateGroups(server, mode, groups, statuses)
+	if err != nil {
+		return err
+	} else if len(evaluationGroups) == 0 {
+		return failedEvaluation(statuses)
+	}
+
+	evaluated := 0
+	for _, evaluatedGroup := range evaluationGroups {
+		evaluated += len(evaluatedGroup.Indices)
+	}
+
+	s.recordEvaluations(key, evaluated)
+
+	// Send the key ID and the public key to the client for the finalization (needed for Serverless Functions)
+	serializedPublicKey := SerializePublicKey(key.PrivateKey)
+
+	response := &protocol.EvaluationResponse{ //nolint:exhaustivestruct
+		Suite:               server.Suite().Identifier(),
+		KeyID:               key.ID,
+		SerializedPublicKey: serializedPublicKey,
+		Statuses:            statuses,
+	}
+
+	if len(evaluationRequest.Infos) > 0 {
+		response.Groups = evaluationGroups
+	} else {
+		response.Evaluation, response.Info = evaluationGroups[0].Evaluation, evaluationGroups[0].Info
+	}
+
+	if key.Share != nil {
+		response.ShareIndex = key.Share.Index
+	}
+
+	contentType := protocol.NegotiateContentType(c.Request().Header.Get(echo.HeaderAccept),
+		c.Request().Header.Get(echo.HeaderContentType))
+
+	data, err := protocol.EncodeEvaluationResponse(contentType, response)
+	if err != nil {
+		return APIError(err)
+	}
+
+	return c.Blob(http.StatusOK, contentType, data) //nolint:wrapcheck
+}
+
+// recordEvaluations records the evaluations of the key, which is rotated as soon as it reaches its maximum number of
+// evaluations.
+func (s *OPRFServerController) recordEvaluations(key *Key, count int) {
+	if key.RecordEvaluations(uint64(count)) {
+		if _, err := s.Rotate(time.Now()); err != nil {
+			log.Println("Rotation error :", err)
+		}
+	}
+}
+
+// ReloadResponse lists the suites whose keys changed
+type ReloadResponse struct {
+	Reloaded []string `json:"reloaded"`
+}
+
+// ReloadHandler is an admin endpoint reloading the keys from the key provider.
+// It returns an HTTP 500 Internal Server Error if the keys can't be loaded, the current keys are then kept.
+func (s *OPRFServerController) ReloadHandler(c echo.Context) error {
+	reloaded, err := s.Reload()
+	if err != nil {
+		log.Println("Reload error :", err)
+
+		return protocol.NewError(protocol.CodeInternal, "couldn't reload the keys")
+	}
 
-	return c.JSON(http.StatusOK, response) //nolint:wrapcheck
+	return c.JSON(http.StatusOK, &ReloadResponse{Reloaded: reloaded}) //nolint:wrapcheck
 }
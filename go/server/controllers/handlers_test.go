@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+func newTestEvaluationRequest(t *testing.T, suite oprf.Suite, mode oprf.Mode) *protocol.EvaluationRequest {
+	t.Helper()
+
+	_, oprfEvaluationRequest, err := oprf.NewClient(suite).Blind([][]byte{{0x00}, {0xFF}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blindedElements := make([][]byte, len(oprfEvaluationRequest.Elements))
+	for index, element := range oprfEvaluationRequest.Elements {
+		blindedElements[index], _ = element.MarshalBinaryCompress()
+	}
+
+	// the public information is only sent in the partially oblivious mode, it isn't valid UTF-8
+	var info []byte
+	if mode == oprf.PartialObliviousMode {
+		info = []byte{0x00, 0xFF, 0x10, 0x80}
+	}
+
+	return &protocol.EvaluationRequest{
+		Suite:           suite.Identifier(),
+		Mode:            protocol.NewMode(mode),
+		Info:            info,
+		BlindedElements: blindedElements,
+		KeyID:           "",
+	}
+}
+
+func TestEvaluateHandlerEncodings(t *testing.T) {
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	evaluationRequest := newTestEvaluationRequest(t, oprf.SuiteP256, oprf.VerifiableMode)
+	jsonRequest, _ := protocol.EncodeEvaluationRequest(protocol.ContentTypeJSON, evaluationRequest)
+	cborRequest, _ := protocol.EncodeEvaluationRequest(protocol.ContentTypeCBOR, evaluationRequest)
+	binaryRequest, _ := protocol.EncodeEvaluationRequest(protocol.ContentTypeBinary, evaluationRequest)
+
+	for _, test := range []struct {
+		contentType, accept, expected string
+		body                          []byte
+	}{
+		{protocol.ContentTypeJSON, "", protocol.ContentTypeJSON, jsonRequest},
+		{protocol.ContentTypeCBOR, "", protocol.ContentTypeCBOR, cborRequest},
+		{protocol.ContentTypeBinary, "*/*", protocol.ContentTypeBinary, binaryRequest},
+		{protocol.ContentTypeJSON, "text/html, application/octet-stream;q=0.9", protocol.ContentTypeBinary, jsonRequest},
+		{protocol.ContentTypeBinary, protocol.ContentTypeCBOR, protocol.ContentTypeCBOR, binaryRequest},
+		{protocol.ContentTypeBinary, "text/html", protocol.ContentTypeJSON, binaryRequest},
+	} {
+		request := httptest.NewRequest(http.MethodPost, "/api/v2/evaluate", bytes.NewReader(test.body))
+		request.Header.Set(echo.HeaderContentType, test.contentType)
+		request.Header.Set(echo.HeaderAccept, test.accept)
+
+		recorder := httptest.NewRecorder()
+		if err := controller.EvaluateHandler(echo.New().NewContext(request, recorder)); err != nil {
+			t.Fatal(test.contentType, test.accept, err)
+		}
+
+		if contentType := protocol.MediaType(recorder.Header().Get(echo.HeaderContentType)); contentType != test.expected {
+			t.Errorf("%s request accepting %q : %s response, expected %s", test.contentType, test.accept,
+				contentType, test.expected)
+		}
+
+		if test.expected != protocol.ContentTypeBinary {
+			continue
+		}
+
+		// suite, kid, share index, public key, count, 2 elements and a 2 scalars proof
+		suiteGroup := oprf.SuiteP256.Group()
+		elementLength := int(suiteGroup.Params().CompressedElementLength)
+		length := 1 + len(evaluationRequest.Suite) + 1 + 16 + 2 + 2 + elementLength + 2 + 2*elementLength +
+			2 + 2*int(suiteGroup.Params().ScalarLength)
+
+		if recorder.Body.Len() != length {
+			t.Errorf("%d bytes binary response, expected %d", recorder.Body.Len(), length)
+		}
+	}
+}
+
+func TestEvaluateV1Handler(t *testing.T) {
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the v1 clients send a hex info in every mode, it is ignored outside of the partially oblivious mode
+	evaluationRequest := newTestEvaluationRequest(t, oprf.SuiteP256, oprf.BaseMode)
+	v1Request, _ := json.Marshal(&protocol.V1EvaluationRequest{
+		Suite:           evaluationRequest.Suite,
+		Info:            "7465737420696e666f",
+		BlindedElements: evaluationRequest.BlindedElements,
+		Mode:            oprf.VerifiableMode,
+		KeyID:           "",
+	})
+	v2Request, _ := json.Marshal(evaluationRequest)
+
+	for _, test := range []struct {
+		name     string
+		handler  echo.HandlerFunc
+		body     []byte
+		expected int
+	}{
+		{"v1 numeric mode", controller.EvaluateV1Handler, v1Request, http.StatusOK},
+		{"v2 named mode", controller.EvaluateHandler, v2Request, http.StatusOK},
+		{"v1 numeric mode on v2", controller.EvaluateHandler, v1Request, http.StatusBadRequest},
+		{"v2 named mode on v1", controller.EvaluateV1Handler, v2Request, http.StatusBadRequest},
+	} {
+		request := httptest.NewRequest(http.MethodPost, "/api/evaluate", bytes.NewReader(test.body))
+		request.Header.Set(echo.HeaderContentType, protocol.ContentTypeJSON)
+
+		recorder := httptest.NewRecorder()
+
+		status := http.StatusOK
+		if err := test.handler(echo.New().NewContext(request, recorder)); err != nil {
+			var apiError *protocol.Error
+			if !errors.As(err, &apiError) {
+				t.Fatal(test.name, err)
+			}
+
+			status = apiError.HTTPStatus()
+		}
+
+		if status != test.expected {
+			t.Errorf("%s : status %d, expected %d", test.name, status, test.expected)
+		}
+	}
+
+	// the v1 response of a verifiable request has a proof
+	request := httptest.NewRequest(http.MethodPost, "/api/evaluate", bytes.NewReader(v1Request))
+	request.Header.Set(echo.HeaderContentType, protocol.ContentTypeJSON)
+
+	recorder := httptest.NewRecorder()
+	if err := controller.EvaluateV1Handler(echo.New().NewContext(request, recorder)); err != nil {
+		t.Fatal(err)
+	}
+
+	var evaluationResponse protocol.EvaluationResponse
+	if err := protocol.DecodeEvaluationResponse(protocol.ContentTypeJSON, recorder.Body.Bytes(),
+		&evaluationResponse); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evaluationResponse.Evaluation.Proof) == 0 {
+		t.Error("no proof in the verifiable response")
+	}
+}
+
+func TestEvaluateHandlerErrors(t *testing.T) {
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	newRequest := func(update func(evaluationRequest *protocol.EvaluationRequest)) []byte {
+		evaluationRequest := newTestEvaluationRequest(t, oprf.SuiteP256, oprf.VerifiableMode)
+		update(evaluationRequest)
+		body, _ := json.Marshal(evaluationRequest)
+
+		return body
+	}
+
+	for _, test := range []struct {
+		name     string
+		body     []byte
+		expected *protocol.Error
+		status   int
+	}{
+		{"malformed body", []byte("{"), protocol.ErrInvalidRequest, http.StatusBadRequest},
+		{"unknown suite", newRequest(func(r *protocol.EvaluationRequest) { r.Suite = "P257-SHA256" }),
+			protocol.ErrUnknownSuite, http.StatusBadRequest},
+		{"unknown mode", newRequest(func(r *protocol.EvaluationRequest) { r.Mode = "oblivious" }),
+			protocol.ErrUnsupportedMode, http.StatusBadRequest},
+		{"invalid element", newRequest(func(r *protocol.EvaluationRequest) { r.BlindedElements[1] = []byte{0x02} }),
+			protocol.ErrInvalidElement, http.StatusBadRequest},
+		{"unknown key ID", newRequest(func(r *protocol.EvaluationRequest) { r.KeyID = "unknown" }),
+			protocol.ErrKeyUnavailable, http.StatusUnprocessableEntity},
+	} {
+		request := httptest.NewRequest(http.MethodPost, "/api/v2/evaluate", bytes.NewReader(test.body))
+		request.Header.Set(echo.HeaderContentType, protocol.ContentTypeJSON)
+
+		recorder := httptest.NewRecorder()
+		context := echo.New().NewContext(request, recorder)
+
+		err := controller.EvaluateHandler(context)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s : %v, expected %v", test.name, err, test.expected)
+
+			continue
+		}
+
+		// the error handler sends the code in a JSON body with the status of the code
+		HTTPErrorHandler(err, context)
+
+		if recorder.Code != test.status {
+			t.Errorf("%s : status %d, expected %d", test.name, recorder.Code, test.status)
+		}
+
+		if apiError := protocol.DecodeError(recorder.Code, recorder.Body.Bytes()); apiError.Code != test.expected.Code {
+			t.Errorf("%s : %s error body, expected %s", test.name, apiError.Code, test.expected.Code)
+		}
+	}
+	// the error code doesn't depend on the encoding, the binary element length depends on the suite
+	for _, contentType := range []string{protocol.ContentTypeJSON, protocol.ContentTypeCBOR, protocol.ContentTypeBinary} {
+		body, err := protocol.EncodeEvaluationRequest(contentType,
+			newTestEvaluationRequest(t, oprf.SuiteP256, oprf.VerifiableMode))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body = bytes.Replace(body, []byte("P256-SHA256"), []byte("P257-SHA256"), 1)
+
+		request := httptest.NewRequest(http.MethodPost, "/api/v2/evaluate", bytes.NewReader(body))
+		request.Header.Set(echo.HeaderContentType, contentType)
+
+		err = controller.EvaluateHandler(echo.New().NewContext(request, httptest.NewRecorder()))
+		if !errors.Is(err, protocol.ErrUnknownSuite) {
+			t.Errorf("%s : %v, expected %v", contentType, err, protocol.ErrUnknownSuite)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+var ErrInvalidInfoPolicy = errors.New("invalid info policy")
+
+// InfoPolicy is the policy of the public information of the partially oblivious mode. The zero policy accepts any
+// public information. A structured policy only accepts a protocol.StructuredInfo satisfying its schema, and can
+// inject server-side fields before the evaluation.
+type InfoPolicy struct {
+	// Structured rejects the free-form public information
+	Structured bool
+	// Required are the fields the client must send
+	Required []protocol.InfoField
+	// Allowed maps a field to its allowed values, any value of a field without allowlist is accepted
+	Allowed map[protocol.InfoField][]string
+	// Injected are the fields set by the server, for instance the tenant ID. The client can't send them.
+	Injected map[protocol.InfoField]string
+}
+
+// Validate checks that the schema is only set on a structured policy and that the injected fields are valid.
+func (p InfoPolicy) Validate() error {
+	if !p.Structured && (len(p.Required) > 0 || len(p.Allowed) > 0 || len(p.Injected) > 0) {
+		return fmt.Errorf("%w : a free-form policy has no schema", ErrInvalidInfoPolicy)
+	}
+
+	for _, field := range p.Required {
+		if _, ok := p.Injected[field]; ok {
+			return fmt.Errorf("%w : the %s field is both required and injected", ErrInvalidInfoPolicy, field)
+		}
+	}
+
+	injected := make(protocol.StructuredInfo, len(p.Injected))
+	for field, value := range p.Injected {
+		injected[field] = []byte(value)
+	}
+
+	if len(injected) > 0 {
+		if _, err := injected.MarshalBinary(); err != nil {
+			return fmt.Errorf("%w : injected fields : %s", ErrInvalidInfoPolicy, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Apply checks the public information against the policy and returns the information to evaluate, with the
+// injected fields. It returns an info_rejected error if the information doesn't satisfy the policy.
+func (p InfoPolicy) Apply(info []byte) ([]byte, error) {
+	if !p.Structured {
+		return info, nil
+	}
+
+	structuredInfo, err := protocol.ParseStructuredInfo(info)
+	if err != nil {
+		return nil, protocol.NewError(protocol.CodeInfoRejected, "a structured info is required : %s", err.Error())
+	}
+
+	for _, field := range p.Required {
+		if _, ok := structuredInfo[field]; !ok {
+			return nil, protocol.NewError(protocol.CodeInfoRejected, "the %s field is required", field)
+		}
+	}
+
+	for field, values := range p.Allowed {
+		value, ok := structuredInfo[field]
+		if ok && !containsValue(values, value) {
+			return nil, protocol.NewError(protocol.CodeInfoRejected, "the %s %q isn't allowed", field, value)
+		}
+	}
+
+	if len(p.Injected) == 0 {
+		return info, nil
+	}
+
+	for field, value := range p.Injected {
+		if _, ok := structuredInfo[field]; ok {
+			return nil, protocol.NewError(protocol.CodeInfoRejected, "the %s field is set by the server", field)
+		}
+
+		structuredInfo[field] = []byte(value)
+	}
+
+	injectedInfo, err := structuredInfo.MarshalBinary()
+	if err != nil {
+		return nil, protocol.NewError(protocol.CodeInternal, err.Error())
+	}
+
+	return injectedInfo, nil
+}
+
+func containsValue(values []string, value []byte) bool {
+	for _, allowedValue := range values {
+		if bytes.Equal([]byte(allowedValue), value) {
+			return true
+		}
+	}
+
+	return false
+}
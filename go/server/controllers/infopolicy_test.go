@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+func TestInfoPolicyApply(t *testing.T) {
+	infoPolicy := InfoPolicy{
+		Structured: true,
+		Required:   []protocol.InfoField{protocol.InfoFieldApplication, protocol.InfoFieldPurpose},
+		Allowed:    map[protocol.InfoField][]string{protocol.InfoFieldPurpose: {"statistics", "audit"}},
+		Injected:   map[protocol.InfoField]string{protocol.InfoFieldTenant: "ensimag"},
+	}
+
+	if err := infoPolicy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	encode := func(info protocol.StructuredInfo) []byte {
+		data, err := info.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return data
+	}
+
+	info := encode(protocol.StructuredInfo{
+		protocol.InfoFieldApplication: []byte("survey"),
+		protocol.InfoFieldPurpose:     []byte("audit"),
+	})
+
+	evaluatedInfo, err := infoPolicy.Apply(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tenant is injected
+	structuredInfo, err := protocol.ParseStructuredInfo(evaluatedInfo)
+	if err != nil || !reflect.DeepEqual(structuredInfo, protocol.StructuredInfo{
+		protocol.InfoFieldApplication: []byte("survey"),
+		protocol.InfoFieldPurpose:     []byte("audit"),
+		protocol.InfoFieldTenant:      []byte("ensimag"),
+	}) {
+		t.Errorf("evaluated info %v (%v), expected the injected tenant", structuredInfo, err)
+	}
+
+	for name, rejectedInfo := range map[string][]byte{
+		"free-form info":  []byte("survey audit"),
+		"missing purpose": encode(protocol.StructuredInfo{protocol.InfoFieldApplication: []byte("survey")}),
+		"unlisted purpose": encode(protocol.StructuredInfo{
+			protocol.InfoFieldApplication: []byte("survey"),
+			protocol.InfoFieldPurpose:     []byte("marketing"),
+		}),
+		"client tenant": encode(protocol.StructuredInfo{
+			protocol.InfoFieldApplication: []byte("survey"),
+			protocol.InfoFieldPurpose:     []byte("audit"),
+			protocol.InfoFieldTenant:      []byte("other"),
+		}),
+	} {
+		if _, err := infoPolicy.Apply(rejectedInfo); !errors.Is(err, protocol.ErrInfoRejected) {
+			t.Errorf("%s : %v, expected an info rejected error", name, err)
+		}
+	}
+
+	// the free-form policy accepts any information
+	if evaluatedInfo, err := (InfoPolicy{}).Apply([]byte("survey audit")); err != nil ||
+		string(evaluatedInfo) != "survey audit" {
+		t.Errorf("free-form info evaluated as %q (%v)", evaluatedInfo, err)
+	}
+
+	for _, invalidPolicy := range []InfoPolicy{
+		{Structured: false, Required: []protocol.InfoField{protocol.InfoFieldPurpose}, Allowed: nil, Injected: nil},
+		{
+			Structured: true,
+			Required:   []protocol.InfoField{protocol.InfoFieldTenant},
+			Allowed:    nil,
+			Injected:   infoPolicy.Injected,
+		},
+	} {
+		if err := invalidPolicy.Validate(); !errors.Is(err, ErrInvalidInfoPolicy) {
+			t.Errorf("invalid policy %v accepted : %v", invalidPolicy, err)
+		}
+	}
+}
+
+func TestEvaluateHandlerInfoPolicy(t *testing.T) {
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	controller.SetInfoPolicy(InfoPolicy{
+		Structured: true,
+		Required:   nil,
+		Allowed:    nil,
+		Injected:   map[protocol.InfoField]string{protocol.InfoFieldTenant: "ensimag"},
+	})
+
+	evaluationRequest := newTestEvaluationRequest(t, oprf.SuiteP256, oprf.PartialObliviousMode)
+
+	evaluate := func() (*protocol.EvaluationResponse, error) {
+		body, _ := json.Marshal(evaluationRequest)
+		request := httptest.NewRequest(http.MethodPost, "/api/v2/evaluate", bytes.NewReader(body))
+		request.Header.Set(echo.HeaderContentType, protocol.ContentTypeJSON)
+
+		recorder := httptest.NewRecorder()
+		if err := controller.EvaluateHandler(echo.New().NewContext(request, recorder)); err != nil {
+			return nil, err
+		}
+
+		response := new(protocol.EvaluationResponse)
+
+		return response, json.Unmarshal(recorder.Body.Bytes(), response)
+	}
+
+	if _, err := evaluate(); !errors.Is(err, protocol.ErrInfoRejected) {
+		t.Errorf("free-form info evaluated : %v", err)
+	}
+
+	evaluationRequest.Info, _ = protocol.StructuredInfo{protocol.InfoFieldApplication: []byte("survey")}.MarshalBinary()
+
+	response, err := evaluate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the response has the evaluated information for the finalization
+	structuredInfo, err := protocol.ParseStructuredInfo(response.Info)
+	if err != nil || string(structuredInfo[protocol.InfoFieldTenant]) != "ensimag" {
+		t.Errorf("evaluated info %v (%v), expected the injected tenant", structuredInfo, err)
+	}
+}
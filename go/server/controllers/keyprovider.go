@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+// FileEnvSuffix is appended to the name of a key environment variable to provide the path of a file holding the key
+const FileEnvSuffix = "_FILE"
+
+var ErrMissingKey = errors.New("missing key")
+
+// KeyProvider loads the key sets of the server. A provider only returns the key sets it holds, a missing suite
+// is not an error.
+type KeyProvider interface {
+	LoadKeySets(suites []oprf.Suite) (KeySetMap, error)
+}
+
+// EnvKeyProvider loads the key sets from the *_KEY_SET and *_PRIVATE_KEY environment variables.
+type EnvKeyProvider struct{}
+
+func (p EnvKeyProvider) LoadKeySets(suites []oprf.Suite) (KeySetMap, error) {
+	serializedKeySetMap, err := LoadKeySetsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return loadSerializedKeySets(suites, serializedKeySetMap)
+}
+
+// FileKeyProvider loads the key sets from files, for instance container secrets. The path of a file is read from
+// the *_KEY_SET_FILE and *_PRIVATE_KEY_FILE environment variables, or defaults to the variable name (without suffix)
+// in Dir, e.g. /run/secrets/P256_PRIVATE_KEY.
+type FileKeyProvider struct {
+	Dir string
+}
+
+func (p FileKeyProvider) LoadKeySets(suites []oprf.Suite) (KeySetMap, error) {
+	serializedKeySetMap := make(SerializedKeySetMap)
+
+	for envPrivateKey, suiteID := range GetEnvPrivateKeySuiteMap() {
+		serializedBase64PrivateKey, ok, err := p.readFile(envPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			serializedKeySetMap[suiteID] = []SerializedKey{{PrivateKey: serializedBase64PrivateKey}}
+		}
+	}
+
+	// a key set file takes precedence over the private key file of the same suite
+	for envKeySet, suiteID := range GetEnvKeySetSuiteMap() {
+		serializedKeySet, ok, err := p.readFile(envKeySet)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		var serializedKeys []SerializedKey
+		if err := json.Unmarshal([]byte(serializedKeySet), &serializedKeys); err != nil {
+			return nil, fmt.Errorf("couldn't parse the %s file : %w", envKeySet, err)
+		}
+
+		serializedKeySetMap[suiteID] = serializedKeys
+	}
+
+	return loadSerializedKeySets(suites, serializedKeySetMap)
+}
+
+// readFile returns the trimmed content of the file associated to the environment variable name.
+func (p FileKeyProvider) readFile(name string) (string, bool, error) {
+	path, ok := os.LookupEnv(name + FileEnvSuffix)
+	if !ok {
+		if p.Dir == "" {
+			return "", false, nil
+		}
+
+		path = filepath.Join(p.Dir, name)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return "", false, nil
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("couldn't read the %s file : %w", name, err)
+	}
+
+	return strings.TrimSpace(string(content)), true, nil
+}
+
+// MemoryKeyProvider returns in-memory key sets, for instance in tests.
+type MemoryKeyProvider struct {
+	KeySets KeySetMap
+}
+
+func NewMemoryKeyProvider(keySets ...*KeySet) MemoryKeyProvider {
+	provider := MemoryKeyProvider{KeySets: make(KeySetMap)}
+	for _, keySet := range keySets {
+		provider.KeySets[keySet.Suite().Identifier()] = keySet
+	}
+
+	return provider
+}
+
+func (p MemoryKeyProvider) LoadKeySets(suites []oprf.Suite) (KeySetMap, error) {
+	keySets := make(KeySetMap)
+
+	for _, suite := range suites {
+		if keySet, ok := p.KeySets[suite.Identifier()]; ok {
+			keySets[suite.Identifier()] = keySet
+		}
+	}
+
+	return keySets, nil
+}
+
+// ChainKeyProvider loads the key set of each suite from the first provider holding it.
+type ChainKeyProvider []KeyProvider
+
+func (p ChainKeyProvider) LoadKeySets(suites []oprf.Suite) (KeySetMap, error) {
+	keySets := make(KeySetMap)
+
+	for _, provider := range p {
+		providerKeySets, err := provider.LoadKeySets(suites)
+		if err != nil {
+			return nil, err
+		}
+
+		for suiteID, keySet := range providerKeySets {
+			if _, ok := keySets[suiteID]; !ok {
+				keySets[suiteID] = keySet
+			}
+		}
+	}
+
+	return keySets, nil
+}
+
+// StrictKeyProvider fails if the wrapped provider doesn't hold a key set for every suite,
+// instead of letting the server generate the missing keys.
+type StrictKeyProvider struct {
+	KeyProvider
+}
+
+func (p StrictKeyProvider) LoadKeySets(suites []oprf.Suite) (KeySetMap, error) {
+	keySets, err := p.KeyProvider.LoadKeySets(suites)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, suite := range suites {
+		if _, ok := keySets[suite.Identifier()]; !ok {
+			return nil, fmt.Errorf("%w for the suite %s", ErrMissingKey, suite.Identifier())
+		}
+	}
+
+	return keySets, nil
+}
+
+// loadSerializedKeySets deserializes the key sets of the provided suites.
+func loadSerializedKeySets(suites []oprf.Suite, serializedKeySetMap SerializedKeySetMap) (KeySetMap, error) {
+	keySets := make(KeySetMap)
+
+	for _, suite := range suites {
+		serializedKeys, ok := serializedKeySetMap[suite.Identifier()]
+		if !ok || len(serializedKeys) == 0 {
+			continue
+		}
+
+		keySet, err := LoadKeySet(suite, serializedKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		keySets[suite.Identifier()] = keySet
+	}
+
+	return keySets, nil
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+func TestFileKeyProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	// the P256 key is a container secret in the key directory
+	if err := os.WriteFile(filepath.Join(dir, EnvP256PrivateKey),
+		[]byte("AtzyGS8NoBjEjqbhwdGY/zWyqdFkJghyTttoIGq4UoM=\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the P384 key set file is provided by its environment variable
+	keySetPath := filepath.Join(t.TempDir(), "p384.json")
+	if err := os.WriteFile(keySetPath, []byte(`[{"kid": "v1", "private_key": `+
+		`"DryE+vL9Q8ciTNy6TvC5c7iXgOmzwkhqHpzAuPAXBRL8uNczSINCqt3crXNjncIW"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(EnvP384KeySet+FileEnvSuffix, keySetPath)
+
+	keySets, err := FileKeyProvider{Dir: dir}.LoadKeySets(SupportedSuites())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keySets[oprf.SuiteP256.Identifier()] == nil {
+		t.Errorf("the P256 key should be loaded from the key directory")
+	}
+
+	if keySets[oprf.SuiteP384.Identifier()].Active().ID != "v1" {
+		t.Errorf("the P384 key set should be loaded from its file")
+	}
+
+	if _, ok := keySets[oprf.SuiteP521.Identifier()]; ok {
+		t.Errorf("there is no P521 key file")
+	}
+}
+
+func TestStrictKeyProvider(t *testing.T) {
+	keySet, err := GenerateKeySet(oprf.SuiteP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyProvider := StrictKeyProvider{NewMemoryKeyProvider(keySet)}
+
+	if _, err := keyProvider.LoadKeySets([]oprf.Suite{oprf.SuiteP256}); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := keyProvider.LoadKeySets(SupportedSuites()); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("a missing suite should be rejected : %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+// KeyState is the lifecycle state of a versioned key
+type KeyState string
+
+const (
+	// KeyStateActive is the state of the key used when the client doesn't request a key ID.
+	KeyStateActive KeyState = "active"
+	// KeyStateVerifyOnly is the state of a published key only used when its key ID is requested.
+	KeyStateVerifyOnly KeyState = "verify-only"
+	// KeyStateRetired is the state of a key that is neither published nor used.
+	KeyStateRetired KeyState = "retired"
+	// KeyStatePending is the state of a published key that will be promoted to active, see KeySet.Rotate.
+	KeyStatePending KeyState = "pending"
+)
+
+// keyIDLength is the number of bytes of the public key hash used as the default key ID
+const keyIDLength = 8
+
+var (
+	ErrUnknownKeyID    = errors.New("unknown key ID")
+	ErrRetiredKey      = errors.New("the key is retired")
+	ErrNoActiveKey     = errors.New("no active key")
+	ErrDuplicateKeyID  = errors.New("duplicate key ID")
+	ErrMultipleActive  = errors.New("more than one active key")
+	ErrInvalidKeyState = errors.New("invalid key state")
+	ErrInvalidKeyID    = errors.New("invalid key ID")
+	ErrPendingKey      = errors.New("the key is not active yet")
+	ErrExpiredKey      = errors.New("the active key expired")
+	// ErrUnenforceableMaxEvaluations is a maximum number of evaluations that the usage counters can't enforce
+	ErrUnenforceableMaxEvaluations = errors.New("max_evaluations can't be enforced by a per-request server")
+)
+
+// KeyValidity is the rotation schedule of a key. The key can be active from NotBefore until NotAfter or until
+// it evaluated MaxEvaluations elements. The zero value is unbounded.
+type KeyValidity struct {
+	NotBefore      *time.Time `json:"not_before,omitempty"`
+	NotAfter       *time.Time `json:"not_after,omitempty"`
+	MaxEvaluations uint64     `json:"max_evaluations,omitempty"`
+}
+
+// Key is a versioned private key. The private key of a key share is a Shamir share of the key.
+type Key struct {
+	ID         string
+	CreatedAt  time.Time
+	State      KeyState
+	PrivateKey *oprf.PrivateKey
+	Share      *KeyShareInfo
+	KeyValidity
+	// usage is shared by the copies of the key, it is set when the key is added to a key set
+	usage *keyUsage
+}
+
+// keyUsage counts the evaluations of a key in memory
+type keyUsage struct {
+	evaluations uint64
+	// expiryLogged is set once the expiry of the active key without successor has been logged
+	expiryLogged uint32
+}
+
+// Evaluations returns the number of elements evaluated with the key since the server started.
+func (k *Key) Evaluations() uint64 {
+	if k.usage == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&k.usage.evaluations)
+}
+
+// RecordEvaluations adds count evaluated elements to the usage counter of the key.
+// It returns true if the key reached its maximum number of evaluations.
+func (k *Key) RecordEvaluations(count uint64) bool {
+	if k.usage == nil {
+		return false
+	}
+
+	evaluations := atomic.AddUint64(&k.usage.evaluations, count)
+
+	return k.MaxEvaluations > 0 && evaluations >= k.MaxEvaluations && evaluations-count < k.MaxEvaluations
+}
+
+// Expired returns true if the validity window of the key ended or if it reached its maximum number of evaluations.
+func (k *Key) Expired(now time.Time) bool {
+	if k.NotAfter != nil && !now.Before(*k.NotAfter) {
+		return true
+	}
+
+	return k.MaxEvaluations > 0 && k.Evaluations() >= k.MaxEvaluations
+}
+
+// NewKey returns an active key created now. The key ID is derived from the public key.
+func NewKey(suite oprf.Suite, privateKey *oprf.PrivateKey) *Key {
+	return &Key{
+		ID:         NewKeyID(suite, privateKey.Public()),
+		CreatedAt:  time.Now().UTC(),
+		State:      KeyStateActive,
+		PrivateKey: privateKey,
+	}
+}
+
+// NewKeyID returns the truncated fingerprint of the public key.
+func NewKeyID(suite oprf.Suite, publicKey *oprf.PublicKey) string {
+	return Fingerprint(suite, publicKey)[:2*keyIDLength]
+}
+
+// Fingerprint returns the hex encoded SHA-256 hash of the suite identifier and the serialized public key.
+func Fingerprint(suite oprf.Suite, publicKey *oprf.PublicKey) string {
+	serializedPublicKey, _ := publicKey.MarshalBinary()
+
+	hash := sha256.New()
+	hash.Write([]byte(suite.Identifier()))
+	hash.Write(serializedPublicKey)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ValidateKeyState checks that the state is one of the known key states.
+func ValidateKeyState(state KeyState) error {
+	switch state {
+	case KeyStateActive, KeyStateVerifyOnly, KeyStateRetired, KeyStatePending:
+		return nil
+	}
+
+	return fmt.Errorf("%w : %q", ErrInvalidKeyState, state)
+}
+
+// ValidateKeyID checks that the key ID fits in the entries of the transparency log.
+func ValidateKeyID(kid string) error {
+	if len(kid) > protocol.MaxKeyIDLength {
+		return fmt.Errorf("%w : %d bytes, at most %d", ErrInvalidKeyID, len(kid), protocol.MaxKeyIDLength)
+	}
+
+	return nil
+}
+
+// KeySet holds the versioned keys of a cipher suite. At most one key is active.
+type KeySet struct {
+	suite oprf.Suite
+	// keys in insertion order
+	keys []*Key
+}
+
+func NewKeySet(suite oprf.Suite) *KeySet {
+	return &KeySet{suite: suite} //nolint:exhaustivestruct
+}
+
+func (k *KeySet) Suite() oprf.Suite {
+	return k.suite
+}
+
+// Add inserts a key in the set. The key ID must be unique and only one key can be active.
+func (k *KeySet) Add(key *Key) error {
+	if err := ValidateKeyState(key.State); err != nil {
+		return err
+	}
+
+	if err := ValidateKeyID(key.ID); err != nil {
+		return err
+	}
+
+	if _, ok := k.Get(key.ID); ok {
+		return fmt.Errorf("%w : %s", ErrDuplicateKeyID, key.ID)
+	}
+
+	if key.State == KeyStateActive && k.Active() != nil {
+		return fmt.Errorf("%w for the suite %s", ErrMultipleActive, k.suite.Identifier())
+	}
+
+	if key.usage == nil {
+		key.usage = new(keyUsage)
+	}
+
+	k.keys = append(k.keys, key)
+
+	return nil
+}
+
+// Get returns the key with the provided key ID, whatever its state.
+func (k *KeySet) Get(kid string) (*Key, bool) {
+	for _, key := range k.keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+// Active returns the active key or nil if there is none.
+func (k *KeySet) Active() *Key {
+	for _, key := range k.keys {
+		if key.State == KeyStateActive {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// Keys returns all the keys of the set.
+func (k *KeySet) Keys() []*Key {
+	return k.keys
+}
+
+// Published returns the keys that are not retired.
+func (k *KeySet) Published() []*Key {
+	keys := make([]*Key, 0, len(k.keys))
+
+	for _, key := range k.keys {
+		if key.State != KeyStateRetired {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// EvaluationKey returns the key used to evaluate a request. An empty key ID selects the active key,
+// otherwise the requested key must be active or verify-only.
+func (k *KeySet) EvaluationKey(kid string) (*Key, error) {
+	if kid == "" {
+		key := k.Active()
+		if key == nil {
+			return nil, fmt.Errorf("%w for the suite %s", ErrNoActiveKey, k.suite.Identifier())
+		}
+
+		return key, nil
+	}
+
+	key, ok := k.Get(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w : %s", ErrUnknownKeyID, kid)
+	}
+
+	if key.State == KeyStateRetired {
+		return nil, fmt.Errorf("%w : %s", ErrRetiredKey, kid)
+	}
+
+	if key.State == KeyStatePending {
+		return nil, fmt.Errorf("%w : %s", ErrPendingKey, kid)
+	}
+
+	return key, nil
+}
+
+// Rotate returns the key set at the time now. A pending key is promoted once its validity window starts or,
+// without a window, once the active key expired. The previous active key becomes verify-only until the end
+// of the grace period, then it is retired. A zero grace period never retires the verify-only keys.
+// It returns false if no key changed.
+func (k *KeySet) Rotate(now time.Time, gracePeriod time.Duration) (*KeySet, bool) {
+	rotated := &KeySet{suite: k.suite, keys: make([]*Key, len(k.keys))}
+
+	for index, key := range k.keys {
+		copiedKey := *key
+		rotated.keys[index] = &copiedKey
+	}
+
+	changed := false
+
+	// several windows may have started since the last rotation
+	for rotated.promote(now) {
+		changed = true
+	}
+
+	for _, key := range rotated.keys {
+		if gracePeriod > 0 && key.State == KeyStateVerifyOnly && key.NotAfter != nil &&
+			!now.Before(key.NotAfter.Add(gracePeriod)) {
+			key.State = KeyStateRetired
+			changed = true
+		}
+	}
+
+	if !changed {
+		return k, false
+	}
+
+	return rotated, true
+}
+
+// promote replaces the active key with the next pending key if it is due.
+func (k *KeySet) promote(now time.Time) bool {
+	active := k.Active()
+	activeExpired := active == nil || active.Expired(now)
+
+	var next *Key
+
+	for _, key := range k.keys {
+		if key.State != KeyStatePending || (key.NotBefore != nil && now.Before(*key.NotBefore)) {
+			continue
+		}
+
+		// a pending key without validity window waits for the expiry of the active key
+		if key.NotBefore == nil && !activeExpired {
+			continue
+		}
+
+		if next == nil || (key.NotBefore != nil && (next.NotBefore == nil || key.NotBefore.Before(*next.NotBefore))) {
+			next = key
+		}
+	}
+
+	if next == nil {
+		return false
+	}
+
+	if active != nil {
+		active.State = KeyStateVerifyOnly
+
+		if active.NotAfter == nil || now.Before(*active.NotAfter) {
+			end := now
+			active.NotAfter = &end
+		}
+	}
+
+	next.State = KeyStateActive
+
+	return true
+}
+
+// inheritUsage shares the usage counters of the keys of the previous key set with the same key ID and private key.
+func (k *KeySet) inheritUsage(previous *KeySet) {
+	if previous == nil {
+		return
+	}
+
+	for _, key := range k.keys {
+		previousKey, ok := previous.Get(key.ID)
+		if ok && bytes.Equal(SerializePublicKey(previousKey.PrivateKey), SerializePublicKey(key.PrivateKey)) {
+			key.usage = previousKey.usage
+		}
+	}
+}
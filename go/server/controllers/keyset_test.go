@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+func TestKeySetEvaluationKey(t *testing.T) {
+	serializedKeys := []SerializedKey{
+		{ID: "old", State: KeyStateVerifyOnly, PrivateKey: "AtzyGS8NoBjEjqbhwdGY/zWyqdFkJghyTttoIGq4UoM="},
+		{ID: "new", PrivateKey: "nQy3QFqrK4iLsHgV1pjBC8yStfMqWXLkVMX7BvVkD5w="},
+		{ID: "gone", State: KeyStateRetired, PrivateKey: "nLhFIkmIYdXCN8crpHHNJGmbxzvDLg4jtCxoJKo4wMw="},
+	}
+
+	keySet, err := LoadKeySet(oprf.SuiteP256, serializedKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key, err := keySet.EvaluationKey(""); err != nil || key.ID != "new" {
+		t.Errorf("the active key should be used by default : %v", err)
+	}
+
+	if key, err := keySet.EvaluationKey("old"); err != nil || key.ID != "old" {
+		t.Errorf("a verify-only key should be used when requested : %v", err)
+	}
+
+	if _, err := keySet.EvaluationKey("gone"); !errors.Is(err, ErrRetiredKey) {
+		t.Errorf("a retired key should not be used : %v", err)
+	}
+
+	if _, err := keySet.EvaluationKey("unknown"); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("an unknown key ID should be rejected : %v", err)
+	}
+
+	if len(keySet.Published()) != 2 {
+		t.Errorf("the retired key should not be published")
+	}
+}
+
+func TestLoadKeySetDefaults(t *testing.T) {
+	serializedKeys := []SerializedKey{{PrivateKey: "AtzyGS8NoBjEjqbhwdGY/zWyqdFkJghyTttoIGq4UoM="}}
+
+	keySet, err := LoadKeySet(oprf.SuiteP256, serializedKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := keySet.Active()
+	if key == nil || key.ID != NewKeyID(oprf.SuiteP256, key.PrivateKey.Public()) {
+		t.Errorf("a legacy key should be active with a key ID derived from its public key")
+	}
+
+	// two active keys are ambiguous
+	serializedKeys = append(
+		serializedKeys,
+		SerializedKey{ID: "other", State: KeyStateActive, PrivateKey: "nQy3QFqrK4iLsHgV1pjBC8yStfMqWXLkVMX7BvVkD5w="},
+	)
+
+	if _, err := LoadKeySet(oprf.SuiteP256, serializedKeys); !errors.Is(err, ErrMultipleActive) {
+		t.Errorf("two active keys should be rejected : %v", err)
+	}
+
+	// the key ID must fit in the entries of the transparency log
+	longKey := SerializedKey{ID: strings.Repeat("k", 300), PrivateKey: "AtzyGS8NoBjEjqbhwdGY/zWyqdFkJghyTttoIGq4UoM="}
+	if _, err := LoadKey(oprf.SuiteP256, longKey); !errors.Is(err, ErrInvalidKeyID) {
+		t.Errorf("a key ID of 300 bytes should be rejected : %v", err)
+	}
+
+	if err := NewKeySet(oprf.SuiteP256).Add(&Key{ID: longKey.ID, State: KeyStateActive}); !errors.Is(err,
+		ErrInvalidKeyID) {
+		t.Errorf("a key ID of 300 bytes should not be added : %v", err)
+	}
+}
+
+func TestKeySetRotate(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	february := start.AddDate(0, 1, 0)
+	march := start.AddDate(0, 2, 0)
+	gracePeriod := 7 * 24 * time.Hour
+
+	serializedKeys := []SerializedKey{
+		{
+			ID: "january", PrivateKey: "AtzyGS8NoBjEjqbhwdGY/zWyqdFkJghyTttoIGq4UoM=",
+			KeyValidity: KeyValidity{NotBefore: &start, NotAfter: &february}, //nolint:exhaustivestruct
+		},
+		{
+			ID: "february", State: KeyStatePending, PrivateKey: "nQy3QFqrK4iLsHgV1pjBC8yStfMqWXLkVMX7BvVkD5w=",
+			KeyValidity: KeyValidity{NotBefore: &february, NotAfter: &march, MaxEvaluations: 10},
+		},
+		{ID: "next", State: KeyStatePending, PrivateKey: "nLhFIkmIYdXCN8crpHHNJGmbxzvDLg4jtCxoJKo4wMw="},
+	}
+
+	keySet, err := LoadKeySet(oprf.SuiteP256, serializedKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keySet.EvaluationKey("february"); !errors.Is(err, ErrPendingKey) {
+		t.Errorf("a pending key should not be used : %v", err)
+	}
+
+	if _, changed := keySet.Rotate(start.AddDate(0, 0, 15), gracePeriod); changed {
+		t.Errorf("no key is due in the middle of January")
+	}
+
+	keySet, changed := keySet.Rotate(february, gracePeriod)
+	if !changed || keySet.Active().ID != "february" {
+		t.Fatalf("the February key should be promoted")
+	}
+
+	if key, _ := keySet.Get("january"); key.State != KeyStateVerifyOnly {
+		t.Errorf("the previous key should be verify-only during the grace period, not %s", key.State)
+	}
+
+	// the key without validity window is promoted once the active key evaluated its maximum of elements
+	if keySet.Active().RecordEvaluations(9) {
+		t.Errorf("the February key has not reached its maximum yet")
+	}
+
+	if !keySet.Active().RecordEvaluations(1) {
+		t.Errorf("the February key has reached its maximum")
+	}
+
+	keySet, _ = keySet.Rotate(february.Add(gracePeriod), gracePeriod)
+	if keySet.Active().ID != "next" {
+		t.Errorf("the next key should be promoted after the maximum of evaluations")
+	}
+
+	if key, _ := keySet.Get("january"); key.State != KeyStateRetired {
+		t.Errorf("the January key should be retired after the grace period, not %s", key.State)
+	}
+
+	if key, _ := keySet.Get("february"); key.State != KeyStateVerifyOnly {
+		t.Errorf("the February key should be verify-only, not %s", key.State)
+	}
+}
@@ -0,0 +1,379 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/oprf"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+const (
+	KeystoreVersion = 1
+	KeystoreKDF     = "scrypt"
+	KeystoreCipher  = "aes-256-gcm"
+
+	// scrypt parameters recommended for interactive logins in 2017, see the scrypt package documentation
+	keystoreScryptN       = 1 << 15
+	keystoreScryptR       = 8
+	keystoreScryptP       = 1
+	keystoreMaxScryptCost = 1 << 20
+	// keystoreMaxScryptWork bounds N*r*p, the memory is 128*N*r bytes : 1 GiB with the maximum cost and r = 8
+	keystoreMaxScryptWork = keystoreMaxScryptCost * keystoreScryptR
+	keystoreSaltLength    = 32
+	keystoreKeyLength     = 32
+)
+
+var (
+	ErrUnsupportedKeystore = errors.New("unsupported keystore")
+	ErrKeystoreIntegrity   = errors.New("wrong passphrase or corrupted keystore")
+	ErrEmptyPassphrase     = errors.New("empty passphrase")
+)
+
+// KeystoreKDFParams are the parameters of the passphrase key derivation
+type KeystoreKDFParams struct {
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// KeystoreKeyMetadata is the clear metadata of a keystore key
+type KeystoreKeyMetadata struct {
+	Suite     string    `json:"suite"`
+	ID        string    `json:"kid"`
+	CreatedAt time.Time `json:"created_at"`
+	State     KeyState  `json:"state"`
+	// Share is set if the private key is a share of the key
+	Share *KeyShareInfo `json:"share,omitempty"`
+	KeyValidity
+}
+
+// KeystoreHeader is the clear part of a keystore. It is authenticated as additional data of the encryption
+// so the metadata can't be modified without the passphrase.
+type KeystoreHeader struct {
+	Version int                   `json:"version"`
+	KDF     KeystoreKDFParams     `json:"kdf"`
+	Cipher  string                `json:"cipher"`
+	Keys    []KeystoreKeyMetadata `json:"keys"`
+}
+
+// Keystore is the on-disk format of the private keys. The ciphertext is the encryption of the JSON array of
+// the serialized private keys, in the order of the header keys.
+type Keystore struct {
+	Header     json.RawMessage `json:"header"`
+	Nonce      []byte          `json:"nonce"`
+	Ciphertext []byte          `json:"ciphertext"`
+}
+
+// EncryptKeystore encrypts the key sets under a key derived from the passphrase and returns the JSON keystore.
+func EncryptKeystore(keySets KeySetMap, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrEmptyPassphrase
+	}
+
+	header := KeystoreHeader{
+		Version: KeystoreVersion,
+		KDF: KeystoreKDFParams{
+			Name: KeystoreKDF,
+			Salt: make([]byte, keystoreSaltLength),
+			N:    keystoreScryptN,
+			R:    keystoreScryptR,
+			P:    keystoreScryptP,
+		},
+		Cipher: KeystoreCipher,
+	}
+
+	if _, err := rand.Read(header.KDF.Salt); err != nil {
+		return nil, err
+	}
+
+	var serializedPrivateKeys [][]byte
+
+	for _, suite := range SupportedSuites() {
+		keySet, ok := keySets[suite.Identifier()]
+		if !ok {
+			continue
+		}
+
+		for _, key := range keySet.Keys() {
+			serializedPrivateKey, err := key.PrivateKey.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+
+			serializedPrivateKeys = append(serializedPrivateKeys, serializedPrivateKey)
+			header.Keys = append(header.Keys, KeystoreKeyMetadata{
+				Suite:       suite.Identifier(),
+				ID:          key.ID,
+				CreatedAt:   key.CreatedAt,
+				State:       key.State,
+				Share:       key.Share,
+				KeyValidity: key.KeyValidity,
+			})
+		}
+	}
+
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(serializedPrivateKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newKeystoreAEAD(header.KDF, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	keystore := Keystore{
+		Header: rawHeader,
+		Nonce:  make([]byte, aead.NonceSize()),
+	}
+
+	if _, err := rand.Read(keystore.Nonce); err != nil {
+		return nil, err
+	}
+
+	keystore.Ciphertext = aead.Seal(nil, keystore.Nonce, plaintext, rawHeader)
+
+	return json.MarshalIndent(keystore, "", "  ")
+}
+
+// DecryptKeystore checks the integrity of the JSON keystore and decrypts its key sets.
+func DecryptKeystore(data []byte, passphrase []byte) (KeySetMap, error) {
+	var keystore Keystore
+	if err := json.Unmarshal(data, &keystore); err != nil {
+		return nil, fmt.Errorf("couldn't parse the keystore : %w", err)
+	}
+
+	// the header is authenticated independently of its formatting
+	var rawHeader bytes.Buffer
+	if err := json.Compact(&rawHeader, keystore.Header); err != nil {
+		return nil, fmt.Errorf("couldn't parse the keystore header : %w", err)
+	}
+
+	var header KeystoreHeader
+	if err := json.Unmarshal(rawHeader.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("couldn't parse the keystore header : %w", err)
+	}
+
+	if header.Version != KeystoreVersion || header.KDF.Name != KeystoreKDF || header.Cipher != KeystoreCipher {
+		return nil, fmt.Errorf("%w : version %d, %s, %s", ErrUnsupportedKeystore,
+			header.Version, header.KDF.Name, header.Cipher)
+	}
+
+	kdf := header.KDF
+	if kdf.N > keystoreMaxScryptCost || kdf.R <= 0 || kdf.P <= 0 || kdf.R > keystoreMaxScryptWork/kdf.P ||
+		kdf.N > keystoreMaxScryptWork/(kdf.R*kdf.P) {
+		return nil, fmt.Errorf("%w : scrypt parameters N=%d r=%d p=%d", ErrUnsupportedKeystore, kdf.N, kdf.R, kdf.P)
+	}
+
+	aead, err := newKeystoreAEAD(header.KDF, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keystore.Nonce) != aead.NonceSize() {
+		return nil, ErrKeystoreIntegrity
+	}
+
+	plaintext, err := aead.Open(nil, keystore.Nonce, keystore.Ciphertext, rawHeader.Bytes())
+	if err != nil {
+		return nil, ErrKeystoreIntegrity
+	}
+
+	var serializedPrivateKeys [][]byte
+	if err := json.Unmarshal(plaintext, &serializedPrivateKeys); err != nil {
+		return nil, fmt.Errorf("couldn't parse the keystore keys : %w", err)
+	}
+
+	if len(serializedPrivateKeys) != len(header.Keys) {
+		return nil, ErrKeystoreIntegrity
+	}
+
+	return loadKeystoreKeySets(header.Keys, serializedPrivateKeys)
+}
+
+func loadKeystoreKeySets(metadata []KeystoreKeyMetadata, serializedPrivateKeys [][]byte) (KeySetMap, error) {
+	keySets := make(KeySetMap)
+
+	for index, keyMetadata := range metadata {
+		suite, err := oprf.GetSuite(keyMetadata.Suite)
+		if err != nil {
+			return nil, err
+		}
+
+		privateKey := new(oprf.PrivateKey)
+		if err := privateKey.UnmarshalBinary(suite, serializedPrivateKeys[index]); err != nil {
+			return nil, fmt.Errorf("couldn't load the private key %s : %w", keyMetadata.ID, err)
+		}
+
+		keySet, ok := keySets[keyMetadata.Suite]
+		if !ok {
+			keySet = NewKeySet(suite)
+			keySets[keyMetadata.Suite] = keySet
+		}
+
+		if err := keySet.Add(&Key{
+			ID:          keyMetadata.ID,
+			CreatedAt:   keyMetadata.CreatedAt,
+			State:       keyMetadata.State,
+			PrivateKey:  privateKey,
+			Share:       keyMetadata.Share,
+			KeyValidity: keyMetadata.KeyValidity,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for suiteID, keySet := range keySets {
+		if keySet.Active() == nil {
+			return nil, fmt.Errorf("%w for the suite %s", ErrNoActiveKey, suiteID)
+		}
+	}
+
+	return keySets, nil
+}
+
+func newKeystoreAEAD(params KeystoreKDFParams, passphrase []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, params.Salt, params.N, params.R, params.P, keystoreKeyLength)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't derive the keystore key : %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// PassphraseSource returns the passphrase of a keystore
+type PassphraseSource func() ([]byte, error)
+
+// PassphraseFromEnv reads the passphrase from an environment variable.
+func PassphraseFromEnv(name string) PassphraseSource {
+	return func() ([]byte, error) {
+		passphrase, ok := os.LookupEnv(name)
+		if !ok || passphrase == "" {
+			return nil, fmt.Errorf("%w : %s is not set", ErrEmptyPassphrase, name)
+		}
+
+		return []byte(passphrase), nil
+	}
+}
+
+// PassphraseFromFile reads the passphrase from a file. The trailing new line is removed.
+func PassphraseFromFile(path string) PassphraseSource {
+	return func() ([]byte, error) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read the passphrase file : %w", err)
+		}
+
+		passphrase := strings.TrimRight(string(content), "\r\n")
+		if passphrase == "" {
+			return nil, fmt.Errorf("%w : %s is empty", ErrEmptyPassphrase, path)
+		}
+
+		return []byte(passphrase), nil
+	}
+}
+
+// PassphraseFromPrompt reads the passphrase from the terminal without echo.
+func PassphraseFromPrompt(prompt string) PassphraseSource {
+	return func() ([]byte, error) {
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			return nil, fmt.Errorf("%w : the standard input is not a terminal", ErrEmptyPassphrase)
+		}
+
+		fmt.Fprint(os.Stderr, prompt)
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read the passphrase : %w", err)
+		}
+
+		if len(passphrase) == 0 {
+			return nil, ErrEmptyPassphrase
+		}
+
+		return passphrase, nil
+	}
+}
+
+// CachedPassphrase reads the passphrase of the source once and returns it again on the next calls : the keystore
+// is reloaded without prompting, the reloads don't wait on the terminal.
+func CachedPassphrase(source PassphraseSource) PassphraseSource {
+	var (
+		passphrase []byte
+		mu         sync.Mutex
+	)
+
+	return func() ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if passphrase == nil {
+			sourcePassphrase, err := source()
+			if err != nil {
+				return nil, err
+			}
+
+			passphrase = sourcePassphrase
+		}
+
+		return passphrase, nil
+	}
+}
+
+// KeystoreKeyProvider unlocks the key sets of a keystore file.
+type KeystoreKeyProvider struct {
+	Path       string
+	Passphrase PassphraseSource
+}
+
+func (p KeystoreKeyProvider) LoadKeySets(suites []oprf.Suite) (KeySetMap, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the keystore : %w", err)
+	}
+
+	passphrase, err := p.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	keystoreKeySets, err := DecryptKeystore(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	keySets := make(KeySetMap)
+
+	for _, suite := range suites {
+		if keySet, ok := keystoreKeySets[suite.Identifier()]; ok {
+			keySets[suite.Identifier()] = keySet
+		}
+	}
+
+	return keySets, nil
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+func TestKeystore(t *testing.T) {
+	keySet, err := LoadKeySet(oprf.SuiteP256, []SerializedKey{
+		{ID: "old", State: KeyStateVerifyOnly, PrivateKey: "AtzyGS8NoBjEjqbhwdGY/zWyqdFkJghyTttoIGq4UoM="},
+		{ID: "new", PrivateKey: "nQy3QFqrK4iLsHgV1pjBC8yStfMqWXLkVMX7BvVkD5w="},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passphrase := []byte("correct horse battery staple")
+
+	data, err := EncryptKeystore(NewMemoryKeyProvider(keySet).KeySets, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keySets, err := DecryptKeystore(data, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range keySet.Keys() {
+		decryptedKey, ok := keySets[oprf.SuiteP256.Identifier()].Get(key.ID)
+		if !ok || decryptedKey.State != key.State || !bytes.Equal(SerializePublicKey(decryptedKey.PrivateKey),
+			SerializePublicKey(key.PrivateKey)) {
+			t.Errorf("the key %s does not match", key.ID)
+		}
+	}
+
+	if _, err := DecryptKeystore(data, []byte("wrong passphrase")); !errors.Is(err, ErrKeystoreIntegrity) {
+		t.Errorf("a wrong passphrase should be rejected : %v", err)
+	}
+
+	// activate the old key without the passphrase
+	var keystore Keystore
+	if err := json.Unmarshal(data, &keystore); err != nil {
+		t.Fatal(err)
+	}
+
+	keystore.Header = bytes.Replace(keystore.Header, []byte(`"verify-only"`), []byte(`"active"`), 1)
+	keystore.Header = bytes.Replace(keystore.Header, []byte(`"kid":"new","created_at":"0001-01-01T00:00:00Z",`+
+		`"state":"active"`), []byte(`"kid":"new","created_at":"0001-01-01T00:00:00Z","state":"retired"`), 1)
+
+	tampered, err := json.Marshal(keystore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecryptKeystore(tampered, passphrase); !errors.Is(err, ErrKeystoreIntegrity) {
+		t.Errorf("a modified header should be rejected : %v", err)
+	}
+
+	// the scrypt parameters of an untrusted header are bounded before deriving the key
+	for _, kdf := range []string{`"r":8,"p":1048576`, `"r":1048576,"p":1`, `"r":-8,"p":1`} {
+		if err := json.Unmarshal(data, &keystore); err != nil {
+			t.Fatal(err)
+		}
+
+		var header bytes.Buffer
+		if err := json.Compact(&header, keystore.Header); err != nil {
+			t.Fatal(err)
+		}
+
+		keystore.Header = bytes.Replace(header.Bytes(), []byte(`"r":8,"p":1`), []byte(kdf), 1)
+
+		crafted, err := json.Marshal(keystore)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := DecryptKeystore(crafted, passphrase); !errors.Is(err, ErrUnsupportedKeystore) {
+			t.Errorf("the scrypt parameters %s should be rejected : %v", kdf, err)
+		}
+	}
+}
+
+func TestCachedPassphrase(t *testing.T) {
+	prompts := 0
+	passphrase := CachedPassphrase(func() ([]byte, error) {
+		prompts++
+		if prompts == 1 {
+			return nil, ErrEmptyPassphrase
+		}
+
+		return []byte("correct horse"), nil
+	})
+
+	// a failed read isn't cached, the passphrase is only read once after
+	if _, err := passphrase(); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if value, err := passphrase(); err != nil || string(value) != "correct horse" {
+			t.Errorf("unexpected passphrase %q %v", value, err)
+		}
+	}
+
+	if prompts != 2 {
+		t.Errorf("the passphrase is read %d times", prompts)
+	}
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"io"
+	"math"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+// Default limits of the evaluation requests
+const (
+	DefaultMaxBodySize   = 1 << 20
+	DefaultMaxBatchSize  = 1024
+	DefaultMaxInfoLength = 1024
+)
+
+// Limits bound the evaluation requests. They are checked before any group operation.
+type Limits struct {
+	// MaxBodySize is the maximum size of a request body in bytes
+	MaxBodySize int64
+	// MaxBatchSize is the maximum number of blinded elements of a request
+	MaxBatchSize int
+	// MaxInfoLength is the maximum length of the public information in bytes
+	MaxInfoLength int
+}
+
+// DefaultLimits returns the default limits of the evaluation requests.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxBodySize:   DefaultMaxBodySize,
+		MaxBatchSize:  DefaultMaxBatchSize,
+		MaxInfoLength: DefaultMaxInfoLength,
+	}
+}
+
+// ReadBody reads the request body, it returns a request_too_large error if the body is larger than MaxBodySize.
+func (l Limits) ReadBody(c echo.Context) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, l.MaxBodySize+1))
+	if err != nil {
+		return nil, protocol.NewError(protocol.CodeInvalidRequest, err.Error())
+	}
+
+	if int64(len(body)) > l.MaxBodySize {
+		return nil, protocol.NewError(protocol.CodeRequestTooLarge, "the request body is larger than %d bytes",
+			l.MaxBodySize)
+	}
+
+	return body, nil
+}
+
+// Validate checks the batch and the public information of the request in the mode. The public information is only
+// used in the partially oblivious mode, it is refused in the other modes rather than silently ignored. A grouped
+// batch has a public information per element.
+func (l Limits) Validate(mode oprf.Mode, evaluationRequest *protocol.EvaluationRequest) error {
+	switch count := len(evaluationRequest.BlindedElements); {
+	case count == 0:
+		return protocol.NewError(protocol.CodeEmptyBatch, "no blinded element")
+	case count > l.MaxBatchSize:
+		return protocol.NewError(protocol.CodeBatchTooLarge, "%d blinded elements, the limit is %d", count,
+			l.MaxBatchSize)
+	}
+
+	if mode != oprf.PartialObliviousMode && (len(evaluationRequest.Info) > 0 || len(evaluationRequest.Infos) > 0) {
+		return protocol.NewError(protocol.CodeInfoNotAllowed, "the %s mode has no public information, use the %s mode",
+			evaluationRequest.Mode, protocol.ModePartialOblivious)
+	}
+
+	if len(evaluationRequest.Infos) > 0 {
+		return l.validateInfos(evaluationRequest)
+	}
+
+	return l.validateInfo(evaluationRequest.Info)
+}
+
+// validateInfos checks the public information of each element, exclusive with the public information of the batch.
+func (l Limits) validateInfos(evaluationRequest *protocol.EvaluationRequest) error {
+	if len(evaluationRequest.Info) > 0 {
+		return protocol.NewError(protocol.CodeInvalidRequest, "the info and the infos of the elements are exclusive")
+	}
+
+	if count := len(evaluationRequest.BlindedElements); len(evaluationRequest.Infos) != count {
+		return protocol.NewError(protocol.CodeInvalidRequest, "%d infos for %d blinded elements",
+			len(evaluationRequest.Infos), count)
+	} else if count > math.MaxUint16 {
+		return protocol.NewError(protocol.CodeBatchTooLarge, "%d blinded elements, the limit of a grouped batch is %d",
+			count, math.MaxUint16)
+	}
+
+	for _, info := range evaluationRequest.Infos {
+		if err := l.validateInfo(info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateInputs checks the inputs and the public information of a full evaluation request in the mode, as Validate
+// checks the blinded elements.
+func (l Limits) ValidateInputs(mode oprf.Mode, fullEvaluationRequest *protocol.FullEvaluationRequest) error {
+	switch count := len(fullEvaluationRequest.Inputs); {
+	case count == 0:
+		return protocol.NewError(protocol.CodeEmptyBatch, "no input")
+	case count > l.MaxBatchSize:
+		return protocol.NewError(protocol.CodeBatchTooLarge, "%d inputs, the limit is %d", count, l.MaxBatchSize)
+	}
+
+	if mode != oprf.PartialObliviousMode && len(fullEvaluationRequest.Info) > 0 {
+		return protocol.NewError(protocol.CodeInfoNotAllowed, "the %s mode has no public information, use the %s mode",
+			fullEvaluationRequest.Mode, protocol.ModePartialOblivious)
+	}
+
+	return l.validateInfo(fullEvaluationRequest.Info)
+}
+
+// ValidateVerification checks the public information of a verification request in the mode.
+func (l Limits) ValidateVerification(mode oprf.Mode, verificationRequest *protocol.VerificationRequest) error {
+	if mode != oprf.PartialObliviousMode && len(verificationRequest.Info) > 0 {
+		return protocol.NewError(protocol.CodeInfoNotAllowed, "the %s mode has no public information, use the %s mode",
+			verificationRequest.Mode, protocol.ModePartialOblivious)
+	}
+
+	return l.validateInfo(verificationRequest.Info)
+}
+
+func (l Limits) validateInfo(info []byte) error {
+	if length := len(info); length > l.MaxInfoLength {
+		return protocol.NewError(protocol.CodeInfoTooLong, "%d bytes public information, the limit is %d", length,
+			l.MaxInfoLength)
+	}
+
+	return nil
+}
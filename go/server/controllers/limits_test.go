@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+func TestLimitsValidate(t *testing.T) {
+	limits := Limits{MaxBodySize: DefaultMaxBodySize, MaxBatchSize: 2, MaxInfoLength: 4}
+	element := []byte{0x02}
+
+	for _, test := range []struct {
+		name     string
+		mode     oprf.Mode
+		info     string
+		elements [][]byte
+		expected error
+	}{
+		{"valid", oprf.VerifiableMode, "", [][]byte{element, element}, nil},
+		{"valid info", oprf.PartialObliviousMode, "info", [][]byte{element}, nil},
+		{"empty batch", oprf.BaseMode, "", nil, protocol.ErrEmptyBatch},
+		{"batch too large", oprf.BaseMode, "", [][]byte{element, element, element}, protocol.ErrBatchTooLarge},
+		{"base mode info", oprf.BaseMode, "info", [][]byte{element}, protocol.ErrInfoNotAllowed},
+		{"verifiable mode info", oprf.VerifiableMode, "info", [][]byte{element}, protocol.ErrInfoNotAllowed},
+		{"info too long", oprf.PartialObliviousMode, "infos", [][]byte{element}, protocol.ErrInfoTooLong},
+	} {
+		err := limits.Validate(test.mode, &protocol.EvaluationRequest{
+			Suite:           oprf.SuiteP256.Identifier(),
+			Mode:            protocol.NewMode(test.mode),
+			Info:            []byte(test.info),
+			BlindedElements: test.elements,
+			KeyID:           "",
+		})
+
+		if (test.expected == nil && err != nil) || (test.expected != nil && !errors.Is(err, test.expected)) {
+			t.Errorf("%s : %v, expected %v", test.name, err, test.expected)
+		}
+	}
+	for _, test := range []struct {
+		name     string
+		info     string
+		infos    []string
+		expected error
+	}{
+		{"valid infos", "", []string{"info", ""}, nil},
+		{"info and infos", "info", []string{"info", "info"}, protocol.ErrInvalidRequest},
+		{"missing info", "", []string{"info"}, protocol.ErrInvalidRequest},
+		{"element info too long", "", []string{"info", "infos"}, protocol.ErrInfoTooLong},
+	} {
+		evaluationRequest := &protocol.EvaluationRequest{ //nolint:exhaustivestruct
+			Suite:           oprf.SuiteP256.Identifier(),
+			Mode:            protocol.ModePartialOblivious,
+			Info:            []byte(test.info),
+			BlindedElements: [][]byte{element, element},
+		}
+
+		for _, info := range test.infos {
+			evaluationRequest.Infos = append(evaluationRequest.Infos, []byte(info))
+		}
+
+		err := limits.Validate(oprf.PartialObliviousMode, evaluationRequest)
+		if (test.expected == nil && err != nil) || (test.expected != nil && !errors.Is(err, test.expected)) {
+			t.Errorf("%s : %v, expected %v", test.name, err, test.expected)
+		}
+	}
+}
+
+func TestEvaluateHandlerBodyLimit(t *testing.T) {
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	controller.SetLimits(Limits{MaxBodySize: 64, MaxBatchSize: DefaultMaxBatchSize, MaxInfoLength: DefaultMaxInfoLength})
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v2/evaluate", strings.NewReader(strings.Repeat(" ", 65)))
+	request.Header.Set(echo.HeaderContentType, protocol.ContentTypeJSON)
+
+	err := controller.EvaluateHandler(echo.New().NewContext(request, httptest.NewRecorder()))
+	if !errors.Is(err, protocol.ErrRequestTooLarge) {
+		t.Fatalf("%v, expected a request too large error", err)
+	}
+
+	var apiError *protocol.Error
+	if !errors.As(err, &apiError) || apiError.HTTPStatus() != http.StatusRequestEntityTooLarge {
+		t.Errorf("%v, expected the status %d", err, http.StatusRequestEntityTooLarge)
+	}
+
+	// a body of the limit is decoded
+	request = httptest.NewRequest(http.MethodPost, "/api/v2/evaluate", strings.NewReader(strings.Repeat(" ", 64)))
+	request.Header.Set(echo.HeaderContentType, protocol.ContentTypeJSON)
+
+	err = controller.EvaluateHandler(echo.New().NewContext(request, httptest.NewRecorder()))
+	if !errors.Is(err, protocol.ErrInvalidRequest) {
+		t.Errorf("%v, expected an invalid request error", err)
+	}
+}
@@ -1,20 +1,45 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
 )
 
+// DefaultRotationInterval is the default interval between the rotation checks of the keys
+const DefaultRotationInterval = time.Minute
+
 type (
 	// suite:base64 private key
 	SerializedBase64KeyMap map[string]string
-	// suite:private key
-	KeyMap map[string]*oprf.PrivateKey
-	// mode:suite:server
-	ServerMap map[oprf.Mode]map[string]Server
+	// suite:serialized keys
+	SerializedKeySetMap map[string][]SerializedKey
+	// suite:key set
+	KeySetMap map[string]*KeySet
+	// mode:suite:kid:server
+	ServerMap map[oprf.Mode]map[string]map[string]Server
 )
 
+// SerializedKey is a base64 serialized private key with its metadata
+type SerializedKey struct {
+	ID         string    `json:"kid"`
+	CreatedAt  time.Time `json:"created_at"`
+	State      KeyState  `json:"state"`
+	PrivateKey string    `json:"private_key"`
+	// Share is set if the private key is a share of the key
+	Share *KeyShareInfo `json:"share,omitempty"`
+	KeyValidity
+}
+
 type Server interface {
 	Evaluate(req *oprf.EvaluationRequest, info []byte) (*oprf.Evaluation, error)
 	FullEvaluate(input, info []byte) (output []byte, err error)
@@ -73,48 +98,491 @@ func (s PartialObliviousServer) Suite() oprf.Suite {
 	return s.suite
 }
 
-// OPRFServerController holds the private keys and the servers
+// OPRFServerController holds the key sets and the servers of each key
 type OPRFServerController struct {
-	keys      KeyMap
-	servers   ServerMap
-	keysMu    sync.RWMutex
-	serversMu sync.RWMutex
+	keyProvider KeyProvider
+	keys        KeySetMap
+	servers     ServerMap
+	keysMu      sync.RWMutex
+	serversMu   sync.RWMutex
+	// reloadMu serializes the reloads and the rotations
+	reloadMu sync.Mutex
+	// gracePeriod is the time a replaced key stays verify-only before being retired
+	gracePeriod time.Duration
+	// serveExpiredKeys keeps evaluating with an expired active key, it is set before serving
+	serveExpiredKeys bool
+	// perRequest is set if the controller only serves one request, its usage counters can't enforce max_evaluations
+	perRequest bool
+	// limits bound the evaluation requests, they are set before serving
+	limits Limits
+	// trustedProxies are the reverse proxies whose X-Forwarded-For header is trusted, they are set before serving
+	trustedProxies []*net.IPNet
+	// infoPolicy checks the public information of the partially oblivious mode, it is set before serving
+	infoPolicy InfoPolicy
+	// keySigner signs the well-known key set, it is set before serving
+	keySigner *KeySigner
+	// signedKeys is the last signed key set, guarded by signedKeysMu
+	signedKeys   *signedKeySet
+	signedKeysMu sync.Mutex
+	// transparencyLog records the published keys and their state changes, it is set before serving
+	transparencyLog *TransparencyLog
+	// self-test results of the library and of the servers of each suite, guarded by keysMu
+	knownAnswerResults []SelfTestResult
+	selfTestResults    map[string][]SelfTestResult
+	selfTestCheckedAt  time.Time
+	healthy            bool
 }
 
 func NewOPRFServerController() *OPRFServerController {
 	controller := &OPRFServerController{ //nolint:exhaustivestruct
-		keys:    make(KeyMap),
-		servers: make(ServerMap),
+		keys:            make(KeySetMap),
+		servers:         make(ServerMap),
+		selfTestResults: make(map[string][]SelfTestResult),
+		limits:          DefaultLimits(),
+		transparencyLog: NewMemoryTransparencyLog(),
 	}
-	controller.servers[oprf.BaseMode] = make(map[string]Server)
-	controller.servers[oprf.VerifiableMode] = make(map[string]Server)
-	controller.servers[oprf.PartialObliviousMode] = make(map[string]Server)
+	controller.servers[oprf.BaseMode] = make(map[string]map[string]Server)
+	controller.servers[oprf.VerifiableMode] = make(map[string]map[string]Server)
+	controller.servers[oprf.PartialObliviousMode] = make(map[string]map[string]Server)
 
 	return controller
 }
 
-// Initialize generate private keys and initialize the encryption's suite servers
-func (s *OPRFServerController) Initialize(serializedBase64KeyMap SerializedBase64KeyMap) error {
-	suites := []oprf.Suite{oprf.SuiteP256, oprf.SuiteP384, oprf.SuiteP521}
+// SupportedSuites returns the cipher suites served by the controller
+func SupportedSuites() []oprf.Suite {
+	return []oprf.Suite{oprf.SuiteP256, oprf.SuiteP384, oprf.SuiteP521, oprf.SuiteRistretto255}
+}
+
+// Initialize loads the key sets from the provider and initialize the encryption's suite servers of each key.
+// The key set of a suite missing from the provider is generated. The evaluation of each suite in each mode is
+// checked against known answers, then with the servers of each key : it returns ErrSelfTest if a check failed.
+func (s *OPRFServerController) Initialize(keyProvider KeyProvider) error {
+	s.keyProvider = keyProvider
+
+	knownAnswerResults := RunKnownAnswerTests()
+	for _, result := range knownAnswerResults {
+		if !result.Passed {
+			log.Println("Known-answer test failed :", result.Suite, result.Mode, result.Error)
+		}
+	}
+
+	s.keysMu.Lock()
+	s.knownAnswerResults = knownAnswerResults
+	s.keysMu.Unlock()
+
+	if _, err := s.Reload(); err != nil {
+		return err
+	}
+
+	if !selfTestPassed(knownAnswerResults) {
+		return fmt.Errorf("%w : known-answer tests", ErrSelfTest)
+	}
+
+	return nil
+}
+
+// SetGracePeriod sets the time a replaced key stays verify-only before being retired, 0 never retires it.
+func (s *OPRFServerController) SetGracePeriod(gracePeriod time.Duration) {
+	s.reloadMu.Lock()
+	s.gracePeriod = gracePeriod
+	s.reloadMu.Unlock()
+}
+
+// SetServeExpiredKeys keeps evaluating with an expired active key until its successor is due, instead of refusing
+// the evaluations with key_unavailable. It must be called before serving the requests.
+func (s *OPRFServerController) SetServeExpiredKeys(serveExpiredKeys bool) {
+	s.serveExpiredKeys = serveExpiredKeys
+}
+
+// SetPerRequest marks a controller created for a single request, as the serverless function. Its usage counters
+// don't outlast the request, so the keys with a maximum number of evaluations are refused. It must be called
+// before Initialize.
+func (s *OPRFServerController) SetPerRequest(perRequest bool) {
+	s.perRequest = perRequest
+}
+
+// SetTrustedProxies sets the reverse proxies whose X-Forwarded-For header gives the client address, it must be called
+// before creating the router.
+func (s *OPRFServerController) SetTrustedProxies(trustedProxies []*net.IPNet) {
+	s.trustedProxies = trustedProxies
+}
+
+// IPExtractor returns the extractor of the client address of the audit logs : the address of the connection, or the
+// X-Forwarded-For header if the connection comes from a trusted proxy. The headers of the other callers are ignored
+// so they can't forge their audited address.
+func (s *OPRFServerController) IPExtractor() echo.IPExtractor {
+	if len(s.trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	trustOptions := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, ipRange := range s.trustedProxies {
+		trustOptions = append(trustOptions, echo.TrustIPRange(ipRange))
+	}
+
+	return echo.ExtractIPFromXFFHeader(trustOptions...)
+}
+
+// SetLimits sets the limits of the evaluation requests, it must be called before serving the requests.
+func (s *OPRFServerController) SetLimits(limits Limits) {
+	s.limits = limits
+}
+
+// SetInfoPolicy sets the policy of the public information, it must be called before serving the requests.
+func (s *OPRFServerController) SetInfoPolicy(infoPolicy InfoPolicy) {
+	s.infoPolicy = infoPolicy
+}
+
+// Reload loads the key sets from the provider again and swaps the servers of the changed suites.
+// The evaluations in progress finish with the previous servers. The current key set of a suite missing
+// from the provider is kept. The current keys are also kept if the self-test of the new servers fails.
+// It returns the changed suites.
+func (s *OPRFServerController) Reload() ([]string, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	suites := SupportedSuites()
+
+	keySets, err := s.keyProvider.LoadKeySets(suites)
+	if err != nil {
+		return nil, err
+	}
+
+	changedKeySets := make(KeySetMap)
+	now := time.Now()
+
 	for _, suite := range suites {
 		suiteID := suite.Identifier()
 
-		privateKey, err := LoadOrGenerateKey(suite, serializedBase64KeyMap)
-		if err != nil {
-			return err
+		s.keysMu.RLock()
+		currentKeySet := s.keys[suiteID]
+		s.keysMu.RUnlock()
+
+		keySet, ok := keySets[suiteID]
+
+		switch {
+		case !ok && currentKeySet != nil:
+			continue
+		case !ok:
+			log.Println("No key for the suite", suiteID, ": generating a new key")
+
+			keySet, err = GenerateKeySet(suite)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if s.perRequest {
+			for _, key := range keySet.Keys() {
+				if key.MaxEvaluations > 0 {
+					return nil, fmt.Errorf("%w : key %s of the suite %s", ErrUnenforceableMaxEvaluations, key.ID,
+						suiteID)
+				}
+			}
+		}
+
+		// the loaded key set is rotated as the current one, with the same usage counters
+		keySet.inheritUsage(currentKeySet)
+		keySet, _ = keySet.Rotate(now, s.gracePeriod)
+
+		if currentKeySet != nil && keySetDigest(currentKeySet) == keySetDigest(keySet) {
+			continue
+		}
+
+		if currentKeySet != nil {
+			log.Println("Reloading the keys of the suite", suiteID)
+			logKeySetFingerprints("Previous", currentKeySet)
+		}
+
+		logKeySetFingerprints("Loaded", keySet)
+
+		changedKeySets[suiteID] = keySet
+	}
+
+	return s.swapKeySets(changedKeySets)
+}
+
+// Rotate promotes the pending keys that are due and retires the verify-only keys at the end of the grace period,
+// see KeySet.Rotate. It returns the changed suites.
+func (s *OPRFServerController) Rotate(now time.Time) ([]string, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	changedKeySets := make(KeySetMap)
+
+	for _, suite := range SupportedSuites() {
+		suiteID := suite.Identifier()
+
+		s.keysMu.RLock()
+		currentKeySet := s.keys[suiteID]
+		s.keysMu.RUnlock()
+
+		if currentKeySet == nil {
+			continue
+		}
+
+		keySet, changed := currentKeySet.Rotate(now, s.gracePeriod)
+		if changed {
+			logRotation(currentKeySet, keySet)
+
+			changedKeySets[suiteID] = keySet
+		}
+
+		if active := keySet.Active(); active != nil && active.Expired(now) &&
+			atomic.CompareAndSwapUint32(&active.usage.expiryLogged, 0, 1) {
+			if s.serveExpiredKeys {
+				log.Println("The active key", active.ID, "of the suite", suiteID, "expired without pending key,",
+					"it is still served")
+			} else {
+				log.Println("The active key", active.ID, "of the suite", suiteID, "expired without pending key,",
+					"the evaluations are refused")
+			}
+		}
+	}
+
+	return s.swapKeySets(changedKeySets)
+}
+
+// RotateEvery rotates the keys at each interval, it never returns.
+func (s *OPRFServerController) RotateEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if _, err := s.Rotate(now); err != nil {
+			log.Println("Rotation error :", err)
+		}
+	}
+}
+
+// swapKeySets self-tests the servers of the changed suites, then replaces their key sets and servers
+// and returns them. Nothing is replaced if a check failed.
+func (s *OPRFServerController) swapKeySets(changedKeySets KeySetMap) ([]string, error) {
+	changedServers := make(map[string]map[oprf.Mode]map[string]Server)
+	changedResults := make(map[string][]SelfTestResult)
+	passed := true
+
+	for suiteID, keySet := range changedKeySets {
+		changedServers[suiteID] = newSuiteServers(keySet)
+		changedResults[suiteID] = selfTestServers(keySet, changedServers[suiteID])
+
+		for _, result := range changedResults[suiteID] {
+			if !result.Passed {
+				log.Println("Self-test failed :", result.Suite, result.Mode, result.KeyID, result.Error)
+
+				passed = false
+			}
+		}
+	}
+
+	// the key sets and the servers are swapped together, see evaluationServer
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+
+	s.selfTestCheckedAt = time.Now().UTC()
+
+	if !passed {
+		// the failed results are reported for the suites without served keys
+		for suiteID, results := range changedResults {
+			if s.keys[suiteID] == nil {
+				s.selfTestResults[suiteID] = results
+				s.healthy = false
+			}
+		}
+
+		return nil, ErrSelfTest
+	}
+
+	// the keys are logged before being served
+	var logEntries []protocol.KeyLogEntry
+
+	for _, suite := range SupportedSuites() {
+		if keySet, ok := changedKeySets[suite.Identifier()]; ok {
+			logEntries = append(logEntries, keyLogEntries(keySet)...)
+		}
+	}
+
+	if _, err := s.transparencyLog.Append(logEntries); err != nil {
+		return nil, err
+	}
+
+	s.serversMu.Lock()
+
+	changedSuites := make([]string, 0, len(changedKeySets))
+
+	for _, suite := range SupportedSuites() {
+		suiteID := suite.Identifier()
+
+		keySet, ok := changedKeySets[suiteID]
+		if !ok {
+			continue
 		}
 
-		s.keys[suiteID] = privateKey
+		s.keys[suiteID] = keySet
+		s.selfTestResults[suiteID] = changedResults[suiteID]
+
+		for mode, servers := range changedServers[suiteID] {
+			s.servers[mode][suiteID] = servers
+		}
+
+		changedSuites = append(changedSuites, suiteID)
+	}
+
+	s.serversMu.Unlock()
 
-		// create the base and verifiable servers for a provided encryption suite.
-		baseModeServer := oprf.NewServer(suite, privateKey)
-		verifiableModeServer := oprf.NewVerifiableServer(suite, privateKey)
-		partialObliviousModeServer := oprf.NewPartialObliviousServer(suite, privateKey)
+	s.healthy = selfTestPassed(s.knownAnswerResults)
+	for _, results := range s.selfTestResults {
+		s.healthy = s.healthy && selfTestPassed(results)
+	}
 
-		s.servers[oprf.BaseMode][suiteID] = BaseServer{baseModeServer, suite}
-		s.servers[oprf.VerifiableMode][suiteID] = VerifiableServer{verifiableModeServer, suite}
-		s.servers[oprf.PartialObliviousMode][suiteID] = PartialObliviousServer{partialObliviousModeServer, suite}
+	return changedSuites, nil
+}
+
+// newSuiteServers creates the servers of each published key of the key set : mode:kid:server.
+func newSuiteServers(keySet *KeySet) map[oprf.Mode]map[string]Server {
+	suite := keySet.Suite()
+	servers := map[oprf.Mode]map[string]Server{
+		oprf.BaseMode:             make(map[string]Server),
+		oprf.VerifiableMode:       make(map[string]Server),
+		oprf.PartialObliviousMode: make(map[string]Server),
+	}
+
+	// retired keys are never used for an evaluation
+	for _, key := range keySet.Published() {
+		for mode := range servers {
+			// the partially oblivious evaluation inverts the (tweaked) key so it can't be combined from key shares
+			if mode == oprf.PartialObliviousMode && key.Share != nil {
+				continue
+			}
+
+			servers[mode][key.ID] = newServer(suite, mode, key.PrivateKey)
+		}
+	}
+
+	return servers
+}
+
+// newServer creates the server of the encryption suite in the mode.
+func newServer(suite oprf.Suite, mode oprf.Mode, privateKey *oprf.PrivateKey) Server {
+	switch mode {
+	case oprf.BaseMode:
+		return BaseServer{oprf.NewServer(suite, privateKey), suite}
+	case oprf.VerifiableMode:
+		return VerifiableServer{oprf.NewVerifiableServer(suite, privateKey), suite}
+	case oprf.PartialObliviousMode:
+		return PartialObliviousServer{oprf.NewPartialObliviousServer(suite, privateKey), suite}
 	}
 
 	return nil
 }
+
+// evaluationServer returns the evaluation key and its server. An expired active key is refused, unless the expired
+// keys are served : the keys are rotated first in case its successor is due.
+func (s *OPRFServerController) evaluationServer(mode oprf.Mode, suiteID, keyID string) (*Key, Server, error) {
+	key, server, err := s.lookupEvaluationServer(mode, suiteID, keyID, time.Now())
+	if !errors.Is(err, ErrExpiredKey) {
+		return key, server, err
+	}
+
+	now := time.Now()
+	if _, err := s.Rotate(now); err != nil {
+		log.Println("Rotation error :", err)
+	}
+
+	return s.lookupEvaluationServer(mode, suiteID, keyID, now)
+}
+
+// lookupEvaluationServer returns the evaluation key and its server. The key set and the servers are read together
+// so a concurrent reload can't mix them.
+func (s *OPRFServerController) lookupEvaluationServer(mode oprf.Mode, suiteID, keyID string,
+	now time.Time,
+) (*Key, Server, error) {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+
+	keySet := s.keys[suiteID]
+	if keySet == nil {
+		return nil, nil, protocol.NewError(protocol.CodeUnknownSuite, "the suite %q isn't served", suiteID)
+	}
+
+	key, err := keySet.EvaluationKey(keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key.State == KeyStateActive && !s.serveExpiredKeys && key.Expired(now) {
+		return nil, nil, fmt.Errorf("%w : %s of the suite %s", ErrExpiredKey, key.ID, suiteID)
+	}
+
+	s.serversMu.RLock()
+	defer s.serversMu.RUnlock()
+
+	return key, s.servers[mode][suiteID][key.ID], nil
+}
+
+// verificationServer returns the key and a server of the key, including the retired keys whose outputs can still be
+// verified. The active key is used if the key ID is empty.
+func (s *OPRFServerController) verificationServer(mode oprf.Mode, suiteID, keyID string) (*Key, Server, error) {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+
+	keySet := s.keys[suiteID]
+	if keySet == nil {
+		return nil, nil, protocol.NewError(protocol.CodeUnknownSuite, "the suite %q isn't served", suiteID)
+	}
+
+	var key *Key
+	if keyID == "" {
+		if key = keySet.Active(); key == nil {
+			return nil, nil, fmt.Errorf("%w for the suite %s", ErrNoActiveKey, suiteID)
+		}
+	} else if key, _ = keySet.Get(keyID); key == nil {
+		return nil, nil, fmt.Errorf("%w : %s", ErrUnknownKeyID, keyID)
+	}
+
+	// a key share only computes partial evaluations
+	if key.Share != nil {
+		return nil, nil, protocol.NewError(protocol.CodeUnsupportedMode,
+			"the outputs can't be verified with the key share %q", key.ID)
+	}
+
+	s.serversMu.RLock()
+	defer s.serversMu.RUnlock()
+
+	// the retired keys have no server
+	server, ok := s.servers[mode][suiteID][key.ID]
+	if !ok {
+		server = newServer(keySet.Suite(), mode, key.PrivateKey)
+	}
+
+	return key, server, nil
+}
+
+// keySetDigest summarizes the keys of a key set, two key sets with the same digest have the same servers.
+func keySetDigest(keySet *KeySet) string {
+	var digest strings.Builder
+
+	for _, key := range keySet.Keys() {
+		fmt.Fprintf(&digest, "%s:%s:%s:%v:%v:%d;", key.ID, key.State, Fingerprint(keySet.Suite(), key.PrivateKey.Public()),
+			key.NotBefore, key.NotAfter, key.MaxEvaluations)
+	}
+
+	return digest.String()
+}
+
+// logRotation logs the state changes of the keys.
+func logRotation(previous, rotated *KeySet) {
+	for index, key := range rotated.Keys() {
+		if previousState := previous.Keys()[index].State; previousState != key.State {
+			log.Printf("Key %s of the suite %s : %s -> %s, fingerprint %s\n", key.ID, rotated.Suite().Identifier(),
+				previousState, key.State, Fingerprint(rotated.Suite(), key.PrivateKey.Public()))
+		}
+	}
+}
+
+func logKeySetFingerprints(prefix string, keySet *KeySet) {
+	for _, key := range keySet.Keys() {
+		log.Printf("%s key %s of the suite %s : %s, fingerprint %s\n", prefix, key.ID, keySet.Suite().Identifier(),
+			key.State, Fingerprint(keySet.Suite(), key.PrivateKey.Public()))
+	}
+}
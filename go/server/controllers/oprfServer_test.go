@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+func TestReload(t *testing.T) {
+	suite := oprf.SuiteP256
+	suiteID := suite.Identifier()
+
+	keySet, err := GenerateKeySet(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyProvider := NewMemoryKeyProvider(keySet)
+	controller := NewOPRFServerController()
+
+	if err := controller.Initialize(keyProvider); err != nil {
+		t.Fatal(err)
+	}
+
+	previousKey, previousServer, err := controller.evaluationServer(oprf.VerifiableMode, suiteID, "")
+	if err != nil || previousServer == nil {
+		t.Fatal("no server", err)
+	}
+
+	// the generated keys of the other suites are kept
+	reloaded, err := controller.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reloaded) != 0 {
+		t.Errorf("no key changed, reloaded %v", reloaded)
+	}
+
+	rotatedKeySet, err := GenerateKeySet(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyOnlyKey := *previousKey
+	verifyOnlyKey.State = KeyStateVerifyOnly
+
+	if err := rotatedKeySet.Add(&verifyOnlyKey); err != nil {
+		t.Fatal(err)
+	}
+
+	keyProvider.KeySets[suiteID] = rotatedKeySet
+
+	reloaded, err = controller.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reloaded) != 1 || reloaded[0] != suiteID {
+		t.Errorf("only the P256 keys changed, reloaded %v", reloaded)
+	}
+
+	key, _, err := controller.evaluationServer(oprf.VerifiableMode, suiteID, "")
+	if err != nil || key.ID != rotatedKeySet.Active().ID {
+		t.Errorf("the new key should be active : %v", err)
+	}
+
+	if _, server, err := controller.evaluationServer(oprf.VerifiableMode, suiteID, previousKey.ID); err != nil ||
+		server == nil {
+		t.Errorf("the previous key should still be published : %v", err)
+	}
+
+	// an evaluation in progress finishes with the previous server
+	if _, err := previousServer.FullEvaluate([]byte("input"), nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpiredActiveKey(t *testing.T) {
+	suite := oprf.SuiteP256
+	suiteID := suite.Identifier()
+
+	keySet, err := GenerateKeySet(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := time.Now().Add(-time.Hour)
+	keySet.Active().NotAfter = &expired
+
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider(keySet)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the expired key without successor isn't used
+	if _, _, err := controller.evaluationServer(oprf.BaseMode, suiteID, ""); !errors.Is(err, ErrExpiredKey) ||
+		APIError(err).Code != "key_unavailable" {
+		t.Errorf("the expired key is used : %v", err)
+	}
+
+	controller.SetServeExpiredKeys(true)
+
+	if _, _, err := controller.evaluationServer(oprf.BaseMode, suiteID, ""); err != nil {
+		t.Errorf("the expired key isn't served on demand : %v", err)
+	}
+
+	// the successor due at the expiry is promoted by the evaluation, before the next scheduled rotation
+	keySet, err = GenerateKeySet(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notAfter := time.Now().Add(50 * time.Millisecond)
+	keySet.Active().NotAfter = &notAfter
+
+	privateKey, err := oprf.GenerateKey(suite, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pendingKey := NewKey(suite, privateKey)
+	pendingKey.State = KeyStatePending
+
+	if err := keySet.Add(pendingKey); err != nil {
+		t.Fatal(err)
+	}
+
+	controller = NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider(keySet)); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if key, _, err := controller.evaluationServer(oprf.BaseMode, suiteID, ""); err != nil || key.ID != pendingKey.ID {
+		t.Errorf("the successor isn't promoted : %v", err)
+	}
+
+	// the usage counters of a per-request controller can't enforce a maximum number of evaluations
+	keySet.Active().MaxEvaluations = 10
+
+	perRequestController := NewOPRFServerController()
+	perRequestController.SetPerRequest(true)
+
+	err = perRequestController.Initialize(NewMemoryKeyProvider(keySet))
+	if !errors.Is(err, ErrUnenforceableMaxEvaluations) {
+		t.Errorf("max_evaluations is accepted per request : %v", err)
+	}
+}
+
+func TestIPExtractor(t *testing.T) {
+	newRequest := func(remoteAddr string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/api/verify", nil)
+		request.RemoteAddr = remoteAddr
+		request.Header.Set("X-Forwarded-For", "192.0.2.1")
+		request.Header.Set("X-Real-IP", "192.0.2.1")
+
+		return request
+	}
+
+	controller := NewOPRFServerController()
+
+	// the headers of a direct caller are ignored
+	if ip := controller.IPExtractor()(newRequest("203.0.113.7:4242")); ip != "203.0.113.7" {
+		t.Errorf("forged address %s", ip)
+	}
+
+	_, trustedProxy, _ := net.ParseCIDR("203.0.113.0/24")
+	controller.SetTrustedProxies([]*net.IPNet{trustedProxy})
+
+	if ip := controller.IPExtractor()(newRequest("203.0.113.7:4242")); ip != "192.0.2.1" {
+		t.Errorf("the address forwarded by the trusted proxy isn't used : %s", ip)
+	}
+
+	if ip := controller.IPExtractor()(newRequest("10.0.0.1:4242")); ip != "10.0.0.1" {
+		t.Errorf("the address forwarded by an untrusted proxy is used : %s", ip)
+	}
+}
+
+func TestNewOPRFServerControllerFromEnv(t *testing.T) {
+	t.Setenv(EnvKeyGracePeriod, "720h")
+	t.Setenv(EnvServeExpiredKeys, "true")
+
+	controller, err := NewOPRFServerControllerFromEnv(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if controller.gracePeriod != 720*time.Hour || !controller.serveExpiredKeys || !controller.perRequest {
+		t.Errorf("the controller is not configured by the environment")
+	}
+
+	t.Setenv(EnvKeyGracePeriod, "a month")
+
+	if _, err := NewOPRFServerControllerFromEnv(false); err == nil {
+		t.Errorf("an invalid grace period should be refused")
+	}
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/circl/group"
+	"github.com/cloudflare/circl/oprf"
+	"github.com/cloudflare/circl/zk/dleq"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+func TestEvaluateHandlerPartialSuccess(t *testing.T) {
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	suite := oprf.SuiteP256
+	inputs := [][]byte{{0x00}, {0xFF}}
+
+	// the blinding doesn't depend on the public key of the client
+	blindingKey, err := oprf.GenerateKey(suite, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finalizeData, oprfEvaluationRequest, err := oprf.NewVerifiableClient(suite, blindingKey.Public()).Blind(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the invalid element has the length of a compressed element for the binary encoding, but no point has its x
+	validElement, _ := oprfEvaluationRequest.Elements[1].MarshalBinaryCompress()
+	invalidElement := append([]byte{0x02}, bytes.Repeat([]byte{0xFF}, len(validElement)-1)...)
+	evaluationRequest := &protocol.EvaluationRequest{ //nolint:exhaustivestruct
+		Suite:           suite.Identifier(),
+		Mode:            protocol.ModeVerifiable,
+		BlindedElements: [][]byte{invalidElement, validElement},
+		PartialSuccess:  true,
+	}
+
+	for _, contentType := range []string{protocol.ContentTypeJSON, protocol.ContentTypeCBOR, protocol.ContentTypeBinary} {
+		body, _ := protocol.EncodeEvaluationRequest(contentType, evaluationRequest)
+		request := httptest.NewRequest(http.MethodPost, "/api/v2/evaluate", bytes.NewReader(body))
+		request.Header.Set(echo.HeaderContentType, contentType)
+
+		recorder := httptest.NewRecorder()
+		if err := controller.EvaluateHandler(echo.New().NewContext(request, recorder)); err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		response := new(protocol.EvaluationResponse)
+		if err := protocol.DecodeEvaluationResponse(contentType, recorder.Body.Bytes(), response); err != nil {
+			t.Fatal(contentType, err)
+		}
+
+		if len(response.Statuses) != 2 || response.Statuses[0].Evaluated || response.Statuses[1].Error != nil ||
+			!errors.Is(response.Statuses[0].Error, protocol.ErrInvalidElement) || !response.Statuses[1].Evaluated {
+			t.Fatalf("%s : unexpected statuses %v", contentType, response.Statuses)
+		}
+
+		// the proof only covers the evaluated element
+		publicKey := new(oprf.PublicKey)
+		if err := publicKey.UnmarshalBinary(suite, response.SerializedPublicKey); err != nil {
+			t.Fatal(err)
+		}
+
+		validFinalizeData, _, err := oprf.NewVerifiableClient(suite, publicKey).DeterministicBlind(inputs[1:],
+			finalizeData.CopyBlinds()[1:])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		evaluation := &oprf.Evaluation{Elements: make([]group.Element, 1), Proof: new(dleq.Proof)}
+		evaluation.Elements[0] = suite.Group().NewElement()
+
+		if err := evaluation.Elements[0].UnmarshalBinary(response.Evaluation.Elements[0]); err != nil {
+			t.Fatal(err)
+		} else if err := evaluation.Proof.UnmarshalBinary(suite.Group(), response.Evaluation.Proof); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := oprf.NewVerifiableClient(suite, publicKey).Finalize(validFinalizeData, evaluation); err != nil {
+			t.Errorf("%s : %v", contentType, err)
+		}
+	}
+
+	// a batch without any valid element fails
+	evaluationRequest.BlindedElements = evaluationRequest.BlindedElements[:1]
+	body, _ := json.Marshal(evaluationRequest)
+	request := httptest.NewRequest(http.MethodPost, "/api/v2/evaluate", bytes.NewReader(body))
+	request.Header.Set(echo.HeaderContentType, protocol.ContentTypeJSON)
+
+	err = controller.EvaluateHandler(echo.New().NewContext(request, httptest.NewRecorder()))
+	if !errors.Is(err, protocol.ErrInvalidElement) {
+		t.Errorf("batch without valid element : %v", err)
+	}
+}
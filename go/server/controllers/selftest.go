@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	SelfTestKnownAnswer = "known-answer"
+	SelfTestRoundTrip   = "round-trip"
+)
+
+var ErrSelfTest = errors.New("self-test failed")
+
+// knownAnswer are the RFC 9497 test vectors of a suite and a mode, the known-answer test uses the first one
+type knownAnswer struct {
+	Identifier string    `json:"identifier"`
+	Mode       oprf.Mode `json:"mode"`
+	Seed       string    `json:"seed"`
+	KeyInfo    string    `json:"keyInfo"`
+	Vectors    []struct {
+		Blind  string `json:"Blind"`
+		Info   string `json:"Info"`
+		Input  string `json:"Input"`
+		Output string `json:"Output"`
+	} `json:"vectors"`
+}
+
+var (
+	// the known-answer tests run once per process, the serverless function creates a controller per request
+	knownAnswerOnce    sync.Once
+	knownAnswerResults []SelfTestResult
+
+	// selfTestInput is the fixed input of the round trips with the server keys
+	selfTestInput = []byte("ensimag-oprf self-test")
+	selfTestInfo  = []byte("ensimag-oprf self-test info")
+)
+
+// SelfTestResult is the result of a check of a suite in a mode
+type SelfTestResult struct {
+	Check  string    `json:"check"`
+	Suite  string    `json:"suite"`
+	Mode   oprf.Mode `json:"mode"`
+	KeyID  string    `json:"kid,omitempty"`
+	Passed bool      `json:"passed"`
+	Error  string    `json:"error,omitempty"`
+}
+
+func newSelfTestResult(check string, suite oprf.Suite, mode oprf.Mode, keyID string, err error) SelfTestResult {
+	result := SelfTestResult{Check: check, Suite: suite.Identifier(), Mode: mode, KeyID: keyID, Passed: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// SelfTestReport is the result of the self-test of the served keys
+type SelfTestReport struct {
+	Passed    bool             `json:"passed"`
+	CheckedAt time.Time        `json:"checked_at"`
+	Results   []SelfTestResult `json:"results"`
+}
+
+// RunKnownAnswerTests checks the evaluation of each suite in each mode against the first RFC 9497 test vector. The
+// tests run once per process, the next calls return the same results.
+func RunKnownAnswerTests() []SelfTestResult {
+	knownAnswerOnce.Do(func() {
+		knownAnswerResults = runKnownAnswerTests()
+	})
+
+	return knownAnswerResults
+}
+
+func runKnownAnswerTests() []SelfTestResult {
+	var knownAnswers []knownAnswer
+	if err := json.Unmarshal(protocol.RFC9497TestVectors, &knownAnswers); err != nil {
+		return []SelfTestResult{{
+			Check: SelfTestKnownAnswer, Suite: "", Mode: 0, KeyID: "", Passed: false,
+			Error: fmt.Sprintf("%s : couldn't parse the test vectors : %s", ErrSelfTest, err.Error()),
+		}}
+	}
+
+	results := make([]SelfTestResult, 0, len(SupportedSuites())*3)
+
+	for _, suite := range SupportedSuites() {
+		for _, vector := range knownAnswers {
+			if vector.Identifier == suite.Identifier() {
+				results = append(results, newSelfTestResult(SelfTestKnownAnswer, suite, vector.Mode, "",
+					runKnownAnswerTest(suite, vector)))
+			}
+		}
+	}
+
+	return results
+}
+
+func runKnownAnswerTest(suite oprf.Suite, vector knownAnswer) error {
+	if len(vector.Vectors) == 0 {
+		return fmt.Errorf("%w : no test vector", ErrSelfTest)
+	}
+
+	seed, _ := hex.DecodeString(vector.Seed)
+	keyInfo, _ := hex.DecodeString(vector.KeyInfo)
+
+	privateKey, err := oprf.DeriveKey(suite, vector.Mode, seed, keyInfo)
+	if err != nil {
+		return err
+	}
+
+	serializedBlind, _ := hex.DecodeString(vector.Vectors[0].Blind)
+	info, _ := hex.DecodeString(vector.Vectors[0].Info)
+	input, _ := hex.DecodeString(vector.Vectors[0].Input)
+	expectedOutput, _ := hex.DecodeString(vector.Vectors[0].Output)
+
+	blind := suite.Group().NewScalar()
+	if err := blind.UnmarshalBinary(serializedBlind); err != nil {
+		return err
+	}
+
+	output, err := roundTrip(newServer(suite, vector.Mode, privateKey), vector.Mode, privateKey.Public(), input,
+		info, blind)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(output, expectedOutput) {
+		return fmt.Errorf("%w : unexpected output %x", ErrSelfTest, output)
+	}
+
+	return nil
+}
+
+// selfTestServers runs a round trip with a fixed input on the server of each published key in each mode.
+func selfTestServers(keySet *KeySet, servers map[oprf.Mode]map[string]Server) []SelfTestResult {
+	suite := keySet.Suite()
+	results := make([]SelfTestResult, 0, len(servers)*len(keySet.Published()))
+
+	for _, mode := range []oprf.Mode{oprf.BaseMode, oprf.VerifiableMode, oprf.PartialObliviousMode} {
+		for _, key := range keySet.Published() {
+			server, ok := servers[mode][key.ID]
+			if !ok {
+				// no partially oblivious server for a key share
+				continue
+			}
+
+			_, err := roundTrip(server, mode, key.PrivateKey.Public(), selfTestInput, selfTestInfo,
+				suite.Group().RandomScalar(rand.Reader))
+			results = append(results, newSelfTestResult(SelfTestRoundTrip, suite, mode, key.ID, err))
+		}
+	}
+
+	return results
+}
+
+// roundTrip blinds the input, evaluates it with the server and finalizes the evaluation. The proof is verified
+// against the public key in the verifiable and partially oblivious modes, the output is checked by the server.
+func roundTrip(server Server, mode oprf.Mode, publicKey *oprf.PublicKey, input, info []byte,
+	blind oprf.Blind,
+) ([]byte, error) {
+	suite := server.Suite()
+	inputs := [][]byte{input}
+	blinds := []oprf.Blind{blind}
+
+	var (
+		finalizeData      *oprf.FinalizeData
+		evaluationRequest *oprf.EvaluationRequest
+		finalize          func(*oprf.Evaluation) ([][]byte, error)
+		err               error
+	)
+
+	switch mode {
+	case oprf.BaseMode:
+		client := oprf.NewClient(suite)
+		finalizeData, evaluationRequest, err = client.DeterministicBlind(inputs, blinds)
+		finalize = func(evaluation *oprf.Evaluation) ([][]byte, error) {
+			return client.Finalize(finalizeData, evaluation)
+		}
+	case oprf.VerifiableMode:
+		client := oprf.NewVerifiableClient(suite, publicKey)
+		finalizeData, evaluationRequest, err = client.DeterministicBlind(inputs, blinds)
+		finalize = func(evaluation *oprf.Evaluation) ([][]byte, error) {
+			return client.Finalize(finalizeData, evaluation)
+		}
+	case oprf.PartialObliviousMode:
+		client := oprf.NewPartialObliviousClient(suite, publicKey)
+		finalizeData, evaluationRequest, err = client.DeterministicBlind(inputs, blinds)
+		finalize = func(evaluation *oprf.Evaluation) ([][]byte, error) {
+			return client.Finalize(finalizeData, evaluation, info)
+		}
+	default:
+		return nil, fmt.Errorf("%w : unknown mode %d", ErrSelfTest, mode)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w : blind : %s", ErrSelfTest, err.Error())
+	}
+
+	evaluation, err := server.Evaluate(evaluationRequest, info)
+	if err != nil {
+		return nil, fmt.Errorf("%w : evaluate : %s", ErrSelfTest, err.Error())
+	}
+
+	outputs, err := finalize(evaluation)
+	if err != nil {
+		return nil, fmt.Errorf("%w : finalize : %s", ErrSelfTest, err.Error())
+	}
+
+	if !server.VerifyFinalize(input, info, outputs[0]) {
+		return nil, fmt.Errorf("%w : the server couldn't verify the output", ErrSelfTest)
+	}
+
+	return outputs[0], nil
+}
+
+func selfTestPassed(results []SelfTestResult) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SelfTestReport returns the results of the known-answer tests and of the self-test of the served keys.
+func (s *OPRFServerController) SelfTestReport() *SelfTestReport {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+
+	report := &SelfTestReport{CheckedAt: s.selfTestCheckedAt}
+	report.Results = append(report.Results, s.knownAnswerResults...)
+
+	for _, suite := range SupportedSuites() {
+		report.Results = append(report.Results, s.selfTestResults[suite.Identifier()]...)
+	}
+
+	report.Passed = len(report.Results) > 0 && selfTestPassed(report.Results)
+
+	return report
+}
+
+// HealthResponse is the status of the server with the self-test report
+type HealthResponse struct {
+	Status   string          `json:"status"`
+	SelfTest *SelfTestReport `json:"self_test"`
+}
+
+// HealthHandler is an endpoint returning the self-test report.
+// It returns an HTTP 503 Service Unavailable Error if a check failed.
+func (s *OPRFServerController) HealthHandler(c echo.Context) error {
+	report := s.SelfTestReport()
+	if !report.Passed {
+		return c.JSON(http.StatusServiceUnavailable, &HealthResponse{Status: "failed", SelfTest: report}) //nolint:wrapcheck
+	}
+
+	return c.JSON(http.StatusOK, &HealthResponse{Status: "ok", SelfTest: report}) //nolint:wrapcheck
+}
+
+// RequireSelfTest is a middleware refusing the requests with an HTTP 503 Service Unavailable Error
+// if the self-test failed.
+func (s *OPRFServerController) RequireSelfTest(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		s.keysMu.RLock()
+		healthy := s.healthy
+		s.keysMu.RUnlock()
+
+		if !healthy {
+			return protocol.NewError(protocol.CodeServiceUnavailable, "the self-test failed")
+		}
+
+		return next(c)
+	}
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+func TestKnownAnswerTests(t *testing.T) {
+	results := RunKnownAnswerTests()
+	if len(results) != 3*len(SupportedSuites()) {
+		t.Errorf("%d known-answer tests instead of one per suite and mode", len(results))
+	}
+
+	for _, result := range results {
+		if !result.Passed {
+			t.Errorf("%s in mode %d : %s", result.Suite, result.Mode, result.Error)
+		}
+	}
+
+	// the tests only run once per process
+	if again := RunKnownAnswerTests(); &again[0] != &results[0] {
+		t.Error("the known-answer tests ran again")
+	}
+}
+
+func TestSelfTestServers(t *testing.T) {
+	suite := oprf.SuiteP384
+
+	keySet, err := GenerateKeySet(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servers := newSuiteServers(keySet)
+	if results := selfTestServers(keySet, servers); len(results) != 3 || !selfTestPassed(results) {
+		t.Fatalf("the self-test of the servers should pass : %v", results)
+	}
+
+	// a server evaluating with another key than the published one
+	otherKey, err := oprf.GenerateKey(suite, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servers[oprf.VerifiableMode][keySet.Active().ID] = newServer(suite, oprf.VerifiableMode, otherKey)
+
+	results := selfTestServers(keySet, servers)
+	for _, result := range results {
+		if result.Passed == (result.Mode == oprf.VerifiableMode) {
+			t.Errorf("only the verifiable mode should fail : %v", result)
+		}
+	}
+}
+
+func TestInitializeSelfTest(t *testing.T) {
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	report := controller.SelfTestReport()
+	if !report.Passed || len(report.Results) != 6*len(SupportedSuites()) {
+		t.Errorf("the known-answer tests and a round trip per suite and mode should pass : %v", report)
+	}
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/cloudflare/circl/group"
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+// MaxKeyShares is the maximum number of shares of a key
+const MaxKeyShares = 255
+
+var ErrInvalidThreshold = errors.New("invalid threshold")
+
+// KeyShareInfo describes a Shamir share of a key. The share private key is the evaluation of the secret polynomial
+// at Index, the constant term being the private key of GroupPublicKey.
+type KeyShareInfo struct {
+	Index          uint16 `json:"index"`
+	Threshold      uint16 `json:"threshold"`
+	Total          uint16 `json:"total"`
+	GroupPublicKey []byte `json:"group_public_key"`
+}
+
+// ValidateThreshold checks that threshold out of total shares is a valid Shamir sharing.
+func ValidateThreshold(threshold, total int) error {
+	if threshold < 2 || threshold > total || total > MaxKeyShares {
+		return fmt.Errorf("%w : %d-of-%d, expected 2 <= threshold <= shares <= %d", ErrInvalidThreshold,
+			threshold, total, MaxKeyShares)
+	}
+
+	return nil
+}
+
+// SplitKey splits the private key into total Shamir shares, any threshold of them reconstruct the key.
+// The share keys keep the key ID, the creation time, the state and the validity of the key.
+func SplitKey(suite oprf.Suite, key *Key, threshold, total int) ([]*Key, error) {
+	if err := ValidateThreshold(threshold, total); err != nil {
+		return nil, err
+	}
+
+	secret, err := privateKeyScalar(suite, key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	suiteGroup := suite.Group()
+
+	// f(x) = secret + a_1 x + ... + a_{t-1} x^{t-1}
+	coefficients := make([]group.Scalar, threshold)
+	coefficients[0] = secret
+
+	for index := 1; index < threshold; index++ {
+		coefficients[index] = suiteGroup.RandomScalar(rand.Reader)
+	}
+
+	groupPublicKey := SerializePublicKey(key.PrivateKey)
+	shares := make([]*Key, total)
+
+	for index := 1; index <= total; index++ {
+		x := suiteGroup.NewScalar().SetUint64(uint64(index))
+
+		// Horner's method
+		y := suiteGroup.NewScalar()
+		for degree := threshold - 1; degree >= 0; degree-- {
+			y.Mul(y, x)
+			y.Add(y, coefficients[degree])
+		}
+
+		privateKey, err := scalarPrivateKey(suite, y)
+		if err != nil {
+			return nil, err
+		}
+
+		shares[index-1] = &Key{
+			ID:         key.ID,
+			CreatedAt:  key.CreatedAt,
+			State:      key.State,
+			PrivateKey: privateKey,
+			Share: &KeyShareInfo{
+				Index:          uint16(index),
+				Threshold:      uint16(threshold),
+				Total:          uint16(total),
+				GroupPublicKey: groupPublicKey,
+			},
+			KeyValidity: key.KeyValidity,
+		}
+	}
+
+	return shares, nil
+}
+
+// CombineKeyShares rebuilds the private key from a threshold of its shares.
+func CombineKeyShares(suite oprf.Suite, shares []*Key) (*oprf.PrivateKey, error) {
+	if len(shares) == 0 || len(shares) < int(shares[0].Share.Threshold) {
+		return nil, fmt.Errorf("%w : not enough shares", ErrInvalidThreshold)
+	}
+
+	shares = shares[:shares[0].Share.Threshold]
+	indices := make([]uint16, len(shares))
+
+	for position, share := range shares {
+		for _, index := range indices[:position] {
+			if index == share.Share.Index {
+				return nil, fmt.Errorf("%w : duplicate share %d", ErrInvalidThreshold, index)
+			}
+		}
+
+		indices[position] = share.Share.Index
+	}
+
+	suiteGroup := suite.Group()
+	secret := suiteGroup.NewScalar()
+
+	for _, share := range shares {
+		y, err := privateKeyScalar(suite, share.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		secret.Add(secret, y.Mul(y, protocol.LagrangeCoefficient(suiteGroup, share.Share.Index, indices)))
+	}
+
+	return scalarPrivateKey(suite, secret)
+}
+
+func privateKeyScalar(suite oprf.Suite, privateKey *oprf.PrivateKey) (group.Scalar, error) {
+	serializedKey, err := privateKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	scalar := suite.Group().NewScalar()
+	if err := scalar.UnmarshalBinary(serializedKey); err != nil {
+		return nil, err
+	}
+
+	return scalar, nil
+}
+
+func scalarPrivateKey(suite oprf.Suite, scalar group.Scalar) (*oprf.PrivateKey, error) {
+	serializedKey, err := scalar.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey := new(oprf.PrivateKey)
+	if err := privateKey.UnmarshalBinary(suite, serializedKey); err != nil {
+		return nil, err
+	}
+
+	return privateKey, nil
+}
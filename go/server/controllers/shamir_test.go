@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+)
+
+func TestSplitKeyEvaluation(t *testing.T) {
+	suite := oprf.SuiteP256
+	suiteGroup := suite.Group()
+
+	keySet, err := GenerateKeySet(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := keySet.Active()
+
+	shares, err := SplitKey(suite, key, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := oprf.NewVerifiableClient(suite, key.PrivateKey.Public())
+
+	_, evaluationRequest, err := client.Blind([][]byte{[]byte("dead3eef")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := VerifiableServer{oprf.NewVerifiableServer(suite, key.PrivateKey), suite}
+
+	expected, err := server.Evaluate(evaluationRequest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// any 2 of the 3 partial evaluations give the evaluation of the key
+	for _, indices := range [][]uint16{{1, 2}, {1, 3}, {2, 3}} {
+		combined := suiteGroup.NewElement()
+
+		for _, index := range indices {
+			share := shares[index-1]
+			if share.ID != key.ID || share.Share.Index != index {
+				t.Fatalf("the share %d metadata does not match", index)
+			}
+
+			partial, err := BaseServer{oprf.NewServer(suite, share.PrivateKey), suite}.Evaluate(evaluationRequest, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lagrangeCoefficient := protocol.LagrangeCoefficient(suiteGroup, index, indices)
+			combined.Add(combined, suiteGroup.NewElement().Mul(partial.Elements[0], lagrangeCoefficient))
+		}
+
+		if !combined.IsEqual(expected.Elements[0]) {
+			t.Errorf("the shares %v don't combine into the key evaluation", indices)
+		}
+	}
+
+	if _, err := SplitKey(suite, key, 4, 3); !errors.Is(err, ErrInvalidThreshold) {
+		t.Errorf("a threshold greater than the number of shares should be rejected : %v", err)
+	}
+}
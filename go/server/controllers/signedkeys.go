@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// KeySetValidity is the time the clients accept a signed key set, it is signed again after half of it
+	KeySetValidity = 24 * time.Hour
+	// keySetCacheControl lets the clients and the proxies cache the signed key set for 5 minutes
+	keySetCacheControl = "public, max-age=300"
+
+	headerETag        = "ETag"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+var ErrInvalidSigningKey = errors.New("invalid key set signing key")
+
+// KeySigner signs the published keys with the online Ed25519 key delegated by the offline root key
+type KeySigner struct {
+	PrivateKey ed25519.PrivateKey
+	Delegation protocol.Delegation
+}
+
+// SerializedKeySigner is the JSON encoding of a KeySigner, the private key being the Ed25519 seed
+type SerializedKeySigner struct {
+	PrivateKey []byte              `json:"private_key"`
+	Delegation protocol.Delegation `json:"delegation"`
+}
+
+// signedKeySet is the signed key set served until the keys change or it must be signed again
+type signedKeySet struct {
+	keys     []byte
+	body     []byte
+	etag     string
+	signedAt time.Time
+}
+
+// ParseKeySigner parses the JSON serialized key signer and checks that the delegation is for its key.
+func ParseKeySigner(data []byte) (*KeySigner, error) {
+	var serializedKeySigner SerializedKeySigner
+	if err := json.Unmarshal(data, &serializedKeySigner); err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidSigningKey, err.Error())
+	}
+
+	if len(serializedKeySigner.PrivateKey) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%w : the private key isn't an Ed25519 seed", ErrInvalidSigningKey)
+	}
+
+	privateKey := ed25519.NewKeyFromSeed(serializedKeySigner.PrivateKey)
+
+	publicKey, _ := privateKey.Public().(ed25519.PublicKey)
+	if !bytes.Equal(publicKey, serializedKeySigner.Delegation.PublicKey) {
+		return nil, fmt.Errorf("%w : the delegation is for another key", ErrInvalidSigningKey)
+	}
+
+	return &KeySigner{PrivateKey: privateKey, Delegation: serializedKeySigner.Delegation}, nil
+}
+
+// LoadKeySignerFromEnv loads the key signer from its environment variable or from its key file, nil if there is
+// none.
+func LoadKeySignerFromEnv() (*KeySigner, error) {
+	serializedKeySigner, ok := os.LookupEnv(EnvKeySigner)
+	if !ok {
+		var err error
+
+		serializedKeySigner, ok, err = FileKeyProvider{Dir: os.Getenv(EnvKeyDir)}.readFile(EnvKeySigner)
+		if err != nil || !ok {
+			return nil, err
+		}
+	}
+
+	keySigner, err := ParseKeySigner([]byte(serializedKeySigner))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse %s : %w", EnvKeySigner, err)
+	}
+
+	if time.Now().After(keySigner.Delegation.NotAfter) {
+		log.Println("The delegation of the key signer expired at", keySigner.Delegation.NotAfter)
+	}
+
+	return keySigner, nil
+}
+
+// SetKeySigner sets the signer of the well-known key set, it must be called before serving the requests.
+func (s *OPRFServerController) SetKeySigner(keySigner *KeySigner) {
+	s.keySigner = keySigner
+}
+
+// WellKnownKeysHandler is an endpoint returning the published keys of every suite with their validity window and
+// their fingerprint, signed by the key delegated by the root key. The signed key set can be cached, it is only signed
+// again when the keys change or after half of its validity, its ETag changes with each signature.
+// For instance :
+// curl http://localhost:1323/.well-known/oprf-keys -H 'If-None-Match: "3f2a..."'
+func (s *OPRFServerController) WellKnownKeysHandler(c echo.Context) error {
+	if s.keySigner == nil {
+		return protocol.NewError(protocol.CodeNotFound, "the keys aren't signed by this server")
+	}
+
+	signed, err := s.signKeySet(time.Now().UTC())
+	if err != nil {
+		return protocol.NewError(protocol.CodeInternal, err.Error())
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, keySetCacheControl)
+	c.Response().Header().Set(headerETag, signed.etag)
+
+	if etagMatch(c.Request().Header.Values(headerIfNoneMatch), signed.etag) {
+		return c.NoContent(http.StatusNotModified) //nolint:wrapcheck
+	}
+
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, signed.body) //nolint:wrapcheck
+}
+
+// etagMatch reports whether the If-None-Match headers list the ETag, with the weak comparison of RFC 7232.
+func etagMatch(ifNoneMatch []string, etag string) bool {
+	for _, header := range ifNoneMatch {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// signKeySet returns the signed key set of the published keys, signed again if the keys changed since the last
+// signature or if it was signed before half of its validity.
+func (s *OPRFServerController) signKeySet(now time.Time) (*signedKeySet, error) {
+	var keys []protocol.KeySetKey
+
+	s.keysMu.RLock()
+
+	for _, suite := range SupportedSuites() {
+		keySet := s.keys[suite.Identifier()]
+		if keySet == nil {
+			continue
+		}
+
+		for _, key := range keySet.Published() {
+			keys = append(keys, protocol.KeySetKey{
+				Suite:         suite.Identifier(),
+				PublicKeyInfo: *NewPublicKeyInfo(suite, key),
+			})
+		}
+	}
+
+	s.keysMu.RUnlock()
+
+	serializedKeys, err := json.Marshal(keys)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't marshal the keys : %w", err)
+	}
+
+	s.signedKeysMu.Lock()
+	defer s.signedKeysMu.Unlock()
+
+	if signed := s.signedKeys; signed != nil && bytes.Equal(signed.keys, serializedKeys) &&
+		now.Before(signed.signedAt.Add(KeySetValidity/2)) {
+		return signed, nil
+	}
+
+	document := &protocol.KeySetDocument{
+		IssuedAt:  now.Truncate(time.Second),
+		ExpiresAt: now.Truncate(time.Second).Add(KeySetValidity),
+		Keys:      keys,
+	}
+
+	// the clients refuse the key sets signed after the end of the delegation
+	if document.ExpiresAt.After(s.keySigner.Delegation.NotAfter) {
+		document.ExpiresAt = s.keySigner.Delegation.NotAfter
+	}
+
+	signedKeys, err := protocol.SignKeySet(s.keySigner.PrivateKey, s.keySigner.Delegation, document)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	body, err := json.Marshal(signedKeys)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't marshal the signed keys : %w", err)
+	}
+
+	// the ETag is the hash of the signed body, a cache revalidating an expired signature gets the new one
+	hash := sha256.Sum256(body)
+	s.signedKeys = &signedKeySet{
+		keys:     serializedKeys,
+		body:     body,
+		etag:     `"` + hex.EncodeToString(hash[:16]) + `"`,
+		signedAt: now,
+	}
+
+	return s.signedKeys, nil
+}
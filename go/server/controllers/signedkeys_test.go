@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+func TestWellKnownKeysHandler(t *testing.T) {
+	rootPublicKey, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingPublicKey, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	delegation := protocol.SignDelegation(rootKey, signingPublicKey, now.Add(-time.Hour), now.Add(time.Hour))
+
+	// the key signer file is checked against its delegation
+	data, _ := json.Marshal(SerializedKeySigner{PrivateKey: signingKey.Seed(), Delegation: delegation})
+
+	keySigner, err := ParseKeySigner(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ = json.Marshal(SerializedKeySigner{PrivateKey: rootKey.Seed(), Delegation: delegation})
+	if _, err := ParseKeySigner(data); !errors.Is(err, ErrInvalidSigningKey) {
+		t.Errorf("the delegation of another key is accepted : %v", err)
+	}
+
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	getKeys := func(etag string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, protocol.WellKnownKeysPath, nil)
+		request.Header.Set(headerIfNoneMatch, etag)
+
+		recorder := httptest.NewRecorder()
+		if err := controller.WellKnownKeysHandler(echo.New().NewContext(request, recorder)); err != nil {
+			t.Fatal(err)
+		}
+
+		return recorder
+	}
+
+	request := httptest.NewRequest(http.MethodGet, protocol.WellKnownKeysPath, nil)
+
+	err = controller.WellKnownKeysHandler(echo.New().NewContext(request, httptest.NewRecorder()))
+	if !errors.Is(err, protocol.ErrNotFound) {
+		t.Errorf("the keys are served without key signer : %v", err)
+	}
+
+	controller.SetKeySigner(keySigner)
+
+	recorder := getKeys("")
+	if recorder.Code != http.StatusOK || recorder.Header().Get(echo.HeaderCacheControl) != keySetCacheControl {
+		t.Fatalf("unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+
+	signedKeySet := new(protocol.SignedKeySet)
+	if err := json.Unmarshal(recorder.Body.Bytes(), signedKeySet); err != nil {
+		t.Fatal(err)
+	}
+
+	document, err := signedKeySet.Verify(rootPublicKey, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every suite has a generated active key, the key set expires with the delegation
+	if len(document.Keys) != len(SupportedSuites()) || !document.ExpiresAt.Equal(delegation.NotAfter) {
+		t.Errorf("unexpected key set %d keys, expires at %s", len(document.Keys), document.ExpiresAt)
+	}
+
+	// the key set isn't signed again while the keys don't change
+	etag := recorder.Header().Get(headerETag)
+	if recorder := getKeys(etag); recorder.Code != http.StatusNotModified {
+		t.Errorf("unexpected status %d with the ETag %s", recorder.Code, etag)
+	}
+
+	for _, ifNoneMatch := range []string{`"0123", ` + etag, "W/" + etag, "*"} {
+		if recorder := getKeys(ifNoneMatch); recorder.Code != http.StatusNotModified {
+			t.Errorf("unexpected status %d with If-None-Match %s", recorder.Code, ifNoneMatch)
+		}
+	}
+
+	// the key set signed again has another ETag, the caches don't keep the expired signature
+	resigned, err := controller.signKeySet(time.Now().UTC().Add(KeySetValidity))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resigned.etag == etag || bytes.Equal(resigned.body, recorder.Body.Bytes()) {
+		t.Errorf("the key set signed again has the ETag %s of the previous signature", resigned.etag)
+	}
+
+	if recorder := getKeys(`"0123"`); recorder.Code != http.StatusOK {
+		t.Errorf("unexpected status %d with another ETag", recorder.Code)
+	}
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+var ErrUnknownLeaf = errors.New("the leaf isn't in the transparency log")
+
+// TransparencyLog is the append-only Merkle log of the published keys and of their state changes. The entries are
+// appended to a file as JSON lines, the log is only kept in memory without file.
+type TransparencyLog struct {
+	path       string
+	leafHashes [][]byte
+	// indices maps the leaf hashes to their index
+	indices map[string]uint64
+	mu      sync.RWMutex
+}
+
+// NewMemoryTransparencyLog returns an empty log only kept in memory.
+func NewMemoryTransparencyLog() *TransparencyLog {
+	return &TransparencyLog{indices: make(map[string]uint64)} //nolint:exhaustivestruct
+}
+
+// OpenTransparencyLog reads the entries of the log file, which is created if it doesn't exist. An empty path opens
+// an in-memory log.
+func OpenTransparencyLog(path string) (*TransparencyLog, error) {
+	transparencyLog := NewMemoryTransparencyLog()
+	if path == "" {
+		return transparencyLog, nil
+	}
+
+	transparencyLog.path = path
+
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open the transparency log : %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry protocol.KeyLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("couldn't parse the entry %d of the transparency log : %w",
+				len(transparencyLog.leafHashes), err)
+		}
+
+		leafData, err := entry.LeafData()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read the entry %d of the transparency log : %w",
+				len(transparencyLog.leafHashes), err)
+		}
+
+		transparencyLog.add(protocol.LeafHash(leafData))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read the transparency log : %w", err)
+	}
+
+	return transparencyLog, nil
+}
+
+// LoadTransparencyLogFromEnv opens the transparency log at the path of EnvTransparencyLog. The file must be on a
+// durable storage, the log is kept in memory without it and its endpoints aren't served.
+func LoadTransparencyLogFromEnv() (*TransparencyLog, error) {
+	return OpenTransparencyLog(os.Getenv(EnvTransparencyLog))
+}
+
+// add appends the leaf hash of an entry in memory.
+func (l *TransparencyLog) add(leafHash []byte) {
+	l.indices[string(leafHash)] = uint64(len(l.leafHashes))
+	l.leafHashes = append(l.leafHashes, leafHash)
+}
+
+// Append appends the entries that aren't in the log yet, in the file first. It returns the number of appended
+// entries.
+func (l *TransparencyLog) Append(entries []protocol.KeyLogEntry) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var (
+		newEntries    []protocol.KeyLogEntry
+		newLeafHashes [][]byte
+	)
+
+	seen := make(map[string]bool)
+
+	for _, entry := range entries {
+		leafData, err := entry.LeafData()
+		if err != nil {
+			return 0, fmt.Errorf("couldn't log the key %q : %w", entry.KeyID, err)
+		}
+
+		leafHash := protocol.LeafHash(leafData)
+		if _, ok := l.indices[string(leafHash)]; !ok && !seen[string(leafHash)] {
+			newEntries = append(newEntries, entry)
+			newLeafHashes = append(newLeafHashes, leafHash)
+			seen[string(leafHash)] = true
+		}
+	}
+
+	if len(newEntries) == 0 {
+		return 0, nil
+	}
+
+	if l.path != "" {
+		var lines []byte
+
+		for _, entry := range newEntries {
+			line, err := json.Marshal(entry)
+			if err != nil {
+				return 0, fmt.Errorf("couldn't marshal the log entry : %w", err)
+			}
+
+			lines = append(append(lines, line...), '\n')
+		}
+
+		file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return 0, fmt.Errorf("couldn't open the transparency log : %w", err)
+		}
+
+		if _, err := file.Write(lines); err != nil {
+			file.Close()
+
+			return 0, fmt.Errorf("couldn't append to the transparency log : %w", err)
+		}
+
+		if err := file.Close(); err != nil {
+			return 0, fmt.Errorf("couldn't append to the transparency log : %w", err)
+		}
+	}
+
+	for _, leafHash := range newLeafHashes {
+		l.add(leafHash)
+	}
+
+	return len(newEntries), nil
+}
+
+// TreeHead returns the current size and root hash of the log.
+func (l *TransparencyLog) TreeHead(now time.Time) protocol.TreeHead {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return protocol.TreeHead{
+		TreeSize:  uint64(len(l.leafHashes)),
+		RootHash:  protocol.RootHash(l.leafHashes),
+		Timestamp: now,
+	}
+}
+
+// InclusionProof returns the inclusion proof of the leaf hash in the tree of treeSize leaves.
+func (l *TransparencyLog) InclusionProof(leafHash []byte, treeSize uint64) (*protocol.InclusionProof, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if treeSize > uint64(len(l.leafHashes)) {
+		return nil, fmt.Errorf("%w : the log has %d leaves", ErrUnknownLeaf, len(l.leafHashes))
+	}
+
+	index, ok := l.indices[string(leafHash)]
+	if !ok || index >= treeSize {
+		return nil, fmt.Errorf("%w : not in the tree of %d leaves", ErrUnknownLeaf, treeSize)
+	}
+
+	return protocol.NewInclusionProof(l.leafHashes[:treeSize], index), nil
+}
+
+// ConsistencyProof returns the consistency proof between the trees of firstSize and secondSize leaves.
+func (l *TransparencyLog) ConsistencyProof(firstSize, secondSize uint64) (*protocol.ConsistencyProof, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if firstSize > secondSize || secondSize > uint64(len(l.leafHashes)) {
+		return nil, fmt.Errorf("%w : trees of %d and %d leaves in a log of %d leaves", ErrUnknownLeaf, firstSize,
+			secondSize, len(l.leafHashes))
+	}
+
+	return protocol.NewConsistencyProof(l.leafHashes[:secondSize], firstSize), nil
+}
+
+// keyLogEntries returns the log entries of the keys of the key set, including the retired keys.
+func keyLogEntries(keySet *KeySet) []protocol.KeyLogEntry {
+	entries := make([]protocol.KeyLogEntry, 0, len(keySet.Keys()))
+
+	for _, key := range keySet.Keys() {
+		entries = append(entries, protocol.KeyLogEntry{
+			Suite:     keySet.Suite().Identifier(),
+			KeyID:     key.ID,
+			PublicKey: SerializePublicKey(key.PrivateKey),
+			State:     string(key.State),
+		})
+	}
+
+	return entries
+}
+
+// SetTransparencyLog sets the log of the published keys, it must be called before Initialize.
+func (s *OPRFServerController) SetTransparencyLog(transparencyLog *TransparencyLog) {
+	s.transparencyLog = transparencyLog
+}
+
+// checkTransparencyLog returns a not found error if the transparency log isn't signed or is only kept in memory : the
+// in-memory log is lost when the server restarts, the clients would see another tree of the same size.
+func (s *OPRFServerController) checkTransparencyLog() error {
+	if s.keySigner == nil {
+		return protocol.NewError(protocol.CodeNotFound, "the transparency log isn't signed by this server")
+	}
+
+	if s.transparencyLog.path == "" {
+		return protocol.NewError(protocol.CodeNotFound, "the transparency log isn't persisted by this server")
+	}
+
+	return nil
+}
+
+// TreeHeadHandler is an endpoint returning the tree head of the transparency log, signed as the well-known key set.
+// For instance :
+// curl http://localhost:1323/api/v2/transparency/tree_head
+func (s *OPRFServerController) TreeHeadHandler(c echo.Context) error {
+	if err := s.checkTransparencyLog(); err != nil {
+		return err
+	}
+
+	treeHead := s.transparencyLog.TreeHead(time.Now())
+
+	signedTreeHead, err := protocol.SignTreeHead(s.keySigner.PrivateKey, s.keySigner.Delegation, treeHead)
+	if err != nil {
+		return protocol.NewError(protocol.CodeInternal, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, signedTreeHead) //nolint:wrapcheck
+}
+
+// InclusionProofHandler is an endpoint returning the inclusion proof of a leaf in the tree of a signed tree head.
+// For instance :
+// curl 'http://localhost:1323/api/v2/transparency/inclusion?leaf_hash=<hex leaf hash>&tree_size=4'
+func (s *OPRFServerController) InclusionProofHandler(c echo.Context) error {
+	if err := s.checkTransparencyLog(); err != nil {
+		return err
+	}
+
+	leafHash, err := hex.DecodeString(c.QueryParam("leaf_hash"))
+	if err != nil {
+		return protocol.NewError(protocol.CodeInvalidRequest, "invalid leaf hash : %s", err.Error())
+	}
+
+	treeSize, err := strconv.ParseUint(c.QueryParam("tree_size"), 10, 64)
+	if err != nil {
+		return protocol.NewError(protocol.CodeInvalidRequest, "invalid tree size : %s", err.Error())
+	}
+
+	proof, err := s.transparencyLog.InclusionProof(leafHash, treeSize)
+	if err != nil {
+		return protocol.NewError(protocol.CodeNotFound, "%s", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, proof) //nolint:wrapcheck
+}
+
+// ConsistencyProofHandler is an endpoint returning the consistency proof between two tree sizes.
+// For instance :
+// curl 'http://localhost:1323/api/v2/transparency/consistency?first=2&second=4'
+func (s *OPRFServerController) ConsistencyProofHandler(c echo.Context) error {
+	if err := s.checkTransparencyLog(); err != nil {
+		return err
+	}
+
+	firstSize, err := strconv.ParseUint(c.QueryParam("first"), 10, 64)
+	if err != nil {
+		return protocol.NewError(protocol.CodeInvalidRequest, "invalid first tree size : %s", err.Error())
+	}
+
+	secondSize, err := strconv.ParseUint(c.QueryParam("second"), 10, 64)
+	if err != nil {
+		return protocol.NewError(protocol.CodeInvalidRequest, "invalid second tree size : %s", err.Error())
+	}
+
+	proof, err := s.transparencyLog.ConsistencyProof(firstSize, secondSize)
+	if err != nil {
+		return protocol.NewError(protocol.CodeNotFound, "%s", err.Error())
+	}
+
+	return c.JSON(http.StatusOK, proof) //nolint:wrapcheck
+}
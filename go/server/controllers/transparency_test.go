@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+func TestTransparencyLog(t *testing.T) {
+	rootPublicKey, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingPublicKey, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	delegation := protocol.SignDelegation(rootKey, signingPublicKey, now.Add(-time.Hour), now.Add(time.Hour))
+
+	path := filepath.Join(t.TempDir(), "keys.log")
+
+	transparencyLog, err := OpenTransparencyLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controller := NewOPRFServerController()
+	controller.SetTransparencyLog(transparencyLog)
+
+	if err := controller.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(handler echo.HandlerFunc, target string, response interface{}) error {
+		recorder := httptest.NewRecorder()
+		if err := handler(echo.New().NewContext(httptest.NewRequest(http.MethodGet, target, nil), recorder)); err != nil {
+			return err
+		}
+
+		return json.Unmarshal(recorder.Body.Bytes(), response)
+	}
+
+	signedTreeHead := new(protocol.SignedTreeHead)
+	if err := get(controller.TreeHeadHandler, protocol.TreeHeadEndpoint, signedTreeHead); !errors.Is(err,
+		protocol.ErrNotFound) {
+		t.Errorf("the tree head is served without key signer : %v", err)
+	}
+
+	controller.SetKeySigner(&KeySigner{PrivateKey: signingKey, Delegation: delegation})
+
+	// the in-memory log isn't served, it changes when the server restarts
+	inMemoryController := NewOPRFServerController()
+	inMemoryController.SetKeySigner(&KeySigner{PrivateKey: signingKey, Delegation: delegation})
+
+	if err := inMemoryController.Initialize(NewMemoryKeyProvider()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := get(inMemoryController.TreeHeadHandler, protocol.TreeHeadEndpoint, signedTreeHead); !errors.Is(err,
+		protocol.ErrNotFound) {
+		t.Errorf("the tree head of the in-memory log is served : %v", err)
+	}
+
+	if err := get(controller.TreeHeadHandler, protocol.TreeHeadEndpoint, signedTreeHead); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signedTreeHead.Verify(rootPublicKey, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// the generated active key of every suite is logged
+	if signedTreeHead.TreeSize != uint64(len(SupportedSuites())) {
+		t.Fatalf("unexpected tree size %d", signedTreeHead.TreeSize)
+	}
+
+	entry := keyLogEntries(controller.keys[SupportedSuites()[0].Identifier()])[0]
+	leafData, err := entry.LeafData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafHash := protocol.LeafHash(leafData)
+	treeSize := strconv.FormatUint(signedTreeHead.TreeSize, 10)
+
+	inclusionProof := new(protocol.InclusionProof)
+	if err := get(controller.InclusionProofHandler, protocol.InclusionProofEndpoint+"?leaf_hash="+
+		hex.EncodeToString(leafHash)+"&tree_size="+treeSize, inclusionProof); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := protocol.VerifyInclusion(leafHash, inclusionProof, signedTreeHead.RootHash); err != nil {
+		t.Error(err)
+	}
+
+	// the logged keys aren't appended again, a state change is appended
+	if appended, err := transparencyLog.Append([]protocol.KeyLogEntry{entry}); err != nil || appended != 0 {
+		t.Errorf("the logged key is appended again : %d %v", appended, err)
+	}
+
+	entry.State = string(KeyStateVerifyOnly)
+	if appended, err := transparencyLog.Append([]protocol.KeyLogEntry{entry}); err != nil || appended != 1 {
+		t.Fatalf("the state change isn't appended : %d %v", appended, err)
+	}
+
+	// the log file is read again when the server restarts
+	reopenedLog, err := OpenTransparencyLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	treeHead := reopenedLog.TreeHead(now)
+	controller.SetTransparencyLog(reopenedLog)
+
+	consistencyProof := new(protocol.ConsistencyProof)
+	if err := get(controller.ConsistencyProofHandler, protocol.ConsistencyProofEndpoint+"?first="+treeSize+
+		"&second="+strconv.FormatUint(treeHead.TreeSize, 10), consistencyProof); err != nil {
+		t.Fatal(err)
+	}
+
+	err = protocol.VerifyConsistency(consistencyProof, signedTreeHead.RootHash, treeHead.RootHash)
+	if err != nil || treeHead.TreeSize != signedTreeHead.TreeSize+1 {
+		t.Errorf("the log didn't grow from the tree head : %d %v", treeHead.TreeSize, err)
+	}
+
+	err = get(controller.ConsistencyProofHandler, protocol.ConsistencyProofEndpoint+"?first=2&second=99",
+		consistencyProof)
+	if !errors.Is(err, protocol.ErrNotFound) {
+		t.Errorf("a proof is returned for a tree larger than the log : %v", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/cloudflare/circl/group"
 	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
 )
 
 // LoadPrivateKey decode the base64 serialized private key and deserialized it.
@@ -29,28 +30,100 @@ func LoadPrivateKey(suite oprf.Suite, serializedBase64Key string) (*oprf.Private
 	return privateKey, nil
 }
 
-// LoadOrGenerateKey tries to load the key from SerializedBase64KeyMap. If there is no entry for the
-// provided cipher suite the key is generated.
-func LoadOrGenerateKey(suite oprf.Suite, serializedBase64KeyMap SerializedBase64KeyMap) (*oprf.PrivateKey, error) {
-	var (
-		privateKey *oprf.PrivateKey
-		err        error
-	)
+// LoadKey deserializes a private key and its metadata. The key ID defaults to NewKeyID and
+// the state defaults to KeyStateActive.
+func LoadKey(suite oprf.Suite, serializedKey SerializedKey) (*Key, error) {
+	privateKey, err := LoadPrivateKey(suite, serializedKey.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{
+		ID:          serializedKey.ID,
+		CreatedAt:   serializedKey.CreatedAt,
+		State:       serializedKey.State,
+		PrivateKey:  privateKey,
+		Share:       serializedKey.Share,
+		KeyValidity: serializedKey.KeyValidity,
+	}
+
+	if key.ID == "" && key.Share != nil {
+		// the shares of a key have the key ID of the key
+		groupPublicKey := new(oprf.PublicKey)
+		if err := groupPublicKey.UnmarshalBinary(suite, key.Share.GroupPublicKey); err != nil {
+			return nil, fmt.Errorf("couldn't load the group public key : %w", err)
+		}
+
+		key.ID = NewKeyID(suite, groupPublicKey)
+	} else if key.ID == "" {
+		key.ID = NewKeyID(suite, privateKey.Public())
+	}
+
+	if key.State == "" {
+		key.State = KeyStateActive
+	}
+
+	if err := ValidateKeyID(key.ID); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
 
-	serializedBase64Key, ok := serializedBase64KeyMap[suite.Identifier()]
-	if ok {
+// SerializeKey serializes a private key and its metadata.
+func SerializeKey(key *Key) (SerializedKey, error) {
+	serializedPrivateKey, err := key.PrivateKey.MarshalBinary()
+	if err != nil {
+		return SerializedKey{}, err //nolint:exhaustivestruct
+	}
+
+	return SerializedKey{
+		ID:          key.ID,
+		CreatedAt:   key.CreatedAt,
+		State:       key.State,
+		PrivateKey:  base64.StdEncoding.EncodeToString(serializedPrivateKey),
+		Share:       key.Share,
+		KeyValidity: key.KeyValidity,
+	}, nil
+}
+
+// LoadKeySet deserializes the keys of a suite. The key set must have an active key.
+func LoadKeySet(suite oprf.Suite, serializedKeys []SerializedKey) (*KeySet, error) {
+	keySet := NewKeySet(suite)
+
+	for _, serializedKey := range serializedKeys {
 		// load a private key for the encryption suite
-		privateKey, err = LoadPrivateKey(suite, serializedBase64Key)
-	} else {
-		// generate a private key for the encryption suite
-		privateKey, err = oprf.GenerateKey(suite, rand.Reader)
+		key, err := LoadKey(suite, serializedKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := keySet.Add(key); err != nil {
+			return nil, err
+		}
+	}
+
+	if keySet.Active() == nil {
+		return nil, fmt.Errorf("%w for the suite %s", ErrNoActiveKey, suite.Identifier())
 	}
 
+	return keySet, nil
+}
+
+// GenerateKeySet returns a key set holding a single, newly generated, active key.
+func GenerateKeySet(suite oprf.Suite) (*KeySet, error) {
+	// generate a private key for the encryption suite
+	privateKey, err := oprf.GenerateKey(suite, rand.Reader)
 	if err != nil {
 		return nil, err
 	}
 
-	return privateKey, nil
+	keySet := NewKeySet(suite)
+	if err := keySet.Add(NewKey(suite, privateKey)); err != nil {
+		return nil, err
+	}
+
+	return keySet, nil
 }
 
 // SerializePublicKey is a wrapper to serialize a public key
@@ -70,9 +143,7 @@ func DeserializeElements(blindedElements [][]byte, suiteGroup group.Group) ([]gr
 		element := suiteGroup.NewElement()
 
 		if err := element.UnmarshalBinary(blindedElement); err != nil {
-			log.Println(err)
-
-			return nil, err
+			return nil, protocol.NewError(protocol.CodeInvalidElement, "blinded element %d : %s", index, err.Error())
 		}
 
 		elements[index] = element
@@ -80,3 +151,27 @@ func DeserializeElements(blindedElements [][]byte, suiteGroup group.Group) ([]gr
 
 	return elements, nil
 }
+
+// DeserializeValidElements deserializes the blinded elements of a partial-success batch.
+// The invalid elements are nil, their status reports the error.
+func DeserializeValidElements(blindedElements [][]byte, suiteGroup group.Group) ([]group.Element,
+	[]protocol.ElementStatus,
+) {
+	elements := make([]group.Element, len(blindedElements))
+	statuses := make([]protocol.ElementStatus, len(blindedElements))
+
+	for index, blindedElement := range blindedElements {
+		element := suiteGroup.NewElement()
+
+		if err := element.UnmarshalBinary(blindedElement); err != nil {
+			statuses[index].Error = protocol.NewError(protocol.CodeInvalidElement, "blinded element %d : %s", index,
+				err.Error())
+
+			continue
+		}
+
+		elements[index] = element
+	}
+
+	return elements, statuses
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+// VerifyHandler is the endpoint of the data stewards, checking that an output is the PRF output of an input and of a
+// public information, for instance during a dispute. The key ID selects any key of the suite, including the retired
+// keys. The public information is the evaluated one, with the server-side fields injected when the pseudonym was
+// issued : the current info policy isn't applied, it may have changed since. The router authenticates it with its
+// own bearer token, every call is audited.
+// For instance :
+// curl -X POST http://localhost:1323/api/verify -H "Authorization: Bearer $OPRF_VERIFIER_TOKEN" \
+// -H 'Content-Type: application/json' -d '{"suite": "P256-SHA256", "mode": "base", "kid": "2021-12", "input": "AA==", \
+// "output": "..."}'
+func (s *OPRFServerController) VerifyHandler(c echo.Context) (err error) {
+	verificationRequest := new(protocol.VerificationRequest)
+	response := new(protocol.VerificationResponse)
+
+	defer func() {
+		auditVerification(c, verificationRequest, response, err)
+	}()
+
+	body, err := s.limits.ReadBody(c)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, verificationRequest); err != nil {
+		return protocol.NewError(protocol.CodeInvalidRequest, err.Error())
+	}
+
+	mode, err := verificationRequest.Mode.OPRFMode()
+	if err != nil {
+		return protocol.NewError(protocol.CodeUnsupportedMode, err.Error())
+	}
+
+	if err := s.limits.ValidateVerification(mode, verificationRequest); err != nil {
+		return err
+	}
+
+	key, server, err := s.verificationServer(mode, verificationRequest.Suite, verificationRequest.KeyID)
+	if err != nil {
+		return APIError(err)
+	}
+
+	response.Suite, response.KeyID = server.Suite().Identifier(), key.ID
+	response.Match = server.VerifyFinalize(verificationRequest.Input, verificationRequest.Info,
+		verificationRequest.Output)
+
+	return c.JSON(http.StatusOK, response) //nolint:wrapcheck
+}
+
+// AuditUnauthenticatedVerification logs the caller of a verification refused by the authentication.
+func AuditUnauthenticatedVerification(c echo.Context, err error) {
+	log.Printf("Audit : unauthenticated verification by %s : %s\n", c.RealIP(), err)
+}
+
+// auditVerification logs the caller, the request and the result of a verification. The input isn't logged.
+func auditVerification(c echo.Context, verificationRequest *protocol.VerificationRequest,
+	response *protocol.VerificationResponse, err error,
+) {
+	result := "no match"
+
+	switch {
+	case err != nil:
+		result = string(APIError(err).Code)
+	case response.Match:
+		result = "match"
+	}
+
+	log.Printf("Audit : verification by %s, suite %q, mode %q, key %q, %d bytes info : %s\n", c.RealIP(),
+		verificationRequest.Suite, verificationRequest.Mode, response.KeyID, len(verificationRequest.Info), result)
+}
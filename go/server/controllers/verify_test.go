@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/circl/oprf"
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/labstack/echo/v4"
+)
+
+func TestVerifyHandler(t *testing.T) {
+	keySet, err := LoadKeySet(oprf.SuiteP256, []SerializedKey{
+		{ID: "new", PrivateKey: "nQy3QFqrK4iLsHgV1pjBC8yStfMqWXLkVMX7BvVkD5w="},
+		{ID: "gone", State: KeyStateRetired, PrivateKey: "nLhFIkmIYdXCN8crpHHNJGmbxzvDLg4jtCxoJKo4wMw="},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controller := NewOPRFServerController()
+	if err := controller.Initialize(NewMemoryKeyProvider(keySet)); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(verificationRequest *protocol.VerificationRequest) (*protocol.VerificationResponse, error) {
+		body, _ := json.Marshal(verificationRequest)
+		request := httptest.NewRequest(http.MethodPost, "/api/verify", bytes.NewReader(body))
+		request.Header.Set(echo.HeaderContentType, protocol.ContentTypeJSON)
+
+		recorder := httptest.NewRecorder()
+		if err := controller.VerifyHandler(echo.New().NewContext(request, recorder)); err != nil {
+			return nil, err
+		}
+
+		response := new(protocol.VerificationResponse)
+
+		return response, json.Unmarshal(recorder.Body.Bytes(), response)
+	}
+
+	input, info := []byte("alice"), []byte("survey")
+
+	// the pseudonyms of the retired keys can still be verified
+	for _, keyID := range []string{"new", "gone"} {
+		key, _ := keySet.Get(keyID)
+
+		output, err := oprf.NewPartialObliviousServer(oprf.SuiteP256, key.PrivateKey).FullEvaluate(input, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		verificationRequest := &protocol.VerificationRequest{
+			Suite:  oprf.SuiteP256.Identifier(),
+			Mode:   protocol.ModePartialOblivious,
+			Info:   info,
+			Input:  input,
+			Output: output,
+			KeyID:  keyID,
+		}
+
+		if response, err := verify(verificationRequest); err != nil || !response.Match || response.KeyID != keyID {
+			t.Errorf("key %s : the output doesn't match (%v)", keyID, err)
+		}
+
+		verificationRequest.Info = []byte("census")
+		if response, err := verify(verificationRequest); err != nil || response.Match {
+			t.Errorf("key %s : the output matches another info (%v)", keyID, err)
+		}
+	}
+
+	// the pseudonym is verified with its evaluated info after a change of the info policy
+	controller.SetInfoPolicy(InfoPolicy{
+		Structured: true,
+		Required:   nil,
+		Allowed:    nil,
+		Injected:   map[protocol.InfoField]string{protocol.InfoFieldTenant: "ensimag"},
+	})
+
+	sentInfo, _ := protocol.StructuredInfo{protocol.InfoFieldApplication: []byte("survey")}.MarshalBinary()
+
+	evaluatedInfo, err := controller.infoPolicy.Apply(sentInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := oprf.NewPartialObliviousServer(oprf.SuiteP256, keySet.Active().PrivateKey).FullEvaluate(input,
+		evaluatedInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controller.SetInfoPolicy(InfoPolicy{
+		Structured: true,
+		Required:   nil,
+		Allowed:    map[protocol.InfoField][]string{protocol.InfoFieldApplication: {"census"}},
+		Injected:   map[protocol.InfoField]string{protocol.InfoFieldTenant: "grenoble-inp"},
+	})
+
+	for _, test := range []struct {
+		name  string
+		info  []byte
+		match bool
+	}{
+		{"evaluated info", evaluatedInfo, true},
+		{"sent info", sentInfo, false},
+	} {
+		response, err := verify(&protocol.VerificationRequest{
+			Suite:  oprf.SuiteP256.Identifier(),
+			Mode:   protocol.ModePartialOblivious,
+			Info:   test.info,
+			Input:  input,
+			Output: output,
+			KeyID:  "",
+		})
+		if err != nil || response.Match != test.match {
+			t.Errorf("%s : match %v (%v), expected %v", test.name, response != nil && response.Match, err, test.match)
+		}
+	}
+
+	if _, err := verify(&protocol.VerificationRequest{
+		Suite:  oprf.SuiteP256.Identifier(),
+		Mode:   protocol.ModeBase,
+		Info:   nil,
+		Input:  input,
+		Output: nil,
+		KeyID:  "unknown",
+	}); !errors.Is(err, protocol.ErrKeyUnavailable) {
+		t.Errorf("unknown key ID : %v", err)
+	}
+}
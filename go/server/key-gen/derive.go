@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cloudflare/circl/oprf"
+
+	"github.com/ensimag-oprf/go/server/controllers"
+)
+
+// runDerive prints the keys derived from the master seed so they can be checked against the deployed server.
+func runDerive(args []string) {
+	flags := flag.NewFlagSet("derive", flag.ExitOnError)
+	seedFile := flags.String("seed-file", "", "File holding the base64 master seed, defaults to "+
+		controllers.EnvMasterSeed)
+	newSeed := flags.Bool("new-seed", false, "Generate a new master seed")
+	kids := flags.String("kid", strings.Join(controllers.LoadDerivedKeyIDsFromEnv(), ","),
+		"Comma-separated key IDs, the last one is active")
+	suiteID := flags.String("suite", "", "Cipher suite, defaults to every supported suite")
+	showPrivate := flags.Bool("private", false, "Show the base64 encoded private keys")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	seed := loadSeed(*seedFile, *newSeed)
+
+	suites := controllers.SupportedSuites()
+	if *suiteID != "" {
+		suite, err := oprf.GetSuite(*suiteID)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		suites = []oprf.Suite{suite}
+	}
+
+	for _, suite := range suites {
+		keySet, err := controllers.DeriveKeySet(suite, seed, controllers.ParseDerivedKeyIDs(*kids))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, key := range keySet.Keys() {
+			log.Println("Suite :", suite, ", key ID :", key.ID, ", state :", key.State)
+			log.Println("Base64 encoded public key :",
+				base64.StdEncoding.EncodeToString(controllers.SerializePublicKey(key.PrivateKey)))
+			log.Println("Fingerprint :", controllers.Fingerprint(suite, key.PrivateKey.Public()))
+
+			if *showPrivate {
+				serializedKey, err := key.PrivateKey.MarshalBinary()
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				log.Println("Base64 encoded private key :", base64.StdEncoding.EncodeToString(serializedKey))
+			}
+		}
+	}
+}
+
+// loadSeed generates a new master seed or reads it from the file or the environment.
+func loadSeed(seedFile string, newSeed bool) []byte {
+	if newSeed {
+		seed := make([]byte, controllers.MinMasterSeedLength)
+		if _, err := rand.Read(seed); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("Base64 encoded master seed :", base64.StdEncoding.EncodeToString(seed))
+
+		return seed
+	}
+
+	if seedFile != "" {
+		content, err := os.ReadFile(seedFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return seed
+	}
+
+	seed, ok, err := controllers.LoadMasterSeedFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !ok {
+		log.Fatal("no master seed : use -seed-file, -new-seed or ", controllers.EnvMasterSeed)
+	}
+
+	return seed
+}
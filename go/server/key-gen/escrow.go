@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudflare/circl/oprf"
+
+	"github.com/ensimag-oprf/go/server/controllers"
+)
+
+// runEscrowExport prints the active key of the suite as printable Shamir shares for the custodians.
+func runEscrowExport(args []string) {
+	flags := flag.NewFlagSet("escrow-export", flag.ExitOnError)
+	threshold := flags.Int("threshold", 2, "Number of shares needed to rebuild the key")
+	total := flags.Int("shares", 3, "Number of shares i.e. of custodians")
+	suiteID := flags.String("suite", "P256-SHA256", "Cipher suite")
+	importKeys := flags.Bool("import", false, "Export the active key configured for the server (keystore, "+
+		"environment variables, key files)")
+	generate := flags.Bool("generate", false, "Generate and export a new key")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	// a new key is only escrowed on request, the configured key is the one to recover
+	if *importKeys == *generate {
+		log.Fatal("either -import or -generate selects the exported key")
+	}
+
+	suite, err := oprf.GetSuite(*suiteID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	key := loadOrGenerateKeySets([]oprf.Suite{suite}, *importKeys)[suite.Identifier()].Active()
+
+	escrowShares, err := controllers.ExportEscrowShares(suite, key, *threshold, *total)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Suite : %s, key ID : %s, %d-of-%d escrow shares\n", suite, key.ID, *threshold, *total)
+	log.Println("Fingerprint :", controllers.Fingerprint(suite, key.PrivateKey.Public()))
+
+	// the labels are comments, ignored by escrow-import
+	for _, escrowShare := range escrowShares {
+		fmt.Printf("# %s\n%s\n\n", escrowShare.Label(), escrowShare)
+	}
+}
+
+// runEscrowImport rebuilds a key from the printable shares of the custodians, read from the arguments or the
+// standard input. The key set file <out>/<suite>_KEY_SET is written only if the rebuilt public key matches the
+// published fingerprint, an existing key set file is never overwritten.
+func runEscrowImport(args []string) {
+	flags := flag.NewFlagSet("escrow-import", flag.ExitOnError)
+	fingerprint := flags.String("fingerprint", "", "Published fingerprint of the key (required)")
+	out := flags.String("out", ".", "Output directory of the key set file")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	if *fingerprint == "" {
+		log.Fatal("the published fingerprint of the key is required")
+	}
+
+	lines := flags.Args()
+	if len(lines) == 0 {
+		lines = readLines(os.Stdin)
+	}
+
+	escrowShares := make([]*controllers.EscrowShare, 0, len(lines))
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		escrowShare, err := controllers.ParseEscrowShare(line)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("Loaded :", escrowShare.Label())
+
+		escrowShares = append(escrowShares, escrowShare)
+	}
+
+	key, err := controllers.ImportEscrowShares(escrowShares, *fingerprint)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	serializedKey, err := controllers.SerializeKey(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := json.Marshal([]controllers.SerializedKey{serializedKey})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.MkdirAll(*out, 0o700); err != nil {
+		log.Fatal(err)
+	}
+
+	// the other keys of an existing key set would be lost
+	path := filepath.Join(*out, envKeySetNames()[escrowShares[0].Suite.Identifier()])
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+
+		log.Fatal(err)
+	}
+
+	if err := file.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Key %s of the suite %s rebuilt, written to %s\n", key.ID, escrowShares[0].Suite, path)
+}
+
+func readLines(reader io.Reader) []string {
+	var lines []string
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	return lines
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/cloudflare/circl/oprf"
+
+	"github.com/ensimag-oprf/go/server/controllers"
+)
+
+// runInspect prints the suite, the public key and the fingerprint of a private key, a public key,
+// a key set file, a keystore or the keys configured for the server.
+func runInspect(args []string) {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	suiteID := flags.String("suite", "P256-SHA256", "Cipher suite of the private, public key or key set")
+	privateKey := flags.String("private", "", "Base64 encoded private key")
+	publicKey := flags.String("public", "", "Base64 encoded public key")
+	keySetFile := flags.String("key-set", "", "Key set file")
+	keystore := flags.String("keystore", "", "Keystore file")
+	passphraseFile := flags.String("passphrase-file", "", "File holding the passphrase of the keystore, "+
+		"defaults to "+controllers.EnvKeystorePassphrase+" or a prompt")
+	importKeys := flags.Bool("import", false, "Inspect the keys configured for the server (keystore, "+
+		"environment variables, key files)")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	suite, err := oprf.GetSuite(*suiteID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch {
+	case *privateKey != "":
+		key, err := controllers.LoadKey(suite, controllers.SerializedKey{PrivateKey: *privateKey}) //nolint:exhaustivestruct
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		logKey(suite, key)
+	case *publicKey != "":
+		serializedPublicKey, err := base64.StdEncoding.DecodeString(*publicKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		deserializedPublicKey := new(oprf.PublicKey)
+		if err := deserializedPublicKey.UnmarshalBinary(suite, serializedPublicKey); err != nil {
+			log.Fatal(err)
+		}
+
+		logPublicKey(suite, deserializedPublicKey)
+	case *keySetFile != "":
+		data, err := os.ReadFile(*keySetFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var serializedKeys []controllers.SerializedKey
+		if err := json.Unmarshal(data, &serializedKeys); err != nil {
+			log.Fatal(err)
+		}
+
+		keySet, err := controllers.LoadKeySet(suite, serializedKeys)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		logKeySets(controllers.KeySetMap{suite.Identifier(): keySet})
+	case *keystore != "":
+		passphrase := controllers.PassphraseSourceFromEnv()
+		if *passphraseFile != "" {
+			passphrase = controllers.PassphraseFromFile(*passphraseFile)
+		}
+
+		keySets, err := controllers.KeystoreKeyProvider{Path: *keystore, Passphrase: passphrase}.LoadKeySets(
+			controllers.SupportedSuites())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		logKeySets(keySets)
+	case *importKeys:
+		keyProvider, err := controllers.NewKeyProviderFromEnv()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		keySets, err := keyProvider.LoadKeySets(controllers.SupportedSuites())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		logKeySets(keySets)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+func logKeySets(keySets controllers.KeySetMap) {
+	for _, suite := range controllers.SupportedSuites() {
+		if keySet, ok := keySets[suite.Identifier()]; ok {
+			for _, key := range keySet.Keys() {
+				logKey(suite, key)
+			}
+		}
+	}
+}
+
+func logKey(suite oprf.Suite, key *controllers.Key) {
+	log.Println("Key ID :", key.ID, ", state :", key.State)
+
+	if key.Share != nil {
+		log.Printf("Share %d of %d (threshold %d)\n", key.Share.Index, key.Share.Total, key.Share.Threshold)
+	}
+
+	logPublicKey(suite, key.PrivateKey.Public())
+}
+
+// logPublicKey prints the suite, the base64 encoded public key and its fingerprint.
+func logPublicKey(suite oprf.Suite, publicKey *oprf.PublicKey) {
+	serializedPublicKey, err := publicKey.MarshalBinary()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Suite :", suite)
+	log.Println("Base64 encoded public key :", base64.StdEncoding.EncodeToString(serializedPublicKey))
+	log.Println("Fingerprint :", controllers.Fingerprint(suite, publicKey))
+}
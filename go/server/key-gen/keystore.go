@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ensimag-oprf/go/server/controllers"
+)
+
+var errPassphraseMismatch = errors.New("the passphrases don't match")
+
+// runKeystore writes a passphrase-encrypted keystore holding a key set for every supported suite.
+func runKeystore(args []string) {
+	flags := flag.NewFlagSet("keystore", flag.ExitOnError)
+	out := flags.String("out", "keystore.json", "Path of the keystore file")
+	passphraseFile := flags.String("passphrase-file", "", "File holding the passphrase, "+
+		"defaults to "+controllers.EnvKeystorePassphrase+" or a prompt")
+	importKeys := flags.Bool("import", false, "Import the keys configured for the server (environment variables, "+
+		"key files) instead of generating new keys")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	keySets := make(controllers.KeySetMap)
+
+	if *importKeys {
+		keyProvider, err := controllers.NewKeyProviderFromEnv()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		keySets, err = keyProvider.LoadKeySets(controllers.SupportedSuites())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, suite := range controllers.SupportedSuites() {
+		if _, ok := keySets[suite.Identifier()]; ok {
+			log.Println("Imported key set :", suite)
+
+			continue
+		}
+
+		keySet, err := controllers.GenerateKeySet(suite)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("Generated key :", suite, keySet.Active().ID)
+
+		keySets[suite.Identifier()] = keySet
+	}
+
+	passphrase, err := newPassphrase(*passphraseFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keystore, err := controllers.EncryptKeystore(keySets, passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(*out, keystore, 0o600); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Keystore written to", *out)
+}
+
+// newPassphrase reads the passphrase from the file, the environment or a confirmed prompt.
+func newPassphrase(passphraseFile string) ([]byte, error) {
+	if passphraseFile != "" {
+		return controllers.PassphraseFromFile(passphraseFile)()
+	}
+
+	if _, ok := os.LookupEnv(controllers.EnvKeystorePassphrase); ok {
+		return controllers.PassphraseFromEnv(controllers.EnvKeystorePassphrase)()
+	}
+
+	passphrase, err := controllers.PassphraseFromPrompt("New keystore passphrase : ")()
+	if err != nil {
+		return nil, err
+	}
+
+	confirmation, err := controllers.PassphraseFromPrompt("Confirm the passphrase : ")()
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(passphrase, confirmation) {
+		return nil, errPassphraseMismatch
+	}
+
+	return passphrase, nil
+}
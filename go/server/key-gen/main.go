@@ -9,6 +9,8 @@ import (
 	"os"
 
 	"github.com/cloudflare/circl/oprf"
+
+	"github.com/ensimag-oprf/go/server/controllers"
 )
 
 var (
@@ -17,16 +19,68 @@ var (
 )
 
 func init() {
-	flag.StringVar(&suiteID, "suite", "P256-SHA256", "Cipher suite : P256-SHA256, P384-SHA384 or P521-SHA512")
+	flag.StringVar(&suiteID, "suite", "P256-SHA256",
+		"Cipher suite : P256-SHA256, P384-SHA384, P521-SHA512 or ristretto255-SHA512")
 	flag.BoolVar(&help, "help", false, "Show the usage")
 
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
 		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nSubcommands (use -help for their flags):\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  inspect\tprint the public key and the fingerprint of a key\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  keystore\twrite a passphrase-encrypted keystore of every suite\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  derive\tprint the keys derived from the master seed\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  split\t\tsplit the keys into Shamir shares for threshold evaluators\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  schedule\tgenerate key sets rotated on schedule\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  escrow-export\tprint a key as Shamir shares for custodians\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  escrow-import\trebuild a key from the custodians' shares\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  root\t\tgenerate the offline root key of the signed key sets\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  delegate\tdelegate the signature of the key sets to a new key\n")
 	}
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "inspect":
+			runInspect(os.Args[2:])
+
+			return
+		case "keystore":
+			runKeystore(os.Args[2:])
+
+			return
+		case "derive":
+			runDerive(os.Args[2:])
+
+			return
+		case "split":
+			runSplit(os.Args[2:])
+
+			return
+		case "schedule":
+			runSchedule(os.Args[2:])
+
+			return
+		case "escrow-export":
+			runEscrowExport(os.Args[2:])
+
+			return
+		case "escrow-import":
+			runEscrowImport(os.Args[2:])
+
+			return
+		case "root":
+			runRoot(os.Args[2:])
+
+			return
+		case "delegate":
+			runDelegate(os.Args[2:])
+
+			return
+		}
+	}
+
 	flag.Parse()
 
 	// Handle required flags
@@ -55,4 +109,5 @@ func main() {
 
 	// Show the base64 encoded key
 	log.Println("Base64 encode private key :", base64.StdEncoding.EncodeToString(serializedKey))
+	log.Println("Fingerprint :", controllers.Fingerprint(suite, privateKey.Public()))
 }
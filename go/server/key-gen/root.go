@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ensimag-oprf/go/protocol"
+	"github.com/ensimag-oprf/go/server/controllers"
+)
+
+// runRoot generates the Ed25519 root key of the signed key sets. The base64 seed is written to a file to keep
+// offline, the base64 public key is configured in the clients. The file must not exist.
+func runRoot(args []string) {
+	flags := flag.NewFlagSet("root", flag.ExitOnError)
+	out := flags.String("out", "oprf-root.key", "Output file of the base64 root private key")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// an existing root key is never overwritten, the clients would stop trusting the server
+	file, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := file.WriteString(base64.StdEncoding.EncodeToString(privateKey.Seed()) + "\n"); err != nil {
+		file.Close()
+
+		log.Fatal(err)
+	}
+
+	if err := file.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Root private key written to", *out, ": keep it offline")
+	log.Println("Base64 encoded root public key :", base64.StdEncoding.EncodeToString(publicKey))
+}
+
+// runDelegate generates an online signing key of the key sets and delegates it with the offline root key.
+// The key signer file <out>/OPRF_KEY_SIGNER is loaded by the server from its key directory.
+func runDelegate(args []string) {
+	flags := flag.NewFlagSet("delegate", flag.ExitOnError)
+	rootFile := flags.String("root-file", "oprf-root.key", "File holding the base64 root private key")
+	validity := flags.Duration("validity", 90*24*time.Hour, "Validity of the delegation")
+	out := flags.String("out", ".", "Output directory of the key signer file")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	content, err := os.ReadFile(*rootFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		log.Fatal("the root file doesn't hold a base64 Ed25519 seed")
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	now := time.Now()
+	delegation := protocol.SignDelegation(ed25519.NewKeyFromSeed(seed), publicKey, now, now.Add(*validity))
+
+	data, err := json.Marshal(controllers.SerializedKeySigner{PrivateKey: privateKey.Seed(), Delegation: delegation})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.MkdirAll(*out, 0o700); err != nil {
+		log.Fatal(err)
+	}
+
+	path := filepath.Join(*out, controllers.EnvKeySigner)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Signing key delegated until %s, written to %s\n", delegation.NotAfter.Format(time.RFC3339), path)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudflare/circl/oprf"
+
+	"github.com/ensimag-oprf/go/server/controllers"
+)
+
+// runSchedule generates a key set of consecutive validity windows for every suite : the first key is active and
+// the next ones are pending, to be promoted by the server at the start of their window.
+// The key set of each suite is written to <out>/<suite>_KEY_SET, to be loaded with OPRF_KEY_DIR.
+func runSchedule(args []string) {
+	flags := flag.NewFlagSet("schedule", flag.ExitOnError)
+	count := flags.Int("keys", 3, "Number of keys i.e. of validity windows")
+	period := flags.Duration("period", 30*24*time.Hour, "Validity period of each key")
+	maxEvaluations := flags.Uint64("max-evaluations", 0, "Number of evaluated elements after which a key is "+
+		"rotated before the end of its window, 0 is unlimited")
+	start := flags.String("start", "", "Start of the first window (RFC 3339), defaults to now")
+	suiteID := flags.String("suite", "", "Cipher suite, defaults to every supported suite")
+	out := flags.String("out", ".", "Output directory")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	if *count < 1 || *period <= 0 {
+		log.Fatal("at least one key with a positive period is required")
+	}
+
+	notBefore := time.Now().UTC().Truncate(time.Second)
+	if *start != "" {
+		var err error
+		if notBefore, err = time.Parse(time.RFC3339, *start); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	suites := controllers.SupportedSuites()
+	if *suiteID != "" {
+		suite, err := oprf.GetSuite(*suiteID)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		suites = []oprf.Suite{suite}
+	}
+
+	if err := os.MkdirAll(*out, 0o700); err != nil {
+		log.Fatal(err)
+	}
+
+	envKeySetNames := envKeySetNames()
+
+	for _, suite := range suites {
+		serializedKeys := make([]controllers.SerializedKey, *count)
+
+		for index := range serializedKeys {
+			privateKey, err := oprf.GenerateKey(suite, rand.Reader)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			key := controllers.NewKey(suite, privateKey)
+			if index > 0 {
+				key.State = controllers.KeyStatePending
+			}
+
+			windowStart := notBefore.Add(time.Duration(index) * *period)
+			windowEnd := windowStart.Add(*period)
+			key.NotBefore, key.NotAfter, key.MaxEvaluations = &windowStart, &windowEnd, *maxEvaluations
+
+			if serializedKeys[index], err = controllers.SerializeKey(key); err != nil {
+				log.Fatal(err)
+			}
+
+			log.Printf("Suite : %s, key ID : %s, %s, from %s to %s\n", suite, key.ID, key.State,
+				windowStart.Format(time.RFC3339), windowEnd.Format(time.RFC3339))
+		}
+
+		data, err := json.Marshal(serializedKeys)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		path := filepath.Join(*out, envKeySetNames[suite.Identifier()])
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("Key set written to", path)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudflare/circl/oprf"
+
+	"github.com/ensimag-oprf/go/server/controllers"
+)
+
+// runSplit splits the active key of every suite into Shamir shares for threshold evaluators.
+// The key set file of the server i is written to <out>/server-<i>/<suite>_KEY_SET, to be loaded with OPRF_KEY_DIR.
+func runSplit(args []string) {
+	flags := flag.NewFlagSet("split", flag.ExitOnError)
+	threshold := flags.Int("threshold", 2, "Number of partial evaluations needed to finalize")
+	total := flags.Int("shares", 3, "Number of shares i.e. of evaluators")
+	suiteID := flags.String("suite", "", "Cipher suite, defaults to every supported suite")
+	out := flags.String("out", "shares", "Output directory")
+	importKeys := flags.Bool("import", false, "Split the active keys configured for the server (keystore, "+
+		"environment variables, key files) instead of generating new keys")
+
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	suites := controllers.SupportedSuites()
+	if *suiteID != "" {
+		suite, err := oprf.GetSuite(*suiteID)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		suites = []oprf.Suite{suite}
+	}
+
+	keySets := loadOrGenerateKeySets(suites, *importKeys)
+	envKeySetNames := envKeySetNames()
+
+	for _, suite := range suites {
+		key := keySets[suite.Identifier()].Active()
+
+		shares, err := controllers.SplitKey(suite, key, *threshold, *total)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Printf("Suite : %s, key ID : %s, %d-of-%d shares\n", suite, key.ID, *threshold, *total)
+
+		for _, share := range shares {
+			serializedShare, err := controllers.SerializeKey(share)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			data, err := json.Marshal([]controllers.SerializedKey{serializedShare})
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			dir := filepath.Join(*out, fmt.Sprintf("server-%d", share.Share.Index))
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				log.Fatal(err)
+			}
+
+			path := filepath.Join(dir, envKeySetNames[suite.Identifier()])
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				log.Fatal(err)
+			}
+
+			log.Println("Share written to", path)
+		}
+	}
+}
+
+// loadOrGenerateKeySets loads the key sets configured for the server or generates new ones.
+func loadOrGenerateKeySets(suites []oprf.Suite, importKeys bool) controllers.KeySetMap {
+	keySets := make(controllers.KeySetMap)
+
+	if importKeys {
+		keyProvider, err := controllers.NewKeyProviderFromEnv()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		keySets, err = controllers.StrictKeyProvider{KeyProvider: keyProvider}.LoadKeySets(suites)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return keySets
+	}
+
+	for _, suite := range suites {
+		keySet, err := controllers.GenerateKeySet(suite)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		keySets[suite.Identifier()] = keySet
+	}
+
+	return keySets
+}
+
+// envKeySetNames returns the key set environment variable name of each suite
+func envKeySetNames() map[string]string {
+	names := make(map[string]string)
+	for envKeySet, suiteID := range controllers.GetEnvKeySetSuiteMap() {
+		names[suiteID] = envKeySet
+	}
+
+	return names
+}
@@ -1,16 +1,22 @@
 package routers
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"os"
 
+	"github.com/ensimag-oprf/go/protocol"
 	"github.com/ensimag-oprf/go/server/controllers"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
-func NewRouter(serializedBase64KeyMap controllers.SerializedBase64KeyMap) (*echo.Echo, error) {
+func NewRouter(oprfServerController *controllers.OPRFServerController) *echo.Echo {
 	router := echo.New()
+	router.HTTPErrorHandler = controllers.HTTPErrorHandler
+	// the audited client address can't be forged with the X-Forwarded-For or X-Real-IP headers
+	router.IPExtractor = oprfServerController.IPExtractor()
 
 	// Middlewares
 	router.Use(middleware.Logger())
@@ -25,16 +31,74 @@ func NewRouter(serializedBase64KeyMap controllers.SerializedBase64KeyMap) (*echo
 	// Endpoints
 	router.File("/", "public/index.html")
 
-	oprfServerController := controllers.NewOPRFServerController()
-	if err := oprfServerController.Initialize(serializedBase64KeyMap); err != nil {
-		return nil, err
+	// The signed key set is served at the root of the server, for the clients checking the keys with the root key
+	router.GET(protocol.WellKnownKeysPath, oprfServerController.WellKnownKeysHandler,
+		oprfServerController.RequireSelfTest)
+
+	// The keys are only served if the self-test passed
+	api := router.Group("/api")
+	api.GET(protocol.PublicKeysEndpoint, oprfServerController.GetKeysHandler, oprfServerController.RequireSelfTest)
+	api.POST(protocol.EvaluateEndpoint, oprfServerController.EvaluateV1Handler, oprfServerController.RequireSelfTest)
+	api.GET(protocol.HealthEndpoint, oprfServerController.HealthHandler)
+	api.GET(protocol.CapabilitiesEndpoint, oprfServerController.CapabilitiesHandler,
+		oprfServerController.RequireSelfTest)
+
+	// The v2 API names the modes, the v1 API is kept at the API root for the existing clients
+	v2 := api.Group(protocol.V2Path)
+	v2.GET(protocol.PublicKeysEndpoint, oprfServerController.GetKeysHandler, oprfServerController.RequireSelfTest)
+	v2.POST(protocol.EvaluateEndpoint, oprfServerController.EvaluateHandler, oprfServerController.RequireSelfTest)
+	v2.GET(protocol.HealthEndpoint, oprfServerController.HealthHandler)
+	v2.GET(protocol.CapabilitiesEndpoint, oprfServerController.CapabilitiesHandler,
+		oprfServerController.RequireSelfTest)
+
+	// The transparency log of the published keys, signed as the well-known key set
+	v2.GET(protocol.TreeHeadEndpoint, oprfServerController.TreeHeadHandler, oprfServerController.RequireSelfTest)
+	v2.GET(protocol.InclusionProofEndpoint, oprfServerController.InclusionProofHandler,
+		oprfServerController.RequireSelfTest)
+	v2.GET(protocol.ConsistencyProofEndpoint, oprfServerController.ConsistencyProofHandler,
+		oprfServerController.RequireSelfTest)
+
+	// Admin endpoints, authenticated with the bearer token
+	if adminToken := os.Getenv(controllers.EnvAdminToken); adminToken != "" {
+		admin := router.Group("/api/admin", bearerAuth(adminToken, nil))
+		admin.POST("/reload", oprfServerController.ReloadHandler)
 	}
 
-	router.GET("/api/request_public_keys", oprfServerController.GetKeysHandler)
-	router.POST("/api/evaluate", oprfServerController.EvaluateHandler)
+	// The trusted callers evaluate their inputs without blinding, authenticated with their own bearer token
+	if trustedCallerToken := os.Getenv(controllers.EnvTrustedCallerToken); trustedCallerToken != "" {
+		api.POST(protocol.FullEvaluateEndpoint, oprfServerController.FullEvaluateHandler,
+			bearerAuth(trustedCallerToken, controllers.AuditUnauthenticatedFullEvaluation),
+			oprfServerController.RequireSelfTest)
+	}
+
+	// The data stewards verify the pseudonyms, authenticated with their own bearer token
+	if verifierToken := os.Getenv(controllers.EnvVerifierToken); verifierToken != "" {
+		api.POST(protocol.VerifyEndpoint, oprfServerController.VerifyHandler,
+			bearerAuth(verifierToken, controllers.AuditUnauthenticatedVerification),
+			oprfServerController.RequireSelfTest)
+	}
 
 	// Static files
 	router.Static("/static", "./public/static")
 
-	return router, nil
+	return router
+}
+
+// bearerAuth authenticates the requests with the bearer token, compared in constant time. The optional onFailure is
+// called with the rejected requests, which are then refused with an unauthorized error.
+func bearerAuth(token string, onFailure func(c echo.Context, err error)) echo.MiddlewareFunc {
+	config := middleware.DefaultKeyAuthConfig
+	config.Validator = func(key string, c echo.Context) (bool, error) {
+		return subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1, nil
+	}
+
+	if onFailure != nil {
+		config.ErrorHandler = func(err error, c echo.Context) error {
+			onFailure(c, err)
+
+			return protocol.NewError(protocol.CodeUnauthorized, "missing or invalid bearer token")
+		}
+	}
+
+	return middleware.KeyAuthWithConfig(config)
 }